@@ -1,23 +1,94 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	"badge-reader/internal/store"
 	"badge-reader/internal/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func Run(dbPath string) error {
-	st, err := store.OpenBadger(dbPath)
+func Run(cfg Config) error {
+	st, err := openStore(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to open badger db: %w", err)
+		return err
 	}
 	defer st.Close()
 
-	m := ui.NewModel(st, dbPath)
-	if _, err := tea.NewProgram(m).Run(); err != nil {
+	label := cfg.DBPath
+	if cfg.Store.InMemory {
+		label = "(in-memory)"
+	} else if cfg.Snapshot {
+		label += " (snapshot copy)"
+	}
+	m := ui.NewModel(st, label, ui.Options{Delimiters: cfg.Delimiters, MatchMode: cfg.MatchMode, BackupCatalog: cfg.BackupCatalogPath()})
+	final, err := tea.NewProgram(m).Run()
+	// I stop scans still running so the store can close promptly.
+	if fm, ok := final.(ui.Model); ok {
+		fm.CancelJobs()
+	}
+	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// openStore opens the DB with the undo journal and audit log next to it,
+// for the TUI and the subcommands alike.
+func openStore(cfg Config) (*store.BadgerStore, error) {
+	if cfg.Snapshot {
+		st, err := store.OpenCheckpoint(cfg.DBPath, cfg.Store)
+		if err != nil {
+			return nil, openError(cfg, fmt.Errorf("snapshot copy: %w", err))
+		}
+		return st, nil
+	}
+	if !cfg.Store.InMemory {
+		cfg.Store.TrashPath = store.TrashPath(cfg.DBPath)
+	}
+	cfg.Store.AuditPath = cfg.AuditPath()
+	st, err := store.OpenBadger(cfg.DBPath, cfg.Store)
+	if errors.Is(err, store.ErrLocked) && cfg.WaitForLock > 0 {
+		st, err = waitForLock(cfg)
+	}
+	if err != nil {
+		return nil, openError(cfg, err)
+	}
+	return st, nil
+}
+
+func openError(cfg Config, err error) error {
+	switch {
+	case errors.Is(err, store.ErrEncryptionKeyRequired) || errors.Is(err, store.ErrEncryptionKeyMismatch):
+		return fmt.Errorf("cannot open %s: %w", cfg.DBPath, err)
+	case errors.Is(err, store.ErrLocked) && cfg.WaitForLock > 0:
+		return fmt.Errorf("cannot open %s: still locked after %s; --snapshot reads a copy instead: %w", cfg.DBPath, cfg.WaitForLock, err)
+	case errors.Is(err, store.ErrLocked):
+		return fmt.Errorf("cannot open %s: %w\n--snapshot reads a copy instead, --wait-for-lock 30s waits for the lock", cfg.DBPath, err)
+	}
+	return fmt.Errorf("failed to open badger db: %w", err)
+}
+
+// waitForLock retries once a second until the lock is free or the wait is
+// over, counting down on stderr. Right as the other process lets go, it may
+// still be closing its files, so I give a failed open one more try.
+func waitForLock(cfg Config) (*store.BadgerStore, error) {
+	deadline := time.Now().Add(cfg.WaitForLock)
+	defer fmt.Fprintln(os.Stderr)
+	retried := false
+	for {
+		fmt.Fprintf(os.Stderr, "\rDatabase is locked by another process; retrying for %s… (Ctrl+C stops) ", time.Until(deadline).Round(time.Second))
+		time.Sleep(min(time.Second, max(0, time.Until(deadline))))
+		st, err := store.OpenBadger(cfg.DBPath, cfg.Store)
+		if err != nil && !errors.Is(err, store.ErrLocked) && !retried {
+			retried = true
+			continue
+		}
+		if !errors.Is(err, store.ErrLocked) || !time.Now().Before(deadline) {
+			return st, err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"badge-reader/internal/store"
+)
+
+// AuditQuery selects audit log entries; zero fields match everything.
+type AuditQuery struct {
+	Key    string
+	Prefix string
+	Op     string
+	User   string
+	Since  time.Time
+	Until  time.Time
+	JSON   bool // print the matching lines as JSONL instead of a table
+}
+
+// A drop prefix entry matches every key under its prefix, and a restored
+// backup every key, as it may have written any.
+func (q AuditQuery) match(e store.AuditEntry) bool {
+	key := e.RawKey()
+	keyOK, prefixOK := key == q.Key, strings.HasPrefix(key, q.Prefix)
+	switch e.Op {
+	case store.AuditDropPrefix:
+		keyOK = strings.HasPrefix(q.Key, key)
+		prefixOK = prefixOK || strings.HasPrefix(q.Prefix, key)
+	case store.AuditRestoreBackup:
+		keyOK, prefixOK = true, true
+	}
+	switch {
+	case q.Key != "" && !keyOK,
+		q.Prefix != "" && !prefixOK,
+		q.Op != "" && !strings.EqualFold(e.Op, q.Op),
+		q.User != "" && e.User != q.User,
+		!q.Since.IsZero() && e.Time.Before(q.Since),
+		!q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// AuditPath is the log this config writes to: --audit-log, or the file
+// next to the DB. An in-memory DB is only logged when it is set.
+func (c Config) AuditPath() string {
+	if c.Store.AuditPath != "" || c.Store.InMemory {
+		return c.Store.AuditPath
+	}
+	return store.AuditPath(c.DBPath)
+}
+
+// RunAudit prints the entries of the audit log that q selects, oldest first.
+func RunAudit(cfg Config, q AuditQuery, w io.Writer) error {
+	path := cfg.AuditPath()
+	if path == "" {
+		return errors.New("no audit log: an in-memory DB is only logged with --audit-log")
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no audit log at %s yet: nothing was changed with --write", path)
+	}
+	if q.JSON {
+		enc := json.NewEncoder(w)
+		return store.ReadAuditLog(path, func(e store.AuditEntry) error {
+			if !q.match(e) {
+				return nil
+			}
+			return enc.Encode(e)
+		})
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tOP\tKEY\tOLD\tNEW")
+	n := 0
+	err := store.ReadAuditLog(path, func(e store.AuditEntry) error {
+		if !q.match(e) {
+			return nil
+		}
+		n++
+		key := strconv.Quote(e.Key)
+		if e.KeyHex != "" {
+			key = "0x" + e.KeyHex
+		}
+		before, after := describeAuditValue(e.OldSize, e.OldSHA256), describeAuditValue(e.NewSize, e.NewSHA256)
+		switch e.Op {
+		case store.AuditDropPrefix:
+			key += "*"
+			before = fmt.Sprintf("%d keys", e.Keys)
+		case store.AuditRestoreBackup:
+			key = e.File
+			before = fmt.Sprintf("%d keys", e.ExistingKeys)
+			after = fmt.Sprintf("%d entries, versions %d-%d", e.Keys, e.MinVersion, e.MaxVersion)
+		}
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Local().Format(time.DateTime), e.User, e.Op, key, before, after)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d entries\n", n)
+	return err
+}
+
+// I show the size and the start of the hash, enough to tell values apart.
+func describeAuditValue(size *int, sum string) string {
+	if size == nil {
+		return "-"
+	}
+	if _, err := hex.DecodeString(sum); err == nil && len(sum) > 12 {
+		sum = sum[:12]
+	}
+	return fmt.Sprintf("%dB %s", *size, sum)
+}
+
+// ParseAuditTime reads a --since/--until value: RFC 3339, a local date or
+// date and time, or a duration meaning that long before now.
+func ParseAuditTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339, 2006-01-02[ 15:04[:05]] or a duration such as 24h", s)
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"badge-reader/internal/backup"
+	"badge-reader/internal/store"
+
+	"github.com/dustin/go-humanize"
+)
+
+// BackupRequest is what the backup subcommand was asked for. An empty Path
+// names the file after the DB and the time.
+type BackupRequest struct {
+	Path        string
+	Prefix      string
+	Incremental bool
+	Since       uint64
+	Gzip        bool
+}
+
+// BackupCatalogPath is the catalog of this config's DB; an in-memory DB
+// has none.
+func (c Config) BackupCatalogPath() string {
+	if c.Store.InMemory {
+		return ""
+	}
+	return backup.CatalogPath(c.DBPath)
+}
+
+// RunBackup writes a full or incremental backup and records it.
+func RunBackup(ctx context.Context, cfg Config, r BackupRequest, log io.Writer) error {
+	if r.Path == "" {
+		if cfg.Store.InMemory {
+			return errors.New("backup: an in-memory DB needs -o")
+		}
+		r.Path = backup.DefaultPath(cfg.DBPath, r.Incremental || r.Since > 0, time.Now())
+	}
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	o := backup.Options{Prefix: r.Prefix, Incremental: r.Incremental, Since: r.Since, Gzip: r.Gzip, Key: cfg.Store.EncryptionKey}
+	info, err := backup.Create(ctx, st, cfg.BackupCatalogPath(), r.Path, o, nil)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	fmt.Fprintf(log, "%s to %s (%s).\n", backup.Describe(info), info.Path, humanize.IBytes(uint64(info.Size)))
+	return nil
+}
+
+// RunRestore loads backups, a full one and then its incremental ones, into
+// the DB, which must be empty unless force is set.
+func RunRestore(ctx context.Context, cfg Config, paths []string, force bool, log io.Writer) error {
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	err = backup.Restore(ctx, st, paths, force, cfg.Store.EncryptionKey)
+	if errors.Is(err, store.ErrNotAudited) {
+		return fmt.Errorf("restored %d backups into %s, but %w", len(paths), cfg.DBPath, err)
+	}
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	fmt.Fprintf(log, "Restored %d backups into %s.\n", len(paths), cfg.DBPath)
+	return nil
+}
+
+// RunVerify restores a backup into memory and checks its key count against
+// the catalog.
+func RunVerify(ctx context.Context, cfg Config, path string, log io.Writer) error {
+	keys, info, known, err := backup.Verify(ctx, cfg.BackupCatalogPath(), path, cfg.Store.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if !known {
+		fmt.Fprintf(log, "%s loads with %d keys; it is not in the catalog, so there is no count to compare.\n", path, keys)
+		return nil
+	}
+	if keys != info.Keys {
+		return fmt.Errorf("verify: %s loads with %d keys but %d were backed up (keys whose TTL has passed since count as missing)", path, keys, info.Keys)
+	}
+	fmt.Fprintf(log, "%s is good: %d keys, as backed up.\n", path, keys)
+	return nil
+}
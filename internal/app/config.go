@@ -0,0 +1,226 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"badge-reader/internal/store"
+	"badge-reader/internal/ui"
+
+	"github.com/dustin/go-humanize"
+)
+
+const DefaultDBPath = "./data/badger"
+
+// EncryptionKeyEnv holds the key itself (raw or hex) as an alternative to a key file.
+const EncryptionKeyEnv = "BADGER_GUI_ENCRYPTION_KEY"
+
+type Config struct {
+	DBPath string
+	Store  store.Options
+	// Delimiters are the bytes the tree browser splits keys on.
+	Delimiters string
+	MatchMode  store.MatchMode
+	// Snapshot opens a private copy of the DB instead of the DB itself.
+	Snapshot bool
+	// WaitForLock is how long to retry while another process holds the lock.
+	WaitForLock time.Duration
+}
+
+// Settings mirrors the CLI flags and the JSON config keys. A nil field is
+// "not set", so the profile value (or a lower layer) is kept.
+type Settings struct {
+	DBPath         *string `json:"dbpath"`
+	Write          *bool   `json:"write"`
+	Profile        *string `json:"profile"`
+	BlockCacheSize *string `json:"block_cache_size"`
+	IndexCacheSize *string `json:"index_cache_size"`
+	NumCompactors  *int    `json:"num_compactors"`
+	Compression    *string `json:"compression"`
+	SyncWrites     *bool   `json:"sync_writes"`
+	ValueThreshold *string `json:"value_threshold"`
+	InMemory       *bool   `json:"in_memory"`
+	LogLevel       *string `json:"log_level"`
+	ScanWorkers    *int    `json:"scan_workers"`
+	Delimiters     *string `json:"delimiters"`
+	MatchMode      *string `json:"match_mode"`
+	AuditLog       *string `json:"audit_log"`
+	Snapshot       *bool   `json:"snapshot"`
+	WaitForLock    *string `json:"wait_for_lock"`
+
+	EncryptionKeyFile     *string `json:"encryption_key_file"`
+	EncryptionKeyRotation *string `json:"encryption_key_rotation"`
+	// EncryptionKey is only read from the environment, never from a file or flag.
+	EncryptionKey *string `json:"-"`
+}
+
+func LoadSettingsFile(path string) (Settings, error) {
+	var s Settings
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func EnvSettings() Settings {
+	var s Settings
+	if v, ok := os.LookupEnv(EncryptionKeyEnv); ok && v != "" {
+		s.EncryptionKey = &v
+	}
+	return s
+}
+
+// ResolveConfig starts from the selected profile and applies each layer in
+// order, so later layers (CLI flags) win over earlier ones (config file).
+func ResolveConfig(layers ...Settings) (Config, error) {
+	profile := ""
+	for _, l := range layers {
+		if l.Profile != nil {
+			profile = *l.Profile
+		}
+	}
+	opts, err := store.ProfileOptions(profile)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := Config{DBPath: DefaultDBPath, Store: opts, Delimiters: ui.DefaultDelimiters}
+	for _, l := range layers {
+		if err := l.apply(&cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.Snapshot && (!cfg.Store.ReadOnly || cfg.Store.InMemory) {
+		return Config{}, fmt.Errorf("a snapshot copy is read-only and cannot be combined with write or in-memory mode")
+	}
+	return cfg, nil
+}
+
+func (s Settings) apply(cfg *Config) error {
+	if s.DBPath != nil {
+		cfg.DBPath = *s.DBPath
+	}
+	if s.Write != nil {
+		cfg.Store.ReadOnly = !*s.Write
+	}
+	if s.BlockCacheSize != nil {
+		n, err := parseSize("block cache size", *s.BlockCacheSize)
+		if err != nil {
+			return err
+		}
+		cfg.Store.BlockCacheSize = n
+	}
+	if s.IndexCacheSize != nil {
+		n, err := parseSize("index cache size", *s.IndexCacheSize)
+		if err != nil {
+			return err
+		}
+		cfg.Store.IndexCacheSize = n
+	}
+	if s.NumCompactors != nil {
+		if *s.NumCompactors == 1 || *s.NumCompactors < 0 {
+			return fmt.Errorf("compactors must be 0 or at least 2, got %d", *s.NumCompactors)
+		}
+		cfg.Store.NumCompactors = *s.NumCompactors
+	}
+	if s.Compression != nil {
+		cfg.Store.Compression = *s.Compression
+	}
+	if s.SyncWrites != nil {
+		cfg.Store.SyncWrites = *s.SyncWrites
+	}
+	if s.ValueThreshold != nil {
+		n, err := parseSize("value threshold", *s.ValueThreshold)
+		if err != nil {
+			return err
+		}
+		cfg.Store.ValueThreshold = n
+	}
+	if s.InMemory != nil {
+		cfg.Store.InMemory = *s.InMemory
+	}
+	if s.LogLevel != nil {
+		cfg.Store.LogLevel = *s.LogLevel
+	}
+	if s.ScanWorkers != nil {
+		if *s.ScanWorkers < 0 {
+			return fmt.Errorf("scan workers must be 0 (one per CPU) or more, got %d", *s.ScanWorkers)
+		}
+		cfg.Store.ScanWorkers = *s.ScanWorkers
+	}
+	if s.Delimiters != nil {
+		if err := checkDelimiters(*s.Delimiters); err != nil {
+			return err
+		}
+		cfg.Delimiters = *s.Delimiters
+	}
+	if s.MatchMode != nil {
+		mode, err := store.ParseMatchMode(*s.MatchMode)
+		if err != nil {
+			return err
+		}
+		cfg.MatchMode = mode
+	}
+	if s.AuditLog != nil {
+		cfg.Store.AuditPath = *s.AuditLog
+	}
+	if s.Snapshot != nil {
+		cfg.Snapshot = *s.Snapshot
+	}
+	if s.WaitForLock != nil {
+		d, err := time.ParseDuration(*s.WaitForLock)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid wait for lock %q: want a duration such as 30s", *s.WaitForLock)
+		}
+		cfg.WaitForLock = d
+	}
+	if s.EncryptionKey != nil {
+		key, err := store.ParseEncryptionKey([]byte(*s.EncryptionKey))
+		if err != nil {
+			return fmt.Errorf("%s: %w", EncryptionKeyEnv, err)
+		}
+		cfg.Store.EncryptionKey = key
+	}
+	if s.EncryptionKeyFile != nil && *s.EncryptionKeyFile != "" {
+		key, err := store.ReadEncryptionKeyFile(*s.EncryptionKeyFile)
+		if err != nil {
+			return err
+		}
+		cfg.Store.EncryptionKey = key
+	}
+	if s.EncryptionKeyRotation != nil {
+		d, err := time.ParseDuration(*s.EncryptionKeyRotation)
+		if err != nil {
+			return fmt.Errorf("invalid encryption key rotation %q: %w", *s.EncryptionKeyRotation, err)
+		}
+		cfg.Store.EncryptionKeyRotation = d
+	}
+	return nil
+}
+
+// I accept plain byte counts as well as units like 64MB or 1GiB.
+func parseSize(name, v string) (int64, error) {
+	n, err := humanize.ParseBytes(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	return int64(n), nil
+}
+
+// The tree splits on single bytes, so I only take printable ASCII delimiters.
+func checkDelimiters(d string) error {
+	if d == "" {
+		return fmt.Errorf("delimiters must not be empty")
+	}
+	for _, r := range d {
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("invalid delimiters %q: only printable ASCII characters are supported", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"badge-reader/internal/store"
+	"badge-reader/internal/transfer"
+)
+
+// ExportRequest is what the export subcommand was asked for. Path "-" is
+// standard output.
+type ExportRequest struct {
+	Path   string
+	Prefix string
+	Match  string // empty exports every key
+	// MatchMode reads Match; empty means glob. cfg.MatchMode is only where
+	// the browser's filter starts, and fuzzy would pick more than asked for.
+	MatchMode string
+	Format    string // jsonl or csv; empty picks by the file extension
+	Encoding  string
+	Flatten   bool
+	Force     bool // overwrite a file that exists
+}
+
+// RunExport writes the selected keys to a file and reports the count on log.
+func RunExport(ctx context.Context, cfg Config, r ExportRequest, log io.Writer) error {
+	o := transfer.ExportOptions{Query: store.KeyQuery{Prefix: r.Prefix}, Flatten: r.Flatten, Overwrite: r.Force}
+	var err error
+	o.Format = transfer.FormatForPath(r.Path)
+	if r.Format != "" {
+		if o.Format, err = transfer.ParseFormat(r.Format); err != nil {
+			return err
+		}
+	}
+	if r.Encoding != "" {
+		if o.Encoding, err = transfer.ParseEncoding(r.Encoding); err != nil {
+			return err
+		}
+	}
+	if r.Flatten && o.Format != transfer.FormatCSV {
+		return fmt.Errorf("--flatten only applies to CSV")
+	}
+	if r.Match != "" {
+		mode := store.MatchGlob
+		if r.MatchMode != "" {
+			if mode, err = store.ParseMatchMode(r.MatchMode); err != nil {
+				return err
+			}
+		}
+		m, err := store.NewMatcher(mode, r.Match)
+		if err != nil {
+			return err
+		}
+		o.Matcher = &m
+	}
+
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if r.Path == "-" {
+		n, err := transfer.Export(ctx, st, os.Stdout, o, nil)
+		return exportResult(log, n, o.Format, err)
+	}
+	n, err := transfer.ExportFile(ctx, st, r.Path, o, nil)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s exists; pass --force to overwrite it", r.Path)
+	}
+	return exportResult(log, n, o.Format, err)
+}
+
+func exportResult(log io.Writer, n int, f transfer.Format, err error) error {
+	if err != nil {
+		return fmt.Errorf("export stopped after %s: %w", transfer.Plural(n, "key"), err)
+	}
+	fmt.Fprintf(log, "Exported %s as %s.\n", transfer.Plural(n, "key"), f)
+	return nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"badge-reader/internal/store"
+	"badge-reader/internal/transfer"
+)
+
+// ImportRequest is what the import subcommand was asked for. Empty fields
+// take the defaults of transfer.ImportOptions.
+type ImportRequest struct {
+	Path        string
+	Format      string // jsonl or csv; empty picks by the file extension
+	KeyColumn   string
+	KeyTemplate string
+	ValueColumn string
+	Encoding    string
+	TTLColumn   string
+	MetaColumn  string
+	Conflict    string
+	DryRun      bool
+}
+
+// RunImport loads a file into the DB, or with a dry run only counts what
+// it would do, and reports the counts on log.
+func RunImport(ctx context.Context, cfg Config, r ImportRequest, log io.Writer) error {
+	o := transfer.ImportOptions{
+		Format:      transfer.FormatForPath(r.Path),
+		KeyColumn:   r.KeyColumn,
+		KeyTemplate: r.KeyTemplate,
+		ValueColumn: r.ValueColumn,
+		TTLColumn:   r.TTLColumn,
+		MetaColumn:  r.MetaColumn,
+		DryRun:      r.DryRun,
+	}
+	var err error
+	if r.Format != "" {
+		if o.Format, err = transfer.ParseFormat(r.Format); err != nil {
+			return err
+		}
+	}
+	if r.Encoding != "" {
+		if o.Encoding, err = transfer.ParseEncoding(r.Encoding); err != nil {
+			return err
+		}
+	}
+	if r.Conflict != "" {
+		if o.Conflict, err = store.ParseConflict(r.Conflict); err != nil {
+			return err
+		}
+	}
+
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	sum := transfer.ImportFile(ctx, st, r.Path, o, nil)
+	if sum.Err != nil {
+		if r.DryRun {
+			return fmt.Errorf("dry run stopped (%s): %w", transfer.DescribeImport(sum), sum.Err)
+		}
+		if errors.Is(sum.Err, store.ErrNotAudited) && sum.Failed == 0 {
+			return fmt.Errorf("imported (%s), but %w", transfer.DescribeImport(sum), sum.Err)
+		}
+		if sum.Created+sum.Overwritten > 0 {
+			return fmt.Errorf("import stopped (%s; those were written, u in the browser undoes them): %w", transfer.DescribeImport(sum), sum.Err)
+		}
+		return fmt.Errorf("import stopped (%s): %w", transfer.DescribeImport(sum), sum.Err)
+	}
+	if r.DryRun {
+		fmt.Fprintf(log, "Dry run, nothing written: %s.\n", transfer.DescribeImport(sum))
+		return nil
+	}
+	fmt.Fprintf(log, "Imported: %s.\n", transfer.DescribeImport(sum))
+	return nil
+}
@@ -0,0 +1,354 @@
+// Package backup takes Badger-format backups of the store to files, gzip
+// compressed or not, loads them back, and keeps a catalog of the backups
+// taken of each DB so an incremental one knows where to carry on.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+)
+
+// Source is what a backup reads; the store implements it.
+type Source interface {
+	Backup(ctx context.Context, w io.Writer, since uint64, prefix string, p *store.Progress) (uint64, int, error)
+}
+
+// Target is what a restore writes to; the store implements it. name is the
+// file, for the audit log.
+type Target interface {
+	LoadBackup(ctx context.Context, r io.Reader, name string, allowExisting bool) error
+}
+
+// Info is one backup, a line of the catalog.
+type Info struct {
+	Path string    `json:"path"`
+	At   time.Time `json:"at"`
+	// Since is the first version the backup holds, 0 for a full one, and
+	// Version the last; the next incremental backup starts after it.
+	Since   uint64 `json:"since"`
+	Version uint64 `json:"version"`
+	Prefix  string `json:"prefix,omitempty"`
+	Keys    int    `json:"keys"` // live keys, which a verify expects back
+	Size    int64  `json:"size"`
+	Gzip    bool   `json:"gzip,omitempty"`
+	// Encrypted backups are sealed with the key of the DB they were taken of.
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+func (i Info) Incremental() bool { return i.Since > 0 }
+
+// Empty is an incremental backup taken when nothing had changed.
+func (i Info) Empty() bool { return i.Incremental() && i.Version < i.Since }
+
+// Describe says what a backup holds, for the CLI and the backups panel.
+func Describe(i Info) string {
+	what := fmt.Sprintf("Full backup of %d keys up to version %d", i.Keys, i.Version)
+	switch {
+	case i.Empty():
+		what = fmt.Sprintf("Incremental backup with no changes since version %d", i.Version)
+	case i.Incremental():
+		what = fmt.Sprintf("Incremental backup of %d keys, versions %d to %d", i.Keys, i.Since, i.Version)
+	}
+	if i.Prefix != "" {
+		what += fmt.Sprintf(" under %q", i.Prefix)
+	}
+	if i.Encrypted {
+		what += ", encrypted"
+	}
+	return what
+}
+
+// CatalogPath is where the catalog of the DB at dbPath lives, next to it.
+func CatalogPath(dbPath string) string {
+	return filepath.Clean(dbPath) + ".backups.jsonl"
+}
+
+// DefaultPath names a new backup of the DB at dbPath after the time.
+func DefaultPath(dbPath string, incremental bool, now time.Time) string {
+	kind := "full"
+	if incremental {
+		kind = "incr"
+	}
+	return fmt.Sprintf("%s-%s-%s.bak.gz", filepath.Clean(dbPath), now.Format("20060102-150405"), kind)
+}
+
+// Options says what a backup covers.
+type Options struct {
+	Prefix string
+	// Incremental carries on from the latest backup in the catalog with the
+	// same prefix. Since, when set, gives the first version explicitly.
+	Incremental bool
+	Since       uint64
+	Gzip        bool // also chosen by a .gz extension
+	// Key is the encryption key of an encrypted DB; the backup is sealed
+	// with it.
+	Key []byte
+}
+
+// Create writes a backup to a new file at path and adds it to the catalog,
+// unless catalog is empty. A failed or canceled backup removes the file.
+func Create(ctx context.Context, src Source, catalog, path string, o Options, p *store.Progress) (Info, error) {
+	since := o.Since
+	if since == 0 && o.Incremental {
+		last, ok, err := Latest(catalog, o.Prefix)
+		if err != nil {
+			return Info{}, err
+		}
+		if !ok {
+			return Info{}, fmt.Errorf("no earlier backup of prefix %q to carry on from", o.Prefix)
+		}
+		since = last.Version + 1
+	}
+	gz := o.Gzip || strings.EqualFold(filepath.Ext(path), ".gz")
+
+	// I never overwrite a file; it may well be an older backup.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Info{}, err
+	}
+	version, keys, err := write(ctx, src, f, since, o.Prefix, gz, o.Key, p)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	var size int64
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = os.Stat(path); err == nil {
+			size = fi.Size()
+		}
+	}
+	if err != nil {
+		os.Remove(path)
+		return Info{}, err
+	}
+	if version == 0 && since > 0 {
+		// Nothing changed; the next incremental starts at the same place.
+		version = since - 1
+	}
+	abs, _ := filepath.Abs(path)
+	info := Info{Path: abs, At: time.Now().UTC(), Since: since, Version: version, Prefix: o.Prefix, Keys: keys, Size: size, Gzip: gz, Encrypted: len(o.Key) > 0}
+	if catalog == "" {
+		return info, nil
+	}
+	if err := appendCatalog(catalog, info); err != nil {
+		return info, fmt.Errorf("backup written but not cataloged: %w", err)
+	}
+	return info, nil
+}
+
+// write compresses before it seals, as sealed bytes do not compress.
+func write(ctx context.Context, src Source, f *os.File, since uint64, prefix string, gz bool, key []byte, p *store.Progress) (uint64, int, error) {
+	bw := bufio.NewWriterSize(f, 1<<20)
+	var w io.Writer = bw
+	var sw *sealWriter
+	if len(key) > 0 {
+		var err error
+		if sw, err = newSealWriter(bw, key); err != nil {
+			return 0, 0, err
+		}
+		w = sw
+	}
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	version, keys, err := src.Backup(ctx, w, since, prefix, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if sw != nil {
+		if err := sw.Close(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return version, keys, bw.Flush()
+}
+
+// Restore loads backups in order, a full one and then the incremental ones
+// after it, into an empty DB; force allows a DB that has keys. key opens
+// sealed backups. A file the audit log missed is loaded all the same, so I
+// carry on and report that at the end.
+func Restore(ctx context.Context, dst Target, paths []string, force bool, key []byte) error {
+	var notLogged error
+	for i, path := range paths {
+		abs, _ := filepath.Abs(path)
+		err := withReader(path, key, func(r io.Reader) error {
+			return dst.LoadBackup(ctx, r, abs, force || i > 0)
+		})
+		if errors.Is(err, store.ErrNotAudited) {
+			if notLogged == nil {
+				notLogged = fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return notLogged
+}
+
+// Verify loads the backup at path into a throwaway in-memory DB and
+// returns the live keys it gives, with what the catalog recorded for it
+// when the catalog knows the file. key opens a sealed backup.
+func Verify(ctx context.Context, catalog, path string, key []byte) (keys int, recorded Info, known bool, err error) {
+	err = withReader(path, key, func(r io.Reader) error {
+		keys, err = store.VerifyBackup(ctx, r)
+		return err
+	})
+	if err != nil {
+		return 0, Info{}, false, err
+	}
+	abs, _ := filepath.Abs(path)
+	infos, err := Catalog(catalog)
+	if err != nil {
+		return keys, Info{}, false, err
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if infos[i].Path == abs {
+			return keys, infos[i], true, nil
+		}
+	}
+	return keys, Info{}, false, nil
+}
+
+// withReader opens a backup, sealed or not and gzip compressed or not,
+// whatever its name.
+func withReader(path string, key []byte, fn func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReaderSize(f, 1<<20)
+	if sealed(br) {
+		sr, err := newSealReader(br, key)
+		if err != nil {
+			return err
+		}
+		br = bufio.NewReaderSize(sr, 1<<20)
+	}
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return fn(br)
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return fn(zr)
+}
+
+// Catalog lists the backups recorded at path, oldest first. A missing
+// catalog has none.
+func Catalog(path string) ([]Info, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var infos []Info
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var info Info
+		if err := json.Unmarshal(sc.Bytes(), &info); err != nil {
+			return nil, fmt.Errorf("backup catalog %s line %d: %w", path, n, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, sc.Err()
+}
+
+// Latest is the newest backup of prefix in the catalog.
+func Latest(catalog, prefix string) (Info, bool, error) {
+	infos, err := Catalog(catalog)
+	if err != nil {
+		return Info{}, false, err
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if infos[i].Prefix == prefix {
+			return infos[i], true, nil
+		}
+	}
+	return Info{}, false, nil
+}
+
+// Chain is the backups to restore for the one at path: the full backup
+// it builds on and the incremental ones in between, oldest first.
+func Chain(catalog, path string) ([]Info, error) {
+	infos, err := Catalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+	abs, _ := filepath.Abs(path)
+	end := -1
+	for i, info := range infos {
+		if info.Path == abs {
+			end = i
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("%s is not in the backup catalog %s", path, catalog)
+	}
+	chain := []Info{infos[end]}
+	for i := end - 1; i >= 0 && chain[0].Incremental(); i-- {
+		if infos[i].Prefix == chain[0].Prefix && infos[i].Version+1 == chain[0].Since {
+			chain = append([]Info{infos[i]}, chain...)
+		}
+	}
+	if chain[0].Incremental() {
+		return nil, fmt.Errorf("the full backup under %s is missing from the catalog", path)
+	}
+	return chain, nil
+}
+
+func appendCatalog(path string, info Info) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
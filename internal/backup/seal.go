@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A backup of an encrypted DB is sealed with the DB's key, so it does not
+// put the values on disk in the clear. The file starts with sealMagic and a
+// random salt, from which each file gets its own AES-GCM key; then come the
+// chunks, each a 4-byte big-endian length and the sealed chunk. A chunk's
+// nonce is its number, and the last one has the high bit of its length
+// set and is sealed with that in its additional data, so a file cut short
+// or with chunks moved around does not open.
+const (
+	sealMagic = "BGUIBAK\x01"
+	saltSize  = 16
+	sealChunk = 64 << 10
+	lastChunk = 1 << 31
+)
+
+// ErrEncrypted is returned when a sealed backup is read without a key.
+var ErrEncrypted = errors.New("backup is encrypted: open the database with its encryption key")
+
+var errSeal = errors.New("backup is damaged or sealed with another key")
+
+func fileAEAD(key, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("badger-gui backup"))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil)[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type sealWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	count uint64
+}
+
+func newSealWriter(w io.Writer, key []byte) (*sealWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := fileAEAD(key, salt)
+	if err != nil {
+		return nil, fmt.Errorf("backup key: %w", err)
+	}
+	if _, err := io.WriteString(w, sealMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &sealWriter{w: w, aead: aead, buf: make([]byte, 0, sealChunk)}, nil
+}
+
+// Write holds a full chunk back until more comes, as only Close knows
+// which chunk is the last.
+func (s *sealWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(s.buf) == sealChunk {
+			if err := s.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		k := copy(s.buf[len(s.buf):sealChunk], p)
+		s.buf = s.buf[:len(s.buf)+k]
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Close seals the last chunk; it does not close the writer underneath.
+func (s *sealWriter) Close() error {
+	return s.flush(true)
+}
+
+func (s *sealWriter) flush(last bool) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], s.count)
+	s.count++
+	sealed := s.aead.Seal(nil, nonce, s.buf, chunkData(last))
+	size := uint32(len(sealed))
+	if last {
+		size |= lastChunk
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], size)
+	if _, err := s.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(sealed)
+	s.buf = s.buf[:0]
+	return err
+}
+
+func chunkData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type sealReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte // what is left of the current chunk
+	count uint64
+	done  bool
+}
+
+// sealed reports whether br starts like a sealed backup.
+func sealed(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(sealMagic))
+	return bytes.Equal(magic, []byte(sealMagic))
+}
+
+func newSealReader(r io.Reader, key []byte) (*sealReader, error) {
+	if len(key) == 0 {
+		return nil, ErrEncrypted
+	}
+	head := make([]byte, len(sealMagic)+saltSize)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, cutShort(err)
+	}
+	aead, err := fileAEAD(key, head[len(sealMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("backup key: %w", err)
+	}
+	return &sealReader{r: r, aead: aead}, nil
+}
+
+func (s *sealReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *sealReader) next() error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		return cutShort(err)
+	}
+	size := binary.BigEndian.Uint32(hdr[:])
+	last := size&lastChunk != 0
+	size &^= lastChunk
+	if size > sealChunk+uint32(s.aead.Overhead()) {
+		return errSeal
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(s.r, chunk); err != nil {
+		return cutShort(err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], s.count)
+	s.count++
+	plain, err := s.aead.Open(chunk[:0], nonce, chunk, chunkData(last))
+	if err != nil {
+		return errSeal
+	}
+	s.buf, s.done = plain, last
+	return nil
+}
+
+func cutShort(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errSeal
+	}
+	return err
+}
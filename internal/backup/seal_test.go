@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func seal(t *testing.T, key, data []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newSealWriter(&out, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd-sized writes, so chunks are filled across them.
+	for len(data) > 0 {
+		n := min(len(data), 10007)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func unseal(key, file []byte) ([]byte, error) {
+	br := bufio.NewReader(bytes.NewReader(file))
+	if !sealed(br) {
+		return nil, errors.New("not sealed")
+	}
+	r, err := newSealReader(br, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	for _, size := range []int{0, 1, sealChunk, 2 * sealChunk, 3*sealChunk + 123} {
+		data := make([]byte, size)
+		rand.Read(data)
+		file := seal(t, key, data)
+		got, err := unseal(key, file)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes: got %d bytes back, not the same", size, len(got))
+		}
+	}
+}
+
+func TestSealRejects(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+	data := make([]byte, 2*sealChunk+500)
+	rand.Read(data)
+	file := seal(t, key, data)
+	head := len(sealMagic) + saltSize
+	firstChunk := head + 4 + sealChunk + 16
+
+	other := bytes.Clone(key)
+	other[0] ^= 1
+	if _, err := unseal(other, file); !errors.Is(err, errSeal) {
+		t.Errorf("another key: %v, want errSeal", err)
+	}
+	if _, err := unseal(nil, file); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("no key: %v, want ErrEncrypted", err)
+	}
+	// Cut after a whole chunk, with and without the last-chunk bit set.
+	cut := bytes.Clone(file[:firstChunk])
+	if _, err := unseal(key, cut); !errors.Is(err, errSeal) {
+		t.Errorf("cut short: %v, want errSeal", err)
+	}
+	cut[head] |= 0x80
+	if _, err := unseal(key, cut); !errors.Is(err, errSeal) {
+		t.Errorf("cut short and marked last: %v, want errSeal", err)
+	}
+	flipped := bytes.Clone(file)
+	flipped[head+100] ^= 1
+	if _, err := unseal(key, flipped); !errors.Is(err, errSeal) {
+		t.Errorf("flipped bit: %v, want errSeal", err)
+	}
+}
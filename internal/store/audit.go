@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Audit operations, one per key a change touched.
+const (
+	AuditSet           = "set"
+	AuditDelete        = "delete"
+	AuditPatternDelete = "pattern delete"
+	AuditRestore       = "restore"
+	AuditImport        = "import"
+	// AuditDropPrefix is one entry for a pattern delete that dropped a
+	// whole prefix; Key holds the prefix.
+	AuditDropPrefix = "drop prefix"
+	// AuditRestoreBackup is one entry for a backup file loaded into the DB.
+	AuditRestoreBackup = "restore backup"
+)
+
+// AuditPath is the default audit log of the DB at dbPath, next to it.
+func AuditPath(dbPath string) string {
+	return filepath.Clean(dbPath) + ".audit.jsonl"
+}
+
+// AuditEntry is one line of the audit log. Values are only described by
+// size and SHA-256; a nil size means the key did not exist on that side.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	DB   string    `json:"db"`
+	Op   string    `json:"op"`
+	// Key is set when the key is valid UTF-8, KeyHex otherwise, so binary
+	// keys survive JSON.
+	Key       string `json:"key,omitempty"`
+	KeyHex    string `json:"key_hex,omitempty"`
+	OldSize   *int   `json:"old_size,omitempty"`
+	OldSHA256 string `json:"old_sha256,omitempty"`
+	NewSize   *int   `json:"new_size,omitempty"`
+	NewSHA256 string `json:"new_sha256,omitempty"`
+	// Keys is how many keys a drop prefix entry stands for, or how many
+	// entries, deletes included, a restored backup held.
+	Keys int `json:"keys,omitempty"`
+	// A restore backup entry names the File, the versions it held and how
+	// many live keys the DB had before.
+	File         string `json:"file,omitempty"`
+	MinVersion   uint64 `json:"min_version,omitempty"`
+	MaxVersion   uint64 `json:"max_version,omitempty"`
+	ExistingKeys int    `json:"existing_keys,omitempty"`
+}
+
+// RawKey returns the key the entry is about, whichever field holds it.
+func (e AuditEntry) RawKey() string {
+	if e.KeyHex != "" {
+		b, err := hex.DecodeString(e.KeyHex)
+		if err == nil {
+			return string(b)
+		}
+	}
+	return e.Key
+}
+
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	db   string
+	user string
+}
+
+func openAudit(path, db string) *auditLog {
+	if path == "" {
+		return nil
+	}
+	return &auditLog{path: path, db: db, user: osUser()}
+}
+
+func osUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// auditChange describes one key a change touched: old is its state before,
+// and exists says whether newValue is there afterwards.
+func auditChange(op string, old TrashEntry, newValue []byte, exists bool) AuditEntry {
+	e := AuditEntry{Op: op}
+	if utf8.ValidString(old.Key) {
+		e.Key = old.Key
+	} else {
+		e.KeyHex = hex.EncodeToString([]byte(old.Key))
+	}
+	if old.Existed {
+		e.OldSize, e.OldSHA256 = describeValue(old.Value)
+	}
+	if exists {
+		e.NewSize, e.NewSHA256 = describeValue(newValue)
+	}
+	return e
+}
+
+// auditDrop describes a prefix drop as a whole: hashing each key it took
+// would mean holding all of them until the drop went through.
+func auditDrop(prefix string, keys int) AuditEntry {
+	e := auditChange(AuditDropPrefix, TrashEntry{Key: prefix}, nil, false)
+	e.Keys = keys
+	return e
+}
+
+func describeValue(v []byte) (*int, string) {
+	n := len(v)
+	sum := sha256.Sum256(v)
+	return &n, hex.EncodeToString(sum[:])
+}
+
+// audit appends entries once their change has committed. Without a log it
+// does nothing.
+func (s *BadgerStore) audit(entries ...AuditEntry) error {
+	a := s.auditLog
+	if a == nil || len(entries) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	now := time.Now().UTC()
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		e.Time, e.User, e.DB = now, a.user, a.db
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return fmt.Errorf("audit log: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// ErrNotAudited is wrapped by the error of a change that committed but could
+// not be written to the audit log: the change itself went through.
+var ErrNotAudited = errors.New("change committed but not logged")
+
+func auditErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrNotAudited, err)
+}
+
+// ReadAuditLog calls fn for each entry of the log at path, oldest first,
+// until fn returns an error. A line cut short by a crash is skipped.
+func ReadAuditLog(path string, fn func(AuditEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	defer f.Close()
+	rd := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 1 && line[len(line)-1] == '\n' {
+			var e AuditEntry
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				return fmt.Errorf("audit log %s line %d: %w", path, n, jerr)
+			}
+			if ferr := fn(e); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("audit log: %w", err)
+		}
+	}
+}
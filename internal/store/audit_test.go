@@ -0,0 +1,26 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+// A change that commits stays made when the audit log cannot be written;
+// the error says so, so callers do not report it as failed.
+func TestAuditFailureKeepsTheChange(t *testing.T) {
+	st := openJournaled(t)
+	// A directory cannot be opened for appending.
+	st.auditLog = openAudit(t.TempDir(), "test")
+
+	err := st.Set("k", []byte("v"))
+	if !errors.Is(err, ErrNotAudited) {
+		t.Fatalf("Set returned %v, want ErrNotAudited", err)
+	}
+	if v, err := st.Get("k"); err != nil || string(v) != "v" {
+		t.Errorf("k = %q, %v; want v", v, err)
+	}
+	done, err := st.Undo()
+	if !errors.Is(err, ErrNotAudited) || len(done) != 1 {
+		t.Fatalf("Undo restored %d entries and returned %v, want 1 and ErrNotAudited", len(done), err)
+	}
+}
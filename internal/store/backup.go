@@ -0,0 +1,204 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotEmpty is returned by LoadBackup when the DB already has keys.
+// Loaded entries keep their versions, so newer ones in the DB would hide
+// them; a backup is restored into a new, empty directory.
+var ErrNotEmpty = errors.New("database is not empty: restore into a new directory")
+
+// loadPendingWrites is how many writes db.Load keeps in flight.
+const loadPendingWrites = 256
+
+// Backup writes every key under prefix with a version of at least since,
+// deletes included, in Badger's backup format. since 0 is a full backup.
+// It returns the highest version written, from which the next incremental
+// backup carries on, and how many live keys it holds. p counts keys read.
+func (s *BadgerStore) Backup(ctx context.Context, w io.Writer, since uint64, prefix string, p *Progress) (uint64, int, error) {
+	var live atomic.Int64
+	stream := s.db.NewStream()
+	stream.NumGo = s.scanWorkers
+	stream.Prefix = []byte(prefix)
+	if since > 0 {
+		// The iterator reads versions above SinceTs, not from it.
+		stream.SinceTs = since - 1
+	}
+	stream.LogPrefix = "badger-gui.backup"
+	stream.ChooseKey = func(item *badger.Item) bool {
+		p.Add(1)
+		if !item.IsDeletedOrExpired() {
+			live.Add(1)
+		}
+		return true
+	}
+	// Stream.Backup runs on a background context; I stop it by failing
+	// its next write.
+	version, err := stream.Backup(ctxWriter{ctx, w}, since)
+	return version, int(live.Load()), err
+}
+
+// LoadBackup loads a backup, or an incremental one on top of the ones
+// before it, into an empty DB. The entries keep their versions, TTLs and
+// meta; they bypass the undo journal, and the audit log gets one entry for
+// the whole file, named name.
+func (s *BadgerStore) LoadBackup(ctx context.Context, r io.Reader, name string, allowExisting bool) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	existing, err := countLive(s.db)
+	if err != nil {
+		return err
+	}
+	if existing > 0 && !allowExisting {
+		return ErrNotEmpty
+	}
+	// I pass the stream on to db.Load frame by frame, counting the entries
+	// and versions on the way.
+	var loaded loadStats
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(loaded.copy(pw, ctxReader{ctx, r}))
+	}()
+	err = s.db.Load(pr, loadPendingWrites)
+	pr.CloseWithError(errors.New("load stopped"))
+	<-done
+	if err != nil {
+		return err
+	}
+	e := AuditEntry{Op: AuditRestoreBackup, File: name, Keys: loaded.entries, ExistingKeys: existing}
+	e.MinVersion, e.MaxVersion = loaded.minVersion, loaded.maxVersion
+	return auditErr(s.audit(e))
+}
+
+type loadStats struct {
+	entries    int
+	minVersion uint64
+	maxVersion uint64
+}
+
+// copy copies the frames of a backup from r to w, each a little-endian
+// length and a KVList, as db.Load reads them.
+func (l *loadStats) copy(w io.Writer, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var hdr [8]byte
+	var buf []byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint64(hdr[:])
+		if uint64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return err
+		}
+		var list pb.KVList
+		if err := proto.Unmarshal(buf, &list); err != nil {
+			return err
+		}
+		for _, kv := range list.Kv {
+			if l.entries == 0 || kv.Version < l.minVersion {
+				l.minVersion = kv.Version
+			}
+			l.maxVersion = max(l.maxVersion, kv.Version)
+			l.entries++
+		}
+		if _, err := w.Write(hdr[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// OpenRestoreTarget opens a new DB at dir to restore a backup into, with
+// the tuning and encryption key this one was opened with but writable and
+// without undo journal. The restore goes to this DB's audit log, under the
+// new DB's path. dir must be missing or empty.
+func (s *BadgerStore) OpenRestoreTarget(dir string) (*BadgerStore, error) {
+	if names, err := os.ReadDir(dir); err == nil && len(names) > 0 {
+		return nil, ErrNotEmpty
+	}
+	o := s.opts
+	o.ReadOnly, o.InMemory = false, false
+	o.TrashPath = ""
+	return OpenBadger(dir, o)
+}
+
+// EncryptionKey is the key the DB was opened with, which also seals its
+// backups; nil when it is not encrypted.
+func (s *BadgerStore) EncryptionKey() []byte {
+	return s.opts.EncryptionKey
+}
+
+// VerifyBackup loads a backup into a throwaway in-memory DB and counts the
+// live keys it gives.
+func VerifyBackup(ctx context.Context, r io.Reader) (int, error) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := db.Load(ctxReader{ctx, r}, loadPendingWrites); err != nil {
+		return 0, err
+	}
+	return countLive(db)
+}
+
+func countLive(db *badger.DB) (int, error) {
+	n := 0
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c ctxWriter) Write(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(b)
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
@@ -2,79 +2,141 @@ package store
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
-	"unicode"
-	"unicode/utf8"
+	"sync"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/dgraph-io/badger/v4/options"
 )
 
+// ErrKeyNotFound is returned by Get when the key has no live value.
+var ErrKeyNotFound = badger.ErrKeyNotFound
+
+// ErrReadOnly is returned by mutations when the store was opened without write access.
+var ErrReadOnly = errors.New("database is opened read-only (start with --write to allow changes)")
+
+// ErrLocked is returned by OpenBadger when another process holds the DB's
+// directory lock.
+var ErrLocked = errors.New("database is locked by another process")
+
+func isLockError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Cannot acquire directory lock")
+}
+
 type BadgerStore struct {
-	db *badger.DB
+	db          *badger.DB
+	readOnly    bool
+	scanWorkers int
+	trash       *trashJournal // nil when there is no undo journal
+	auditLog    *auditLog     // nil when changes are not logged
+	opts        Options       // as opened, for OpenRestoreTarget
+	tempDir     string        // a checkpoint copy, removed on Close
+
+	snapMu sync.Mutex
+	snap   *snapshot // nil unless a snapshot is pinned
 }
 
-func OpenBadger(path string) (*BadgerStore, error) {
-	opts := badger.DefaultOptions(path)
+func OpenBadger(path string, o Options) (*BadgerStore, error) {
+	opts, err := o.badgerOptions(path)
+	if err != nil {
+		return nil, err
+	}
 
-	opts.SyncWrites = false // I disable sync writes for maximum throughput.
-	opts.NumMemtables = 5
-	opts.NumLevelZeroTables = 5
-	opts.NumLevelZeroTablesStall = 10
-	opts.ValueLogFileSize = 1 << 30 // I set the value log file size to 1GB.
-	opts.ValueLogMaxEntries = 1000000
-	opts.NumCompactors = 4            // I tune this for available CPU.
-	opts.Compression = options.Snappy // I prefer Snappy; ZSTD costs more CPU.
-	opts.BlockCacheSize = 512 << 20   // I set the block cache to 512MB.
-	opts.IndexCacheSize = 256 << 20
+	trash, err := openTrash(o.TrashPath, o.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := badger.Open(opts)
 	if err != nil {
-		return nil, err
+		return nil, openError(err, o)
 	}
 
-	return &BadgerStore{db: db}, nil
+	label := "(in-memory)"
+	if !o.InMemory {
+		label, _ = filepath.Abs(path)
+	}
+	return &BadgerStore{
+		db:          db,
+		readOnly:    o.ReadOnly,
+		scanWorkers: scanWorkers(o.ScanWorkers),
+		trash:       trash,
+		auditLog:    openAudit(o.AuditPath, label),
+		opts:        o,
+	}, nil
 }
 
 func (s *BadgerStore) Close() error {
-	return s.db.Close()
+	s.Unpin()
+	err := s.db.Close()
+	if s.tempDir != "" {
+		if rerr := os.RemoveAll(s.tempDir); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// ReadOnly reports whether Set and Delete are disabled.
+func (s *BadgerStore) ReadOnly() bool {
+	return s.readOnly
+}
+
+// KeyQuery narrows ListKeys; the zero value lists every key in ascending order.
+type KeyQuery struct {
+	Prefix   string
+	HasMeta  bool
+	UserMeta byte
+	Reverse  bool
+}
+
+func (q KeyQuery) match(item *badger.Item) bool {
+	return !q.HasMeta || item.UserMeta() == q.UserMeta
 }
 
 func (s *BadgerStore) ListKeysPage(startAfter string, limit int) ([]string, string, bool, error) {
+	return s.ListKeys(KeyQuery{}, startAfter, limit)
+}
+
+// ListKeys pages keys in the query's order, starting after startAfter.
+func (s *BadgerStore) ListKeys(q KeyQuery, startAfter string, limit int) ([]string, string, bool, error) {
+	return s.listKeys(q, startAfter, false, limit)
+}
+
+// ListKeysFrom pages keys in the query's order starting at from itself (or
+// the nearest key past it), so a seek lands on the key when it exists.
+func (s *BadgerStore) ListKeysFrom(q KeyQuery, from string, limit int) ([]string, string, bool, error) {
+	return s.listKeys(q, from, true, limit)
+}
+
+func (s *BadgerStore) listKeys(q KeyQuery, start string, inclusive bool, limit int) ([]string, string, bool, error) {
 	if limit <= 0 {
 		return nil, "", false, nil
 	}
 	var keys []string
 	var lastKey string
 	var hasMore bool
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
+	prefix := []byte(q.Prefix)
+	err := s.view(func(txn *badger.Txn) error {
+		it := newKeyIterator(txn, q, start, inclusive)
 		defer it.Close()
 
-		if startAfter == "" {
-			it.Rewind()
-		} else {
-			after := []byte(startAfter)
-			it.Seek(after)
-			if it.Valid() && bytes.Equal(it.Item().Key(), after) {
+		for it.ValidForPrefix(prefix) {
+			item := it.Item()
+			if !q.match(item) {
 				it.Next()
+				continue
 			}
-		}
-
-		for it.Valid() {
-			item := it.Item()
-			key := string(item.KeyCopy(nil))
-			keys = append(keys, key)
-			lastKey = key
 			if len(keys) >= limit {
-				it.Next()
-				if it.Valid() {
-					hasMore = true
-				}
+				hasMore = true
 				break
 			}
+			key := string(item.KeyCopy(nil))
+			keys = append(keys, key)
+			lastKey = key
 			it.Next()
 		}
 		return nil
@@ -82,43 +144,76 @@ func (s *BadgerStore) ListKeysPage(startAfter string, limit int) ([]string, stri
 	return keys, lastKey, hasMore, err
 }
 
-func (s *BadgerStore) CountKeysMatching(term string) (int, error) {
-	pattern := strings.TrimSpace(term)
-	if pattern == "" {
-		return 0, nil
+// newKeyIterator opens a key-only iterator over q and puts it on start, or
+// just past it when inclusive is false. An empty start is the beginning of
+// the query in its order.
+func newKeyIterator(txn *badger.Txn, q KeyQuery, start string, inclusive bool) *badger.Iterator {
+	prefix := []byte(q.Prefix)
+	end := prefixSuccessor(prefix)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = q.Reverse
+	if !q.Reverse {
+		// Backwards I leave the prefix off: the seek lands on end, outside
+		// it, and the iterator would call that the end of the keys. Callers
+		// stop at the prefix themselves.
+		opts.Prefix = prefix
 	}
-	pr := []rune(pattern)
-	count := 0
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.Valid(); it.Next() {
-			key := string(it.Item().Key())
-			if fuzzyMatch(pr, key) {
-				count++
+	it := txn.NewIterator(opts)
+
+	from := []byte(start)
+	switch {
+	case !q.Reverse && bytes.Compare(from, prefix) < 0:
+		it.Seek(prefix)
+	case q.Reverse && (start == "" || end != nil && bytes.Compare(from, end) >= 0):
+		if end == nil {
+			// No key sorts past the prefix, so I start at the very last one.
+			it.Rewind()
+		} else {
+			// I step over end itself; a reverse seek lands on the last key <= end.
+			it.Seek(end)
+			if it.Valid() && bytes.Equal(it.Item().Key(), end) {
+				it.Next()
 			}
 		}
-		return nil
-	})
-	return count, err
+	default:
+		it.Seek(from)
+		if !inclusive && it.Valid() && bytes.Equal(it.Item().Key(), from) {
+			it.Next()
+		}
+	}
+	return it
 }
 
-func (s *BadgerStore) GroupKeyCounts() (map[string]int, error) {
-	counts := make(map[string]int)
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.Valid(); it.Next() {
-			key := string(it.Item().Key())
-			group := keyGroup(key)
-			counts[group]++
+// CountKeysMatching counts the keys of q the matcher accepts.
+func (s *BadgerStore) CountKeysMatching(ctx context.Context, q KeyQuery, m Matcher, p *Progress) (int, error) {
+	counts := make([]int, s.scanWorkers)
+	err := s.scanKeys(ctx, []byte(q.Prefix), p, func(worker int, item *badger.Item) {
+		if q.match(item) && m.Match(string(item.Key())) {
+			counts[worker]++
 		}
-		return nil
 	})
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total, err
+}
+
+func (s *BadgerStore) GroupKeyCounts(ctx context.Context, p *Progress) (map[string]int, error) {
+	perWorker := make([]map[string]int, s.scanWorkers)
+	for i := range perWorker {
+		perWorker[i] = make(map[string]int)
+	}
+	err := s.scanKeys(ctx, nil, p, func(worker int, item *badger.Item) {
+		perWorker[worker][keyGroup(string(item.Key()))]++
+	})
+	counts := perWorker[0]
+	for _, m := range perWorker[1:] {
+		for group, n := range m {
+			counts[group] += n
+		}
+	}
 	return counts, err
 }
 
@@ -132,70 +227,122 @@ func keyGroup(key string) string {
 	return "(no prefix)"
 }
 
-func fuzzyMatch(pattern []rune, target string) bool {
-	if len(pattern) == 0 {
-		return true
-	}
-	pi := 0
-	for _, r := range target {
-		if equalFold(r, pattern[pi]) {
-			pi++
-			if pi == len(pattern) {
-				return true
-			}
-		}
-	}
-	return false
+// Entry is a value together with the per-entry attributes Badger stores next to it.
+type Entry struct {
+	Value     []byte
+	ExpiresAt uint64 // unix seconds, 0 when the entry has no TTL
+	UserMeta  byte
 }
 
-// I lifted this from strings.EqualFold.
-func equalFold(tr, sr rune) bool {
-	if tr == sr {
-		return true
-	}
-	if tr < sr {
-		tr, sr = sr, tr
-	}
-	// I fast-path ASCII.
-	if tr < utf8.RuneSelf {
-		// I normalize ASCII case by comparing lower/upper pairs.
-		if 'A' <= sr && sr <= 'Z' && tr == sr+'a'-'A' {
-			return true
-		}
-		return false
-	}
-
-	// I fall back to SimpleFold for the general case, which cycles equivalents.
-	r := unicode.SimpleFold(sr)
-	for r != sr && r < tr {
-		r = unicode.SimpleFold(r)
-	}
-	return r == tr
+// SetOptions says whether a write keeps the stored TTL and UserMeta or
+// replaces them with ExpiresAt (0 clears the TTL) and UserMeta.
+type SetOptions struct {
+	KeepTTL   bool
+	ExpiresAt uint64
+	KeepMeta  bool
+	UserMeta  byte
 }
 
 func (s *BadgerStore) Get(key string) ([]byte, error) {
-	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	e, err := s.GetEntry(key)
+	return e.Value, err
+}
+
+func (s *BadgerStore) GetEntry(key string) (Entry, error) {
+	var out Entry
+	err := s.view(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(key))
 		if err != nil {
 			return err
 		}
+		out.ExpiresAt = item.ExpiresAt()
+		out.UserMeta = item.UserMeta()
 		return item.Value(func(v []byte) error {
-			out = append(out, v...)
+			out.Value = append(out.Value, v...)
 			return nil
 		})
 	})
 	return out, err
 }
 
+// Set replaces the value and keeps the TTL and UserMeta the key already has.
 func (s *BadgerStore) Set(key string, value []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(key), value)
+	return s.SetWith(key, value, SetOptions{KeepTTL: true, KeepMeta: true})
+}
+
+// SetWith journals the key's previous state before writing it.
+func (s *BadgerStore) SetWith(key string, value []byte, o SetOptions) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	var old TrashEntry
+	var captured []int
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		if old, err = s.set(txn, key, value, o); err != nil {
+			return err
+		}
+		captured, err = s.capture(TrashSave, old)
+		return err
 	})
+	if err != nil {
+		return s.discard(captured, err)
+	}
+	return auditErr(s.audit(auditChange(AuditSet, old, value, true)))
+}
+
+// set writes the key in txn and returns its state from before.
+func (s *BadgerStore) set(txn *badger.Txn, key string, value []byte, o SetOptions) (TrashEntry, error) {
+	e := badger.NewEntry([]byte(key), value).WithMeta(o.UserMeta)
+	e.ExpiresAt = o.ExpiresAt
+	old, err := oldState(txn, key)
+	if err != nil {
+		return old, err
+	}
+	if old.Existed {
+		if o.KeepTTL {
+			e.ExpiresAt = old.ExpiresAt
+		}
+		if o.KeepMeta {
+			e.UserMeta = old.UserMeta
+		}
+	} else {
+		// I write a new key with no TTL and zero meta unless told otherwise.
+		if o.KeepTTL {
+			e.ExpiresAt = 0
+		}
+		if o.KeepMeta {
+			e.UserMeta = 0
+		}
+	}
+	return old, txn.SetEntry(e)
 }
 
+// Delete journals the key's value, TTL and meta before deleting it.
 func (s *BadgerStore) Delete(key string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(key))
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	var old TrashEntry
+	var captured []int
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		if old, err = s.delete(txn, key); err != nil || !old.Existed {
+			return err
+		}
+		captured, err = s.capture(TrashDelete, old)
+		return err
 	})
+	if err != nil {
+		return s.discard(captured, err)
+	}
+	return auditErr(s.audit(auditChange(AuditDelete, old, nil, false)))
+}
+
+func (s *BadgerStore) delete(txn *badger.Txn, key string) (TrashEntry, error) {
+	old, err := oldState(txn, key)
+	if err != nil {
+		return old, err
+	}
+	return old, txn.Delete([]byte(key))
 }
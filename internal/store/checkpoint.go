@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// OpenCheckpoint opens a private copy of the DB at path, so a DB another
+// process holds the lock on can still be read. The copy lives in a
+// temporary directory that Close removes. Tables and value log files that
+// no longer change are hard-linked when the file system allows it; the
+// MANIFEST, the key registry, the memtable logs and the value log being
+// written are copied. The store is read-only, without undo journal or
+// audit log.
+//
+// The copy is opened writable underneath: a live writer preallocates its
+// logs, and Badger only trims their unwritten tails when it may write. A
+// writable open must never delete a file it shares with the original, as
+// Badger truncates a file before removing it: compaction is off, and the
+// value logs it would drop on open are copied rather than linked.
+func OpenCheckpoint(path string, o Options) (*BadgerStore, error) {
+	if o.InMemory {
+		return nil, fmt.Errorf("an in-memory DB has nothing to copy")
+	}
+	dir, err := os.MkdirTemp("", "badger-gui-snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkpoint(path, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("copy %s: %w", path, err)
+	}
+	o.ReadOnly = false
+	o.frozen = true
+	o.TrashPath, o.AuditPath = "", ""
+	st, err := OpenBadger(dir, o)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	st.readOnly = true
+	st.tempDir = dir
+	return st, nil
+}
+
+// checkpoint fills dir from src in the order that keeps the copy whole
+// while src is being written: memtable logs first, so a memtable flushed
+// meanwhile is in the tables; tables on both sides of the MANIFEST, so
+// every table it lists is there; value logs last, so they hold every
+// value the rest points at. A table the MANIFEST no longer lists is
+// deleted by Badger on open.
+func checkpoint(src, dir string) error {
+	if _, err := os.Stat(filepath.Join(src, "MANIFEST")); err != nil {
+		return fmt.Errorf("not a Badger directory: %w", err)
+	}
+	steps := []func() error{
+		func() error { return linkAll(src, dir, ".sst") },
+		func() error { return copyAll(src, dir, ".mem") },
+		func() error { return copyNamed(src, dir, "MANIFEST", "KEYREGISTRY") },
+		func() error { return linkAll(src, dir, ".sst") },
+		func() error { return copyValueLogs(src, dir) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func filesWithExt(dir, ext string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ext {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// linkAll hard-links the files with ext that dir does not have yet, or has
+// a copy of that was taken while the file was still being written. A file
+// that is gone by the time it is linked was compacted away.
+func linkAll(src, dir, ext string) error {
+	names, err := filesWithExt(src, ext)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		from, to := filepath.Join(src, name), filepath.Join(dir, name)
+		if have, err := os.Stat(to); err == nil {
+			if want, err := os.Stat(from); err != nil || os.SameFile(have, want) || have.Size() == want.Size() {
+				continue
+			}
+			if err := os.Remove(to); err != nil {
+				return err
+			}
+		}
+		if err := linkOrCopy(from, to); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyAll(src, dir, ext string) error {
+	names, err := filesWithExt(src, ext)
+	if err != nil {
+		return err
+	}
+	return copyNamed(src, dir, names...)
+}
+
+// copyNamed copies the files that exist of names.
+func copyNamed(src, dir string, names ...string) error {
+	for _, name := range names {
+		if err := copyFile(filepath.Join(src, name), filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// vlogHeaderSize is the size of an empty value log (vlogHeaderSize in
+// badger's value.go); Badger deletes such a file on a writable open.
+const vlogHeaderSize = 20
+
+// copyValueLogs links the value log files that are full and copies the
+// newest, which the writer appends to and Badger trims on open, along with
+// the empty ones Badger deletes on open.
+func copyValueLogs(src, dir string) error {
+	names, err := filesWithExt(src, ".vlog")
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	last := len(names) - 1
+	for _, name := range names[:last] {
+		from, to := filepath.Join(src, name), filepath.Join(dir, name)
+		info, err := os.Stat(from)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil:
+			return err
+		case info.Size() <= vlogHeaderSize:
+			err = copyFile(from, to)
+		default:
+			err = linkOrCopy(from, to)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return copyNamed(src, dir, names[last])
+}
+
+func linkOrCopy(from, to string) error {
+	if err := os.Link(from, to); err == nil {
+		return nil
+	}
+	return copyFile(from, to)
+}
+
+// copyFile copies from to a new file, leaving runs of zeros as holes: the
+// logs a writer has open are preallocated far past what it has written.
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 1<<20)
+	var size int64
+	for {
+		n, rerr := io.ReadFull(in, buf)
+		if n > 0 && !allZero(buf[:n]) {
+			if _, err := out.WriteAt(buf[:n], size); err != nil {
+				out.Close()
+				return err
+			}
+		}
+		size += int64(n)
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			out.Close()
+			return rerr
+		}
+	}
+	if err := out.Truncate(size); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+var zeroBlock = make([]byte, 1<<20)
+
+func allZero(b []byte) bool {
+	return bytes.Equal(b, zeroBlock[:len(b)])
+}
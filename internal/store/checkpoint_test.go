@@ -0,0 +1,138 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// writeTables fills dir with many small L0 tables and values in the value
+// log, and leaves L0 over its compaction trigger, so a DB opened on it with
+// compactors would start compacting at once. Two opens without writes leave
+// a value log with only its header, which Badger deletes on a writable open.
+func writeTables(t *testing.T, dir string, keys int) {
+	t.Helper()
+	opts := badger.DefaultOptions(dir).WithLogger(nil).
+		WithMemTableSize(1 << 20).WithBaseTableSize(64 << 10).WithValueThreshold(1024).
+		WithNumCompactors(0).WithNumLevelZeroTables(1).WithNumLevelZeroTablesStall(100)
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < keys; i++ {
+		err := db.Update(func(txn *badger.Txn) error {
+			size := 600
+			if i%10 == 0 {
+				size = 4096
+			}
+			return txn.Set([]byte(fmt.Sprintf("k/%06d", i)), make([]byte, size))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		db, err := badger.Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fileSizes(t *testing.T, dir string) map[string]int64 {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := map[string]int64{}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); ext != ".sst" && ext != ".vlog" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[e.Name()] = info.Size()
+	}
+	return sizes
+}
+
+func countKeys(t *testing.T, st *BadgerStore) int {
+	t.Helper()
+	n := 0
+	err := st.view(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := it.Item().Value(func([]byte) error { return nil }); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestOpenCheckpointLeavesOriginalIntact(t *testing.T) {
+	const keys = 15000
+	dir := t.TempDir()
+	writeTables(t, dir, keys)
+	before := fileSizes(t, dir)
+	if len(before) < 4 {
+		t.Fatalf("want several tables to share, got %v", before)
+	}
+
+	o, err := ProfileOptions(ProfilePerformance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.LogLevel = "off"
+	st, err := OpenCheckpoint(dir, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyDir := st.tempDir
+	if got := countKeys(t, st); got != keys {
+		t.Errorf("copy has %d keys, want %d", got, keys)
+	}
+	// Long enough for compactions to have picked up the full L0.
+	time.Sleep(500 * time.Millisecond)
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(copyDir); !os.IsNotExist(err) {
+		t.Errorf("copy %s left behind: %v", copyDir, err)
+	}
+
+	after := fileSizes(t, dir)
+	for name, size := range before {
+		if after[name] != size {
+			t.Errorf("%s: size %d after the copy was used, was %d", name, after[name], size)
+		}
+	}
+	o.ReadOnly = true
+	orig, err := OpenBadger(dir, o)
+	if err != nil {
+		t.Fatalf("reopen original: %v", err)
+	}
+	defer orig.Close()
+	if got := countKeys(t, orig); got != keys {
+		t.Errorf("original has %d keys, want %d", got, keys)
+	}
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DeleteChunk is how many deletes go into one chunk at most. A chunk is
+// also cut before it outgrows a transaction (see txnFits) and is written
+// in a single one, so it commits all or nothing.
+const DeleteChunk = 1000
+
+// DeletePlan is what a pattern delete would remove, for the preview.
+type DeletePlan struct {
+	Total  int
+	Sample []string // the first matching keys in key order
+	// Drop is set when every key under Prefix matches, so the delete can
+	// drop the whole prefix instead of writing a tombstone per key.
+	Drop   bool
+	Prefix string
+}
+
+// DeleteSummary records how far a pattern delete got. Keys holds the keys
+// that were committed as deleted; after a prefix drop it is empty and
+// Prefix says what went.
+type DeleteSummary struct {
+	Keys    []string
+	Dropped bool
+	Prefix  string
+	Deleted int
+	Failed  int   // keys in chunks that did not commit
+	Err     error // the first failure, or ctx.Err() when canceled
+}
+
+// PlanDelete counts the keys m matches and keeps the first sample of them.
+func (s *BadgerStore) PlanDelete(ctx context.Context, m Matcher, sample int, p *Progress) (DeletePlan, error) {
+	prefix, literal := m.literalPrefix()
+	totals := make([]int, s.scanWorkers)
+	seen := make([]int, s.scanWorkers)
+	samples := make([][]string, s.scanWorkers)
+	// The plan is for a delete, which acts on the latest state.
+	err := s.scanLatest(ctx, []byte(prefix), p, func(worker int, item *badger.Item) {
+		seen[worker]++
+		key := string(item.Key())
+		if !m.Match(key) {
+			return
+		}
+		totals[worker]++
+		if sample == 0 {
+			return
+		}
+		// A worker gets its ranges in no particular order, so I keep its
+		// smallest keys rather than its first ones.
+		if samples[worker] = append(samples[worker], key); len(samples[worker]) > 2*sample {
+			samples[worker] = smallestKeys(samples[worker], sample)
+		}
+	})
+	plan := DeletePlan{Prefix: prefix}
+	all := 0
+	var keys []string
+	for w := range totals {
+		plan.Total += totals[w]
+		all += seen[w]
+		keys = append(keys, samples[w]...)
+	}
+	plan.Sample = smallestKeys(keys, sample)
+	plan.Drop = literal && prefix != "" && plan.Total == all
+	return plan, err
+}
+
+func smallestKeys(keys []string, n int) []string {
+	sort.Strings(keys)
+	return keys[:min(n, len(keys))]
+}
+
+// DeleteMatching deletes every key m matches. A pure prefix is dropped with
+// DropPrefix; anything else is deleted in chunks, and a chunk that fails is
+// counted in the summary while the rest carry on. Either way the keys go to
+// the undo journal first, as one change. p counts deleted keys.
+//
+// Journaling a prefix and dropping it are two steps, and DropPrefix only
+// blocks writes once it starts, so a key written under the prefix between
+// them would go without a journal entry. I journal again what was written
+// while the last round ran until a round finds nothing new, which leaves
+// only the moment between that check and the drop.
+func (s *BadgerStore) DeleteMatching(ctx context.Context, m Matcher, p *Progress) DeleteSummary {
+	if s.readOnly {
+		return DeleteSummary{Err: ErrReadOnly}
+	}
+	// I check again rather than trust the preview: keys may have changed
+	// while it was on screen.
+	plan, err := s.PlanDelete(ctx, m, 0, nil)
+	if err != nil {
+		return DeleteSummary{Err: err}
+	}
+	op, err := s.trashOp()
+	if err != nil {
+		return DeleteSummary{Err: err}
+	}
+	if plan.Drop {
+		captured, err := s.captureDrop(ctx, op, plan.Prefix)
+		if err != nil {
+			return DeleteSummary{Err: s.discard(captured, err)}
+		}
+		if err := s.db.DropPrefix([]byte(plan.Prefix)); err != nil {
+			return DeleteSummary{Failed: plan.Total, Err: s.discardUnchanged(captured, err)}
+		}
+		p.Add(plan.Total)
+		return DeleteSummary{Dropped: true, Prefix: plan.Prefix, Deleted: plan.Total, Err: auditErr(s.audit(auditDrop(plan.Prefix, plan.Total)))}
+	}
+	return s.deleteInBatches(ctx, op, m, plan.Prefix, p)
+}
+
+func (s *BadgerStore) deleteInBatches(ctx context.Context, op int, m Matcher, prefix string, p *Progress) DeleteSummary {
+	var sum DeleteSummary
+	chunk := make([]TrashEntry, 0, DeleteChunk)
+	var chunkSize int64
+	flush := func() {
+		captured, err := s.captureOp(op, TrashPatternDelete, chunk)
+		if err == nil {
+			err = s.deleteChunk(chunk)
+		}
+		if err != nil {
+			err = s.discardUnchanged(captured, err)
+			sum.Failed += len(chunk)
+			if sum.Err == nil {
+				sum.Err = err
+			}
+		} else {
+			audits := make([]AuditEntry, len(chunk))
+			for i, e := range chunk {
+				sum.Keys = append(sum.Keys, e.Key)
+				audits[i] = auditChange(AuditPatternDelete, e, nil, false)
+			}
+			sum.Deleted += len(chunk)
+			p.Add(len(chunk))
+			if err := s.audit(audits...); err != nil && sum.Err == nil {
+				sum.Err = auditErr(err)
+			}
+		}
+		chunk, chunkSize = chunk[:0], 0
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		scanned := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if scanned++; scanned%progressEvery == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if !m.Match(string(it.Item().Key())) {
+				continue
+			}
+			e, err := trashEntry(it.Item())
+			if err != nil {
+				return err
+			}
+			size := txnEntrySize(e.Key, nil)
+			if len(chunk) > 0 && !s.txnFits(len(chunk)+1, chunkSize+size) {
+				flush()
+			}
+			chunkSize += size
+			if chunk = append(chunk, e); len(chunk) == DeleteChunk {
+				flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// I leave the pending chunk alone: a canceled delete stops here.
+		sum.Err = err
+		return sum
+	}
+	if len(chunk) > 0 {
+		flush()
+	}
+	return sum
+}
+
+// dropCaptureRounds bounds how often captureDrop goes back for keys written
+// meanwhile, so a prefix that is written to all the time still gets dropped.
+const dropCaptureRounds = 5
+
+// captureDrop journals every key under prefix for a delete that drops it,
+// then what was written under it while that ran, until nothing was.
+func (s *BadgerStore) captureDrop(ctx context.Context, op int, prefix string) ([]int, error) {
+	if s.trash == nil {
+		return nil, nil
+	}
+	var captured []int
+	var since uint64
+	for round := 0; round < dropCaptureRounds; round++ {
+		ids, readTs, err := s.captureAll(ctx, op, prefix, since)
+		if captured = append(captured, ids...); err != nil {
+			return captured, err
+		}
+		if round > 0 && len(ids) == 0 {
+			break
+		}
+		since = readTs
+	}
+	return captured, nil
+}
+
+// captureAll journals the keys under prefix written after since, a chunk
+// at a time. It returns the ids of what it journaled and the timestamp it
+// read at.
+func (s *BadgerStore) captureAll(ctx context.Context, op int, prefix string, since uint64) ([]int, uint64, error) {
+	var captured []int
+	var readTs uint64
+	err := s.db.View(func(txn *badger.Txn) error {
+		readTs = txn.ReadTs()
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		// After the first round most keys are older and skipped unread.
+		opts.PrefetchValues = since == 0
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		chunk := make([]TrashEntry, 0, DeleteChunk)
+		for it.Rewind(); it.Valid(); it.Next() {
+			if it.Item().Version() <= since {
+				continue
+			}
+			e, err := trashEntry(it.Item())
+			if err != nil {
+				return err
+			}
+			if chunk = append(chunk, e); len(chunk) == DeleteChunk {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				ids, err := s.captureOp(op, TrashPatternDelete, chunk)
+				if captured = append(captured, ids...); err != nil {
+					return err
+				}
+				chunk = chunk[:0]
+			}
+		}
+		ids, err := s.captureOp(op, TrashPatternDelete, chunk)
+		captured = append(captured, ids...)
+		return err
+	})
+	return captured, readTs, err
+}
+
+// deleteChunk writes the tombstones in one transaction. A WriteBatch would
+// commit part of them on its own when the chunk outgrew a transaction.
+func (s *BadgerStore) deleteChunk(entries []TrashEntry) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, e := range entries {
+			if err := txn.Delete([]byte(e.Key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// txnEntrySize is at least what Badger counts against a transaction's
+// size for one write (Txn.checkSize): the key with its version, the meta
+// bytes and the value, of which Badger counts only a pointer once it goes
+// to the value log.
+func txnEntrySize(key string, value []byte) int64 {
+	return int64(len(key)) + int64(len(value)) + 2 + 10
+}
+
+// txnFits tells whether count writes of size bytes in all fit in one
+// transaction; Badger refuses a write that reaches either limit.
+func (s *BadgerStore) txnFits(count int, size int64) bool {
+	return int64(count) < s.db.MaxBatchCount() && size < s.db.MaxBatchSize()
+}
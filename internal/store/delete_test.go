@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestLiteralPrefix(t *testing.T) {
+	for _, c := range []struct {
+		mode MatchMode
+		term string
+		want string
+		ok   bool
+	}{
+		{MatchPrefix, "user:", "user:", true},
+		{MatchGlob, "user:*", "user:", true},
+		{MatchGlob, "*", "", true},
+		{MatchGlob, "user:", "", false},
+		{MatchGlob, "user:*:name", "", false},
+		{MatchGlob, "us?r:*", "", false},
+		{MatchGlob, "user:[ab]*", "", false},
+		{MatchGlob, `user\*:*`, "", false},
+		{MatchGlob, "user:**", "", false},
+		{MatchSubstring, "user:", "", false},
+		{MatchFuzzy, "user:", "", false},
+	} {
+		got, ok := Matcher{Mode: c.mode, Term: c.term}.literalPrefix()
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s %q: got %q, %v; want %q, %v", c.mode, c.term, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func setKeys(t *testing.T, st *BadgerStore, keys ...string) {
+	t.Helper()
+	wb := st.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, k := range keys {
+		if err := wb.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(t *testing.T, st *BadgerStore, key string) bool {
+	t.Helper()
+	_, err := st.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+func matcher(t *testing.T, mode MatchMode, term string) Matcher {
+	t.Helper()
+	m, err := NewMatcher(mode, term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// A glob's * stops at '/', so "user:*" does not cover user:a/b and the
+// delete must not drop the whole prefix.
+func TestGlobDeleteKeepsKeysItDoesNotMatch(t *testing.T) {
+	st := openJournaled(t)
+	setKeys(t, st, "user:1", "user:2", "user:a/b", "users")
+	m := matcher(t, MatchGlob, "user:*")
+
+	plan, err := st.PlanDelete(context.Background(), m, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Drop || plan.Total != 2 || fmt.Sprint(plan.Sample) != "[user:1 user:2]" {
+		t.Fatalf("plan %+v, want 2 keys without a drop", plan)
+	}
+	sum := st.DeleteMatching(context.Background(), m, nil)
+	if sum.Err != nil || sum.Dropped || sum.Deleted != 2 {
+		t.Fatalf("delete %+v, want 2 keys deleted one by one", sum)
+	}
+	for key, want := range map[string]bool{"user:1": false, "user:2": false, "user:a/b": true, "users": true} {
+		if got := exists(t, st, key); got != want {
+			t.Errorf("%s exists: %v, want %v", key, got, want)
+		}
+	}
+}
+
+// A prefix covers every key under it, so it is dropped whole, and Undo
+// brings the keys back.
+func TestPrefixDeleteDrops(t *testing.T) {
+	st := openJournaled(t)
+	setKeys(t, st, "user:1", "user:2", "user:a/b", "users")
+	m := matcher(t, MatchPrefix, "user:")
+
+	plan, err := st.PlanDelete(context.Background(), m, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Drop || plan.Prefix != "user:" || plan.Total != 3 {
+		t.Fatalf("plan %+v, want a drop of the 3 keys under user:", plan)
+	}
+	sum := st.DeleteMatching(context.Background(), m, nil)
+	if sum.Err != nil || !sum.Dropped || sum.Prefix != "user:" || sum.Deleted != 3 {
+		t.Fatalf("delete %+v, want user: dropped with 3 keys", sum)
+	}
+	for key, want := range map[string]bool{"user:1": false, "user:2": false, "user:a/b": false, "users": true} {
+		if got := exists(t, st, key); got != want {
+			t.Errorf("%s exists: %v, want %v", key, got, want)
+		}
+	}
+	if _, err := st.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(t, st, "user:a/b") {
+		t.Error("user:a/b not back after undo")
+	}
+}
+
+// A chunk that cannot be journaled is not deleted, and the summary says how
+// many keys failed.
+func TestDeleteReportsFailedChunks(t *testing.T) {
+	st := openJournaled(t)
+	keys := make([]string, 2*DeleteChunk+500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k:%05d", i)
+	}
+	setKeys(t, st, keys...)
+	// The journal is taken to a directory that does not exist, so each
+	// chunk fails to be captured.
+	st.trash.path = filepath.Join(t.TempDir(), "gone", "db.trash.jsonl")
+
+	sum := st.DeleteMatching(context.Background(), matcher(t, MatchSubstring, "k:"), nil)
+	if sum.Err == nil || sum.Failed != len(keys) || sum.Deleted != 0 || len(sum.Keys) != 0 {
+		t.Fatalf("delete: failed %d, deleted %d, err %v; want all %d failed", sum.Failed, sum.Deleted, sum.Err, len(keys))
+	}
+	for _, key := range []string{keys[0], keys[DeleteChunk], keys[len(keys)-1]} {
+		if !exists(t, st, key) {
+			t.Errorf("%s deleted by a failed chunk", key)
+		}
+	}
+}
+
+// Tombstones for long keys outgrow a transaction long before DeleteChunk
+// of them, so chunks are cut by size; each is still one transaction.
+func TestDeleteChunksFitATransaction(t *testing.T) {
+	st := openJournaled(t)
+	long := strings.Repeat("x", 5000)
+	var keys []string
+	for i := range 1500 {
+		keys = append(keys, fmt.Sprintf("k:%05d:%s", i, long))
+	}
+	for i := 0; i < len(keys); i += 100 {
+		setKeys(t, st, keys[i:i+100]...)
+	}
+	if size := int64(DeleteChunk) * txnEntrySize(keys[0], nil); size < st.db.MaxBatchSize() {
+		t.Fatalf("a chunk of these keys is %d bytes, under the %d limit", size, st.db.MaxBatchSize())
+	}
+
+	sum := st.DeleteMatching(context.Background(), matcher(t, MatchSubstring, "k:"), nil)
+	if sum.Err != nil || sum.Failed != 0 || sum.Deleted != len(keys) {
+		t.Fatalf("delete: deleted %d, failed %d, err %v; want all %d deleted", sum.Deleted, sum.Failed, sum.Err, len(keys))
+	}
+	for _, key := range []string{keys[0], keys[len(keys)-1]} {
+		if exists(t, st, key) {
+			t.Errorf("%.10s… still there", key)
+		}
+	}
+}
+
+// A later round of journaling before a drop takes only the keys written
+// after the round before it read.
+func TestCaptureAllSince(t *testing.T) {
+	st := openJournaled(t)
+	setKeys(t, st, "user:1", "user:2", "user:3")
+	op, err := st.trashOp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, readTs, err := st.captureAll(context.Background(), op, "user:", 0)
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("first round journaled %d keys (%v), want 3", len(ids), err)
+	}
+	setKeys(t, st, "user:2", "user:4", "other")
+	ids, _, err = st.captureAll(context.Background(), op, "user:", readTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.trash.lookup(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range got {
+		keys = append(keys, e.Key)
+	}
+	if fmt.Sprint(keys) != "[user:2 user:4]" {
+		t.Errorf("second round journaled %v, want [user:2 user:4]", keys)
+	}
+}
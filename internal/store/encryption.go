@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Badger keeps decrypted table indexes in the index cache, so an encrypted DB
+// without one would decrypt every index on each read.
+const encryptedIndexCacheSize = 64 << 20
+
+var (
+	ErrEncryptionKeyRequired = errors.New("database is encrypted: pass --encryption-key-file or set BADGER_GUI_ENCRYPTION_KEY")
+	ErrEncryptionKeyMismatch = errors.New("encryption key does not match this database")
+)
+
+// ReadEncryptionKeyFile loads an AES key from disk; see ParseEncryptionKey.
+func ReadEncryptionKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read encryption key: %w", err)
+	}
+	return ParseEncryptionKey(data)
+}
+
+// ParseEncryptionKey accepts a 16, 24 or 32 byte AES key, raw or hex
+// encoded (surrounding whitespace is ignored). Text made only of hex digits
+// is read as hex, so `openssl rand -hex 16` gives an AES-128 key; a "hex:"
+// or "raw:" prefix says which it is outright, e.g. for a raw key that
+// happens to be 32 hex digits.
+func ParseEncryptionKey(data []byte) ([]byte, error) {
+	// A prefix may come after blank lines or spaces; what follows "raw:"
+	// is the key as it is, so I only trim in front of the prefix.
+	lead := bytes.TrimLeft(data, " \t\r\n")
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(lead, []byte("hex:")):
+		return hexKey(lead[len("hex:"):])
+	case bytes.HasPrefix(lead, []byte("raw:")):
+		return rawKey(lead[len("raw:"):])
+	case isHex(trimmed):
+		if key, err := hexKey(trimmed); err == nil {
+			return key, nil
+		}
+	}
+	return rawKey(data)
+}
+
+func hexKey(text []byte) ([]byte, error) {
+	key, err := hex.DecodeString(string(bytes.TrimSpace(text)))
+	if err != nil || !validKeyLen(len(key)) {
+		return nil, fmt.Errorf("hex encryption key must be 32, 48 or 64 hex digits, got %d characters", len(bytes.TrimSpace(text)))
+	}
+	return key, nil
+}
+
+// rawKey takes the bytes as they are, or without the surrounding
+// whitespace a key file usually ends with.
+func rawKey(data []byte) ([]byte, error) {
+	if validKeyLen(len(data)) {
+		return data, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if validKeyLen(len(trimmed)) {
+		return trimmed, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes raw, or 32, 48 or 64 hex digits; got %d bytes", len(trimmed))
+}
+
+func isHex(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func validKeyLen(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// I translate Badger's key registry failure into something actionable.
+func openError(err error, o Options) error {
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		if len(o.EncryptionKey) == 0 {
+			return ErrEncryptionKeyRequired
+		}
+		return ErrEncryptionKeyMismatch
+	}
+	if isLockError(err) {
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	}
+	return err
+}
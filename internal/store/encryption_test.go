@@ -0,0 +1,60 @@
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseEncryptionKey(t *testing.T) {
+	raw := func(n int) []byte { return bytes.Repeat([]byte("k"), n) }
+	key := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i*7 + 1)
+		}
+		return b
+	}
+	hexOf := func(n int) string { return hex.EncodeToString(key(n)) }
+	tests := []struct {
+		name string
+		in   string
+		want []byte
+	}{
+		{"raw 16", string(raw(16)), raw(16)},
+		{"raw 24", string(raw(24)), raw(24)},
+		{"raw 32", string(raw(32)), raw(32)},
+		{"raw binary 32", string(key(32)), key(32)},
+		{"raw 16 with newline", string(raw(16)) + "\n", raw(16)},
+		{"hex 32 digits is AES-128", hexOf(16), key(16)},
+		{"hex 48 digits is AES-192", hexOf(24), key(24)},
+		{"hex 64 digits is AES-256", hexOf(32), key(32)},
+		{"hex with newline", hexOf(16) + "\n", key(16)},
+		{"upper-case hex", "  " + string(bytes.ToUpper([]byte(hexOf(16)))) + "\n", key(16)},
+		{"hex prefix", "hex:" + hexOf(24), key(24)},
+		{"raw prefix keeps hex-looking bytes", "raw:" + hexOf(16), []byte(hexOf(16))},
+		{"raw prefix with newline", "raw:" + string(raw(24)) + "\n", raw(24)},
+		{"raw prefix after a blank line", "\n  raw:" + hexOf(16) + "\n", []byte(hexOf(16))},
+		{"raw prefix after a space, binary key", " raw:" + string(key(16)), key(16)},
+		{"hex prefix after a blank line", "\r\n\thex:" + hexOf(16), key(16)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEncryptionKey([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %x (%d bytes), want %x (%d bytes)", got, len(got), tt.want, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseEncryptionKeyRejects(t *testing.T) {
+	for _, in := range []string{"", "short", string(bytes.Repeat([]byte("k"), 20)), "hex:abcd", "hex:" + string(bytes.Repeat([]byte("z"), 32)), "raw:abc"} {
+		if key, err := ParseEncryptionKey([]byte(in)); err == nil {
+			t.Errorf("%q: got key %x, want an error", in, key)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// EachEntry calls fn with every live key of q that m accepts (all of them
+// when m is nil) and its value, in q's order, from one consistent read.
+// Values are read one at a time and are only valid during the call, so a
+// caller can stream the whole DB without holding it in memory. It stops at
+// the first error from fn or once ctx is done.
+func (s *BadgerStore) EachEntry(ctx context.Context, q KeyQuery, m *Matcher, p *Progress, fn func(key []byte, e Entry) error) error {
+	prefix := []byte(q.Prefix)
+	return s.view(func(txn *badger.Txn) error {
+		it := newKeyIterator(txn, q, "", false)
+		defer it.Close()
+		scanned := 0
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if scanned++; scanned%progressEvery == 0 {
+				p.Add(progressEvery)
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			if !q.match(item) || m != nil && !m.Match(string(item.Key())) {
+				continue
+			}
+			err := item.Value(func(v []byte) error {
+				return fn(item.Key(), Entry{Value: v, ExpiresAt: item.ExpiresAt(), UserMeta: item.UserMeta()})
+			})
+			if err != nil {
+				return err
+			}
+		}
+		p.Add(scanned % progressEvery)
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+)
+
+// internalPrefix starts the keys Badger keeps for itself, such as the
+// transaction markers it publishes along with the writes.
+const internalPrefix = "!badger!"
+
+// Change is one write to the open DB, as Subscribe sees it.
+type Change struct {
+	At        time.Time
+	Key       string
+	Version   uint64
+	Size      int
+	UserMeta  byte
+	ExpiresAt uint64
+	Deleted   bool
+}
+
+// Subscribe calls fn with the writes under any of prefixes (every write
+// when there are none) until ctx is done. It sees writes made through this
+// handle: edits, imports, deletes, undo and trash restores. A prefix drop
+// removes keys without writes and does not show.
+//
+// Badger holds up the writes while fn runs, so fn must only hand the
+// changes off. Badger does not say which writes are deletes: a delete is a
+// change with no value until ResolveDeletes has looked at it.
+func (s *BadgerStore) Subscribe(ctx context.Context, prefixes []string, fn func([]Change)) error {
+	matches := []pb.Match{{}}
+	if len(prefixes) > 0 {
+		matches = make([]pb.Match, len(prefixes))
+		for i, p := range prefixes {
+			matches[i] = pb.Match{Prefix: []byte(p)}
+		}
+	}
+	err := s.db.Subscribe(ctx, func(kvs *badger.KVList) error {
+		now := time.Now()
+		changes := make([]Change, 0, len(kvs.Kv))
+		for _, kv := range kvs.Kv {
+			if bytes.HasPrefix(kv.Key, []byte(internalPrefix)) {
+				continue
+			}
+			c := Change{At: now, Key: string(kv.Key), Version: kv.Version, Size: len(kv.Value), ExpiresAt: kv.ExpiresAt}
+			// Badger puts the UserMeta in Meta.
+			if len(kv.Meta) > 0 {
+				c.UserMeta = kv.Meta[0]
+			}
+			changes = append(changes, c)
+		}
+		if len(changes) > 0 {
+			fn(changes)
+		}
+		return nil
+	}, matches)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// ResolveDeletes marks the changes with no value that were deletes rather
+// than writes of an empty value, from the latest state of their keys.
+func (s *BadgerStore) ResolveDeletes(changes []Change) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		for i := range changes {
+			c := &changes[i]
+			if c.Size > 0 {
+				continue
+			}
+			item, err := txn.Get([]byte(c.Key))
+			switch {
+			case errors.Is(err, badger.ErrKeyNotFound):
+				c.Deleted = true
+			case err != nil:
+				return err
+			case item.Version() != c.Version:
+				// Written again since; I look for the version itself.
+				c.Deleted = deletedAt(txn, []byte(c.Key), c.Version)
+			}
+		}
+		return nil
+	})
+}
+
+func deletedAt(txn *badger.Txn, k []byte, version uint64) bool {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.AllVersions = true
+	opts.Prefix = k
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(k); it.ValidForPrefix(k); it.Next() {
+		item := it.Item()
+		if !bytes.Equal(item.Key(), k) {
+			break
+		}
+		if item.Version() == version {
+			return item.IsDeletedOrExpired()
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// FilterKeys reads at most scanLimit keys of q after cursor and returns up
+// to limit of them that m accepts. The returned cursor is the last key read,
+// matched or not, so a rare term still comes back after scanLimit keys and
+// the next call picks up from there. An empty cursor starts at the
+// beginning of q; done reports that the scan reached the end.
+func (s *BadgerStore) FilterKeys(ctx context.Context, q KeyQuery, m Matcher, cursor string, scanLimit, limit int) ([]string, string, bool, error) {
+	var keys []string
+	next := cursor
+	done := true
+	prefix := []byte(q.Prefix)
+	err := s.view(func(txn *badger.Txn) error {
+		it := newKeyIterator(txn, q, cursor, false)
+		defer it.Close()
+
+		scanned := 0
+		var last []byte
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if scanned >= scanLimit || len(keys) >= limit {
+				done = false
+				break
+			}
+			if scanned++; scanned%progressEvery == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			last = item.KeyCopy(last[:0])
+			if !q.match(item) {
+				continue
+			}
+			if key := string(last); m.Match(key) {
+				keys = append(keys, key)
+			}
+		}
+		if last != nil {
+			next = string(last)
+		}
+		return nil
+	})
+	return keys, next, done, err
+}
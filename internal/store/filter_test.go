@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func setMetaKeys(t *testing.T, st *BadgerStore, meta byte, keys ...string) {
+	t.Helper()
+	err := st.db.Update(func(txn *badger.Txn) error {
+		for _, k := range keys {
+			if err := txn.SetEntry(badger.NewEntry([]byte(k), []byte("v")).WithMeta(meta)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// filterAll follows the cursor until the scan is done.
+func filterAll(t *testing.T, st *BadgerStore, q KeyQuery, m Matcher, scanLimit, limit int) []string {
+	t.Helper()
+	var all []string
+	cursor := ""
+	for range 1000 {
+		keys, next, done, err := st.FilterKeys(context.Background(), q, m, cursor, scanLimit, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > limit {
+			t.Fatalf("%+v: %d keys in a page of %d", q, len(keys), limit)
+		}
+		all = append(all, keys...)
+		if done {
+			return all
+		}
+		if next == cursor {
+			t.Fatalf("%+v: the cursor is stuck at %q", q, cursor)
+		}
+		cursor = next
+	}
+	t.Fatalf("%+v: the filter does not end", q)
+	return nil
+}
+
+// Paging a meta filter, a few keys read and a few kept at a time, gives
+// every matching key once, in either order.
+func TestFilterKeysMetaPaging(t *testing.T) {
+	st := openMemory(t)
+	setMetaKeys(t, st, 0, "a/1", "a/3", "a/5", "b/1")
+	setMetaKeys(t, st, 7, "a/2", "a/4", "a/6", "a/x", "b/2")
+	setMetaKeys(t, st, 9, "a/7")
+	tests := []struct {
+		q    KeyQuery
+		term string
+		want []string
+	}{
+		{KeyQuery{Prefix: "a/", HasMeta: true, UserMeta: 7}, "", []string{"a/2", "a/4", "a/6", "a/x"}},
+		{KeyQuery{Prefix: "a/", HasMeta: true, UserMeta: 7}, "*/[0-9]", []string{"a/2", "a/4", "a/6"}},
+		{KeyQuery{HasMeta: true, UserMeta: 7}, "*/[0-9]", []string{"a/2", "a/4", "a/6", "b/2"}},
+		{KeyQuery{HasMeta: true}, "*/*", []string{"a/1", "a/3", "a/5", "b/1"}},
+		{KeyQuery{Prefix: "a/", HasMeta: true, UserMeta: 9}, "*/*", []string{"a/7"}},
+		{KeyQuery{Prefix: "a/", HasMeta: true, UserMeta: 3}, "*/*", nil},
+		{KeyQuery{Prefix: "a/"}, "a/[13]", []string{"a/1", "a/3"}},
+	}
+	for _, tt := range tests {
+		m := matcher(t, MatchGlob, tt.term)
+		if tt.term == "" {
+			m = matcher(t, MatchFuzzy, "")
+		}
+		back := slices.Clone(tt.want)
+		slices.Reverse(back)
+		for _, page := range [][2]int{{1, 1}, {2, 1}, {3, 2}, {100, 1}, {100, 100}} {
+			if got := filterAll(t, st, tt.q, m, page[0], page[1]); !slices.Equal(got, tt.want) {
+				t.Errorf("%+v %q reading %d keeping %d: %q, want %q", tt.q, tt.term, page[0], page[1], got, tt.want)
+			}
+			rq := tt.q
+			rq.Reverse = true
+			if got := filterAll(t, st, rq, m, page[0], page[1]); !slices.Equal(got, back) {
+				t.Errorf("%+v %q reading %d keeping %d: %q, want %q", rq, tt.term, page[0], page[1], got, back)
+			}
+		}
+	}
+}
+
+// A page that reads its limit without a match still moves the cursor on.
+func TestFilterKeysCursorPastMisses(t *testing.T) {
+	st := openMemory(t)
+	setMetaKeys(t, st, 0, "k1", "k2", "k3", "k4")
+	setMetaKeys(t, st, 7, "k5")
+	q := KeyQuery{HasMeta: true, UserMeta: 7}
+	keys, next, done, err := st.FilterKeys(context.Background(), q, matcher(t, MatchFuzzy, ""), "", 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%v %s %v", keys, next, done); got != "[] k3 false" {
+		t.Errorf("got %s, want [] k3 false", got)
+	}
+}
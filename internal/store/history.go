@@ -0,0 +1,88 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// KeyVersion describes one stored version of a key. Version is Badger's
+// commit timestamp: a logical counter, not wall-clock time.
+type KeyVersion struct {
+	Version   uint64
+	ExpiresAt uint64 // unix seconds, 0 when the entry has no TTL
+	Size      int64
+	UserMeta  byte
+	Deleted   bool
+	Expired   bool
+}
+
+// History lists every version Badger still holds for key, newest first.
+// Versions disappear once compaction or value log GC discards them.
+func (s *BadgerStore) History(key string) ([]KeyVersion, error) {
+	var out []KeyVersion
+	k := []byte(key)
+	now := uint64(time.Now().Unix())
+	err := s.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.AllVersions = true
+		opts.Prefix = k
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(k); it.ValidForPrefix(k); it.Next() {
+			item := it.Item()
+			if !bytes.Equal(item.Key(), k) {
+				break
+			}
+			v := KeyVersion{
+				Version:   item.Version(),
+				ExpiresAt: item.ExpiresAt(),
+				Size:      item.ValueSize(),
+				UserMeta:  item.UserMeta(),
+			}
+			if item.IsDeletedOrExpired() {
+				v.Expired = v.ExpiresAt != 0 && v.ExpiresAt <= now
+				v.Deleted = !v.Expired
+			}
+			out = append(out, v)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// GetVersion loads the entry written at a specific version of key.
+func (s *BadgerStore) GetVersion(key string, version uint64) (Entry, error) {
+	var out Entry
+	k := []byte(key)
+	err := s.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.AllVersions = true
+		opts.Prefix = k
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(k); it.ValidForPrefix(k); it.Next() {
+			item := it.Item()
+			if !bytes.Equal(item.Key(), k) {
+				break
+			}
+			if item.Version() != version {
+				continue
+			}
+			out.ExpiresAt = item.ExpiresAt()
+			out.UserMeta = item.UserMeta()
+			if item.IsDeletedOrExpired() {
+				return nil
+			}
+			var err error
+			out.Value, err = item.ValueCopy(nil)
+			return err
+		}
+		return fmt.Errorf("version %d of key not found (it may have been compacted away)", version)
+	})
+	return out, err
+}
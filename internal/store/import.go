@@ -0,0 +1,225 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ImportChunk is how many keys go into one chunk at most. Like a delete
+// chunk, a chunk is cut before it outgrows a transaction and is written in
+// a single one, so it commits all or nothing.
+const ImportChunk = 1000
+
+// Conflict says what an import does with a key that already exists.
+type Conflict int
+
+const (
+	ConflictOverwrite Conflict = iota
+	ConflictSkip
+	ConflictFail
+)
+
+var conflictNames = []string{"overwrite", "skip", "fail"}
+
+func (c Conflict) String() string {
+	if c < 0 || int(c) >= len(conflictNames) {
+		return "unknown"
+	}
+	return conflictNames[c]
+}
+
+// Next cycles through the policies in the order above.
+func (c Conflict) Next() Conflict {
+	return (c + 1) % Conflict(len(conflictNames))
+}
+
+func ParseConflict(s string) (Conflict, error) {
+	for i, name := range conflictNames {
+		if strings.EqualFold(s, name) {
+			return Conflict(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown conflict policy %q (want %s)", s, strings.Join(conflictNames, ", "))
+}
+
+// ErrKeyExists is returned by an import with ConflictFail that meets a key
+// already in the DB.
+var ErrKeyExists = errors.New("key already exists")
+
+// ImportEntry is one key an import writes.
+type ImportEntry struct {
+	Key string
+	Entry
+}
+
+// ImportSummary counts what an import did, or with a dry run what it would
+// do. Keys whose TTL has already passed are left out as Expired.
+type ImportSummary struct {
+	Created     int
+	Overwritten int
+	Skipped     int
+	Expired     int
+	Failed      int   // keys in chunks that did not commit
+	Err         error // the first failure, or ctx.Err() when canceled
+}
+
+// Import writes the entries next returns until it returns io.EOF, in
+// WriteBatch chunks. Each chunk is checked against the DB first: existing
+// keys are overwritten, skipped or stop the import, as c says. A dry run
+// only counts; it reads the DB and works on a read-only store. Otherwise
+// the keys go to the undo journal as one change and to the audit log. next
+// must return a fresh Value each time, as a chunk holds on to them. p
+// counts entries read. A failure stops the import, but the chunks before it
+// stay written; they are part of the same change, so Undo takes them back.
+func (s *BadgerStore) Import(ctx context.Context, next func() (ImportEntry, error), c Conflict, dryRun bool, p *Progress) ImportSummary {
+	var sum ImportSummary
+	if s.readOnly && !dryRun {
+		sum.Err = ErrReadOnly
+		return sum
+	}
+	op := 0
+	if !dryRun {
+		var err error
+		if op, err = s.trashOp(); err != nil {
+			sum.Err = err
+			return sum
+		}
+	}
+	// pending holds the keys read but not yet committed, so a key that
+	// comes twice counts as overwritten the second time. A dry run never
+	// commits, so it remembers every key.
+	pending := map[string]bool{}
+	chunk := make([]ImportEntry, 0, ImportChunk)
+	var chunkSize int64
+	flush := func() error {
+		err := s.importChunk(op, chunk, pending, c, dryRun, &sum)
+		chunk, chunkSize = chunk[:0], 0
+		if !dryRun {
+			clear(pending)
+		}
+		return err
+	}
+	now := uint64(time.Now().Unix())
+	for {
+		e, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			sum.Err = err
+			return sum
+		}
+		p.Add(1)
+		if e.ExpiresAt != 0 && e.ExpiresAt <= now {
+			sum.Expired++
+			continue
+		}
+		size := txnEntrySize(e.Key, e.Value)
+		if len(chunk) > 0 && !s.txnFits(len(chunk)+1, chunkSize+size) {
+			if err := flush(); err != nil {
+				return sum
+			}
+		}
+		chunkSize += size
+		if chunk = append(chunk, e); len(chunk) == ImportChunk {
+			if err := flush(); err != nil {
+				return sum
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		flush()
+	}
+	return sum
+}
+
+// importChunk checks and writes one chunk and adds it to sum. It returns
+// the error that should stop the import, which is also in sum.
+func (s *BadgerStore) importChunk(op int, chunk []ImportEntry, pending map[string]bool, c Conflict, dryRun bool, sum *ImportSummary) error {
+	var olds []TrashEntry
+	var writes []ImportEntry
+	created, overwritten, skipped := 0, 0, 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, e := range chunk {
+			old, err := oldState(txn, e.Key)
+			if err != nil {
+				return err
+			}
+			exists := old.Existed || pending[e.Key]
+			switch {
+			case exists && c == ConflictFail:
+				return fmt.Errorf("%w: %q", ErrKeyExists, e.Key)
+			case exists && c == ConflictSkip:
+				skipped++
+				continue
+			case exists:
+				overwritten++
+			default:
+				created++
+			}
+			// I journal a key once per chunk, as it was before the import.
+			if !pending[e.Key] {
+				olds = append(olds, old)
+			}
+			pending[e.Key] = true
+			writes = append(writes, e)
+		}
+		return nil
+	})
+	if err == nil && !dryRun {
+		var captured []int
+		captured, err = s.captureOp(op, TrashImport, olds)
+		if err == nil {
+			err = s.writeChunk(writes)
+		}
+		if err != nil {
+			err = s.discardUnchanged(captured, err)
+			sum.Failed += len(writes)
+		}
+	}
+	if err != nil {
+		sum.Err = err
+		return err
+	}
+	sum.Created += created
+	sum.Overwritten += overwritten
+	sum.Skipped += skipped
+	if dryRun {
+		return nil
+	}
+	audits := make([]AuditEntry, 0, len(writes))
+	byKey := make(map[string]int, len(writes))
+	for i, e := range writes {
+		byKey[e.Key] = i
+	}
+	for _, old := range olds {
+		audits = append(audits, auditChange(AuditImport, old, writes[byKey[old.Key]].Value, true))
+	}
+	if err := s.audit(audits...); err != nil && sum.Err == nil {
+		sum.Err = auditErr(err)
+	}
+	return nil
+}
+
+// writeChunk writes the chunk in one transaction, as deleteChunk does.
+func (s *BadgerStore) writeChunk(entries []ImportEntry) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, e := range entries {
+			be := badger.NewEntry([]byte(e.Key), e.Value).WithMeta(e.UserMeta)
+			be.ExpiresAt = e.ExpiresAt
+			if err := txn.SetEntry(be); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
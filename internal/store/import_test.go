@@ -0,0 +1,35 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// Large values outgrow a transaction long before ImportChunk of them, so
+// chunks are cut by size; each is still one transaction.
+func TestImportChunksFitATransaction(t *testing.T) {
+	st := openJournaled(t)
+	const keys = 1500
+	value := bytes.Repeat([]byte("v"), 5000)
+	if size := int64(ImportChunk) * txnEntrySize("k:00000", value); size < st.db.MaxBatchSize() {
+		t.Fatalf("a chunk of these entries is %d bytes, under the %d limit", size, st.db.MaxBatchSize())
+	}
+	i := 0
+	next := func() (ImportEntry, error) {
+		if i == keys {
+			return ImportEntry{}, io.EOF
+		}
+		i++
+		return ImportEntry{Key: fmt.Sprintf("k:%05d", i), Entry: Entry{Value: bytes.Clone(value)}}, nil
+	}
+	sum := st.Import(context.Background(), next, ConflictOverwrite, false, nil)
+	if sum.Err != nil || sum.Failed != 0 || sum.Created != keys {
+		t.Fatalf("import: created %d, failed %d, err %v; want all %d created", sum.Created, sum.Failed, sum.Err, keys)
+	}
+	if !exists(t, st, "k:01500") {
+		t.Error("last key not imported")
+	}
+}
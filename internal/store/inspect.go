@@ -0,0 +1,58 @@
+package store
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// KeyInfo is what the inspector panel shows for one key.
+type KeyInfo struct {
+	KeySize       int64
+	ValueSize     int64
+	EstimatedSize int64
+	Version       uint64
+	ExpiresAt     uint64
+	UserMeta      byte
+	InValueLog    bool
+	SHA256        [sha256.Size]byte
+	CRC32         uint32
+}
+
+func (s *BadgerStore) Inspect(key string) (KeyInfo, error) {
+	var info KeyInfo
+	err := s.view(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		info.KeySize = item.KeySize()
+		info.EstimatedSize = item.EstimatedSize()
+		info.Version = item.Version()
+		info.ExpiresAt = item.ExpiresAt()
+		info.UserMeta = item.UserMeta()
+		return item.Value(func(v []byte) error {
+			info.ValueSize = int64(len(v))
+			info.InValueLog = inValueLog(item, info.ValueSize)
+			info.SHA256 = sha256.Sum256(v)
+			info.CRC32 = crc32.ChecksumIEEE(v)
+			return nil
+		})
+	})
+	return info, err
+}
+
+// inValueLog tells whether the value lives in the value log instead of next
+// to the key in the LSM tree. Item hides the meta bit that says so, but
+// EstimatedSize gives it away: for a value kept with the key it is the key
+// and value lengths, while a value pointer's length also covers the log
+// entry's header and checksum. An empty value kept with the key counts as
+// no value, so its estimate is 0.
+func inValueLog(item *badger.Item, valueSize int64) bool {
+	est := item.EstimatedSize()
+	if est == item.KeySize()+valueSize {
+		return false
+	}
+	return valueSize > 0 || est != 0
+}
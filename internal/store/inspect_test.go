@@ -0,0 +1,54 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Inspect reads where a value lives from the public Item API; this pins
+// that down for values on both sides of the threshold, before and after
+// they are flushed to a table.
+func TestInspectValueLog(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	o, err := ProfileOptions(ProfileInspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ReadOnly = false
+	o.LogLevel = "off"
+	o.ValueThreshold = 64
+	values := map[string]string{
+		"empty": "",
+		"small": "tiny",
+		"edge":  strings.Repeat("e", 63),
+		"big":   strings.Repeat("b", 1000),
+	}
+	want := map[string]bool{"empty": false, "small": false, "edge": false, "big": true}
+
+	for _, reopened := range []bool{false, true} {
+		st, err := OpenBadger(dir, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reopened {
+			for k, v := range values {
+				if err := st.Set(k, []byte(v)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		for k, v := range values {
+			info, err := st.Inspect(k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.InValueLog != want[k] || info.ValueSize != int64(len(v)) {
+				t.Errorf("%s (reopened %v): in value log %v, size %d; want %v, %d", k, reopened, info.InValueLog, info.ValueSize, want[k], len(v))
+			}
+		}
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func openMemory(t *testing.T) *BadgerStore {
+	t.Helper()
+	o, err := ProfileOptions(ProfileInspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ReadOnly = false
+	o.InMemory = true
+	o.LogLevel = "off"
+	st, err := OpenBadger("", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// listKeys holds keys around the edges of the byte order: prefixes of
+// 0xff bytes have no successor to seek back from.
+var listKeys = []string{
+	"a", "a/1", "a/2", "a\xff", "a\xff\x00", "b",
+	"\xff", "\xff\xff", "\xff\xff\x00", "\xff\xff\xff", "\xff\xff\xff\xff",
+}
+
+// pageAll reads q a page of size keys at a time, each page starting after
+// the last key of the one before.
+func pageAll(t *testing.T, st *BadgerStore, q KeyQuery, size int) []string {
+	t.Helper()
+	var all []string
+	after := ""
+	for range 100 {
+		keys, last, more, err := st.ListKeys(q, after, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, keys...)
+		if !more {
+			return all
+		}
+		if len(keys) == 0 {
+			t.Fatalf("%+v: an empty page with more after %q", q, after)
+		}
+		after = last
+	}
+	t.Fatalf("%+v: paging does not end", q)
+	return nil
+}
+
+func under(prefix string) []string {
+	var keys []string
+	for _, k := range listKeys {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Paging forward and backward over a prefix, a page size at a time, gives
+// every key once, in order.
+func TestListKeysPaging(t *testing.T) {
+	st := openMemory(t)
+	setKeys(t, st, listKeys...)
+	for _, prefix := range []string{"", "a", "a\xff", "\xff", "\xff\xff", "\xff\xff\xff\xff", "c"} {
+		want := under(prefix)
+		back := slices.Clone(want)
+		slices.Reverse(back)
+		for _, size := range []int{1, 2, 3, len(listKeys)} {
+			if got := pageAll(t, st, KeyQuery{Prefix: prefix}, size); !slices.Equal(got, want) {
+				t.Errorf("prefix %q by %d: %q, want %q", prefix, size, got, want)
+			}
+			if got := pageAll(t, st, KeyQuery{Prefix: prefix, Reverse: true}, size); !slices.Equal(got, back) {
+				t.Errorf("prefix %q by %d backwards: %q, want %q", prefix, size, got, back)
+			}
+		}
+	}
+}
+
+func TestListKeysStart(t *testing.T) {
+	st := openMemory(t)
+	setKeys(t, st, listKeys...)
+	tests := []struct {
+		q         KeyQuery
+		start     string
+		inclusive bool
+		want      string
+	}{
+		// A start before the prefix begins at its first key.
+		{KeyQuery{Prefix: "a/"}, "", false, "[a/1 a/2]"},
+		{KeyQuery{Prefix: "a/"}, "0", false, "[a/1 a/2]"},
+		{KeyQuery{Prefix: "a/"}, "a/1", false, "[a/2]"},
+		{KeyQuery{Prefix: "a/"}, "a/1", true, "[a/1 a/2]"},
+		{KeyQuery{Prefix: "a/"}, "a/15", true, "[a/2]"},
+		{KeyQuery{Prefix: "a/"}, "a/3", true, "[]"},
+		// Backwards, a start at or past the prefix's successor begins at
+		// its last key.
+		{KeyQuery{Prefix: "a/", Reverse: true}, "a0", false, "[a/2 a/1]"},
+		{KeyQuery{Prefix: "a/", Reverse: true}, "z", true, "[a/2 a/1]"},
+		{KeyQuery{Prefix: "a/", Reverse: true}, "a/2", false, "[a/1]"},
+		{KeyQuery{Prefix: "a/", Reverse: true}, "a/2", true, "[a/2 a/1]"},
+		{KeyQuery{Prefix: "a/", Reverse: true}, "a/15", true, "[a/1]"},
+		{KeyQuery{Prefix: "a/", Reverse: true}, "a/0", true, "[]"},
+		// A prefix of 0xff bytes has no successor: backwards it starts
+		// at the very last key.
+		{KeyQuery{Prefix: "\xff\xff", Reverse: true}, "", false, `["\xff\xff\xff\xff" "\xff\xff\xff" "\xff\xff\x00" "\xff\xff"]`},
+		{KeyQuery{Prefix: "\xff\xff", Reverse: true}, "\xff\xff\xff", false, `["\xff\xff\x00" "\xff\xff"]`},
+		{KeyQuery{Prefix: "\xff\xff", Reverse: true}, "\xff\xff\xff", true, `["\xff\xff\xff" "\xff\xff\x00" "\xff\xff"]`},
+		{KeyQuery{Prefix: "\xff\xff"}, "\xff\xff\x00", false, `["\xff\xff\xff" "\xff\xff\xff\xff"]`},
+	}
+	for _, tt := range tests {
+		list := st.ListKeys
+		if tt.inclusive {
+			list = st.ListKeysFrom
+		}
+		keys, _, _, err := list(tt.q, tt.start, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprint(keys)
+		if strings.Contains(tt.want, `"`) {
+			got = fmt.Sprintf("%q", keys)
+		}
+		if got != tt.want {
+			t.Errorf("%+v from %q (inclusive %v): %s, want %s", tt.q, tt.start, tt.inclusive, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchMode selects how a Matcher compares a term with keys.
+type MatchMode int
+
+const (
+	MatchFuzzy     MatchMode = iota // ranked subsequence, ignores case
+	MatchSubstring                  // term anywhere in the key
+	MatchPrefix                     // key starts with term
+	MatchRegex                      // Go regexp
+	MatchGlob                       // path.Match pattern
+)
+
+var matchModeNames = []string{"fuzzy", "substring", "prefix", "regex", "glob"}
+
+func (m MatchMode) String() string {
+	if m < 0 || int(m) >= len(matchModeNames) {
+		return "unknown"
+	}
+	return matchModeNames[m]
+}
+
+// Next cycles through the modes in the order above.
+func (m MatchMode) Next() MatchMode {
+	return (m + 1) % MatchMode(len(matchModeNames))
+}
+
+func ParseMatchMode(s string) (MatchMode, error) {
+	for i, name := range matchModeNames {
+		if strings.EqualFold(s, name) {
+			return MatchMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown match mode %q (want %s)", s, strings.Join(matchModeNames, ", "))
+}
+
+// Matcher is the one key matcher behind the list filter, the match counter
+// and pattern operations, so they always agree.
+type Matcher struct {
+	Mode    MatchMode
+	Term    string
+	pattern []rune
+	re      *regexp.Regexp
+}
+
+// NewMatcher checks the term up front so a bad regex or glob fails before a scan.
+func NewMatcher(mode MatchMode, term string) (Matcher, error) {
+	m := Matcher{Mode: mode, Term: term}
+	switch mode {
+	case MatchFuzzy:
+		m.pattern = []rune(term)
+	case MatchRegex:
+		re, err := regexp.Compile(term)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		m.re = re
+	case MatchGlob:
+		if _, err := path.Match(term, ""); err != nil {
+			return Matcher{}, fmt.Errorf("invalid glob: %w", err)
+		}
+	case MatchSubstring, MatchPrefix:
+	default:
+		return Matcher{}, fmt.Errorf("unknown match mode %d", mode)
+	}
+	return m, nil
+}
+
+func (m Matcher) Match(key string) bool {
+	_, _, ok := m.Rank(key)
+	return ok
+}
+
+// Rank reports whether key matches, a score where higher is a better fuzzy
+// match, and the byte offsets in key that matched, for highlighting.
+func (m Matcher) Rank(key string) (int, []int, bool) {
+	switch m.Mode {
+	case MatchFuzzy:
+		return fuzzyRank(m.pattern, key)
+	case MatchSubstring:
+		i := strings.Index(key, m.Term)
+		if i < 0 {
+			return 0, nil, false
+		}
+		return 0, span(i, i+len(m.Term)), true
+	case MatchPrefix:
+		if !strings.HasPrefix(key, m.Term) {
+			return 0, nil, false
+		}
+		return 0, span(0, len(m.Term)), true
+	case MatchRegex:
+		loc := m.re.FindStringIndex(key)
+		if loc == nil {
+			return 0, nil, false
+		}
+		return 0, span(loc[0], loc[1]), true
+	case MatchGlob:
+		ok, _ := path.Match(m.Term, key)
+		return 0, nil, ok
+	}
+	return 0, nil, false
+}
+
+// RankedMatch is one key that matched, by its index in the input.
+type RankedMatch struct {
+	Index   int
+	Matched []int
+}
+
+// RankKeys returns the matching keys; fuzzy matches come best first and the
+// other modes keep the input order.
+func (m Matcher) RankKeys(keys []string) []RankedMatch {
+	var out []RankedMatch
+	var scores []int
+	for i, k := range keys {
+		score, matched, ok := m.Rank(k)
+		if !ok {
+			continue
+		}
+		out = append(out, RankedMatch{Index: i, Matched: matched})
+		scores = append(scores, score)
+	}
+	if m.Mode == MatchFuzzy {
+		idx := make([]int, len(out))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+		sorted := make([]RankedMatch, len(out))
+		for i, j := range idx {
+			sorted[i] = out[j]
+		}
+		out = sorted
+	}
+	return out
+}
+
+func span(from, to int) []int {
+	out := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+// fuzzyRank matches pattern as a case-insensitive subsequence of target. I
+// reward runs of consecutive characters and matches at the start of a
+// segment, and charge a little for every skipped character.
+func fuzzyRank(pattern []rune, target string) (int, []int, bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+	var matched []int
+	score := 0
+	pi := 0
+	prevMatch := -1
+	prev := rune(0)
+	for i, r := range target {
+		if pi < len(pattern) && equalFold(r, pattern[pi]) {
+			score += 10
+			switch {
+			case prevMatch >= 0 && prevMatch+utf8.RuneLen(prev) == i:
+				score += 15
+			case i == 0 || isSegmentStart(prev):
+				score += 12
+			}
+			matched = append(matched, i)
+			prevMatch = i
+			pi++
+		} else if pi > 0 && pi < len(pattern) {
+			score--
+		}
+		prev = r
+	}
+	if pi < len(pattern) {
+		return 0, nil, false
+	}
+	// I prefer shorter keys when everything else is equal.
+	score -= utf8.RuneCountInString(target) / 8
+	return score, matched, true
+}
+
+func isSegmentStart(prev rune) bool {
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+// I lifted this from strings.EqualFold.
+func equalFold(tr, sr rune) bool {
+	if tr == sr {
+		return true
+	}
+	if tr < sr {
+		tr, sr = sr, tr
+	}
+	// I fast-path ASCII.
+	if tr < utf8.RuneSelf {
+		// I normalize ASCII case by comparing lower/upper pairs.
+		if 'A' <= sr && sr <= 'Z' && tr == sr+'a'-'A' {
+			return true
+		}
+		return false
+	}
+
+	// I fall back to SimpleFold for the general case, which cycles equivalents.
+	r := unicode.SimpleFold(sr)
+	for r != sr && r < tr {
+		r = unicode.SimpleFold(r)
+	}
+	return r == tr
+}
+
+// literalPrefix returns the prefix every match must start with when the
+// term is a plain prefix: prefix mode, or a glob like "user:*" with no other
+// wildcard. Globs' * stops at '/', so a caller still has to check that every
+// key under the prefix matches before treating it as the whole match set.
+func (m Matcher) literalPrefix() (string, bool) {
+	switch m.Mode {
+	case MatchPrefix:
+		return m.Term, true
+	case MatchGlob:
+		lit, ok := strings.CutSuffix(m.Term, "*")
+		if ok && !strings.ContainsAny(lit, `*?[\`) {
+			return lit, true
+		}
+	}
+	return "", false
+}
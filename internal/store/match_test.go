@@ -0,0 +1,116 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatcherMatch(t *testing.T) {
+	tests := []struct {
+		mode MatchMode
+		term string
+		key  string
+		want bool
+	}{
+		{MatchFuzzy, "", "anything", true},
+		{MatchFuzzy, "usr", "user:42", true},
+		{MatchFuzzy, "USR", "user:42", true},
+		{MatchFuzzy, "rsu", "user:42", false},
+		{MatchFuzzy, "ÄB", "äb", true},
+		{MatchSubstring, "er:4", "user:42", true},
+		{MatchSubstring, "ER", "user:42", false},
+		{MatchPrefix, "user:", "user:42", true},
+		{MatchPrefix, "ser:", "user:42", false},
+		{MatchRegex, `^user:\d+$`, "user:42", true},
+		{MatchRegex, `^user:\d+$`, "user:4a", false},
+		{MatchRegex, `\d`, "a1b", true},
+		{MatchGlob, "user:*", "user:42", true},
+		{MatchGlob, "user:*", "user:a/b", false},
+		{MatchGlob, "user:*/*", "user:a/b", true},
+		{MatchGlob, "user:?", "user:42", false},
+		{MatchGlob, "user:[0-9][0-9]", "user:42", true},
+	}
+	for _, tt := range tests {
+		m := matcher(t, tt.mode, tt.term)
+		if got := m.Match(tt.key); got != tt.want {
+			t.Errorf("%s %q on %q: %v, want %v", tt.mode, tt.term, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherMatched(t *testing.T) {
+	tests := []struct {
+		mode      MatchMode
+		term, key string
+		want      string
+	}{
+		{MatchFuzzy, "ua", "user:a", "[0 5]"},
+		{MatchSubstring, "er", "user", "[2 3]"},
+		{MatchPrefix, "us", "user", "[0 1]"},
+		{MatchRegex, `\d+`, "ab123c", "[2 3 4]"},
+		{MatchGlob, "u*", "user", "[]"},
+	}
+	for _, tt := range tests {
+		_, matched, ok := matcher(t, tt.mode, tt.term).Rank(tt.key)
+		if got := fmt.Sprint(matched); !ok || got != tt.want {
+			t.Errorf("%s %q on %q: %s (%v), want %s", tt.mode, tt.term, tt.key, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNewMatcherRejects(t *testing.T) {
+	tests := []struct {
+		mode MatchMode
+		term string
+		want string
+	}{
+		{MatchRegex, "user:(", "invalid regex"},
+		{MatchRegex, "[a-", "invalid regex"},
+		{MatchGlob, "user:[", "invalid glob"},
+		{MatchGlob, `user:\`, "invalid glob"},
+		{MatchMode(99), "x", "unknown match mode"},
+	}
+	for _, tt := range tests {
+		if _, err := NewMatcher(tt.mode, tt.term); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: %v, want %q", tt.mode, tt.term, err, tt.want)
+		}
+	}
+}
+
+// Fuzzy matches come best first: consecutive runs, then segment starts,
+// then fewer skipped characters, then shorter keys. Ties keep input order.
+func TestRankKeysFuzzyOrder(t *testing.T) {
+	tests := []struct {
+		term string
+		keys []string
+		want []string
+	}{
+		{"user", []string{"uxsxexr", "xuser", "user"}, []string{"user", "xuser", "uxsxexr"}},
+		{"ab", []string{"xaxb", "x:a:b", "ab"}, []string{"ab", "x:a:b", "xaxb"}},
+		{"ab", []string{"a-----b", "a-b"}, []string{"a-b", "a-----b"}},
+		{"ab", []string{"ab" + strings.Repeat("x", 40), "ab"}, []string{"ab", "ab" + strings.Repeat("x", 40)}},
+		{"ab", []string{"xab", "yab", "ba"}, []string{"xab", "yab"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range matcher(t, MatchFuzzy, tt.term).RankKeys(tt.keys) {
+			got = append(got, tt.keys[r.Index])
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q over %q: %q, want %q", tt.term, tt.keys, got, tt.want)
+		}
+	}
+}
+
+// Modes other than fuzzy keep the input order.
+func TestRankKeysKeepsOrder(t *testing.T) {
+	keys := []string{"b1", "a", "b22", "b"}
+	var got []int
+	for _, r := range matcher(t, MatchSubstring, "b").RankKeys(keys) {
+		got = append(got, r.Index)
+	}
+	if fmt.Sprint(got) != "[0 2 3]" {
+		t.Errorf("got %v, want [0 2 3]", got)
+	}
+}
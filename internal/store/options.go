@@ -0,0 +1,182 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+const (
+	ProfileInspect     = "inspect"
+	ProfilePerformance = "performance"
+)
+
+type Options struct {
+	// ReadOnly opens the DB with Badger's ReadOnly option and rejects mutations.
+	ReadOnly bool
+
+	// Profile selects the base tuning; the fields below override it.
+	Profile        string
+	BlockCacheSize int64
+	IndexCacheSize int64
+	NumCompactors  int
+	Compression    string // none, snappy or zstd
+	SyncWrites     bool
+	ValueThreshold int64
+	InMemory       bool
+	LogLevel       string // debug, info, warning, error or off
+
+	// ScanWorkers is how many goroutines full scans (counts, groups) use;
+	// 0 means one per CPU.
+	ScanWorkers int
+
+	// TrashPath is the undo journal that deletes and saves write to first;
+	// empty disables it.
+	TrashPath string
+
+	// AuditPath is the append-only log every change is recorded in; empty
+	// disables it.
+	AuditPath string
+
+	// EncryptionKey opens a DB encrypted at rest; empty means unencrypted.
+	EncryptionKey         []byte
+	EncryptionKeyRotation time.Duration
+
+	// frozen keeps Badger from deleting or rewriting any table, for a
+	// checkpoint copy whose tables are hard links to the original's.
+	frozen bool
+}
+
+// ProfileOptions returns the fully populated options for a built-in profile.
+func ProfileOptions(name string) (Options, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ProfileInspect:
+		// I keep caches small so a quick look works on a CI box.
+		return Options{
+			ReadOnly:       true,
+			Profile:        ProfileInspect,
+			BlockCacheSize: 16 << 20,
+			IndexCacheSize: 0,
+			NumCompactors:  2,
+			Compression:    "snappy",
+			SyncWrites:     true,
+			ValueThreshold: 1 << 20,
+			LogLevel:       "warning",
+		}, nil
+	case ProfilePerformance:
+		// I keep the original heavy tuning for long editing sessions on big hosts.
+		return Options{
+			ReadOnly:       true,
+			Profile:        ProfilePerformance,
+			BlockCacheSize: 512 << 20,
+			IndexCacheSize: 256 << 20,
+			NumCompactors:  4,
+			Compression:    "snappy",
+			SyncWrites:     false,
+			ValueThreshold: 1 << 20,
+			LogLevel:       "warning",
+		}, nil
+	default:
+		return Options{}, fmt.Errorf("unknown profile %q (want %s or %s)", name, ProfileInspect, ProfilePerformance)
+	}
+}
+
+func (o Options) badgerOptions(path string) (badger.Options, error) {
+	opts := badger.DefaultOptions(path)
+	if o.InMemory {
+		if o.ReadOnly {
+			return opts, fmt.Errorf("in-memory mode starts empty and needs --write")
+		}
+		opts = badger.DefaultOptions("").WithInMemory(true)
+	}
+
+	switch o.Profile {
+	case ProfilePerformance:
+		opts.NumMemtables = 5
+		opts.NumLevelZeroTables = 5
+		opts.NumLevelZeroTablesStall = 10
+		opts.ValueLogFileSize = 1 << 30 // I set the value log file size to 1GB.
+		opts.ValueLogMaxEntries = 1000000
+	default:
+		// I shrink memtables and vlog files; they dominate memory on open.
+		opts.NumMemtables = 2
+		opts.MemTableSize = 16 << 20
+		opts.ValueLogFileSize = 64 << 20
+	}
+
+	opts.ReadOnly = o.ReadOnly
+	opts.BlockCacheSize = o.BlockCacheSize
+	opts.IndexCacheSize = o.IndexCacheSize
+	opts.NumCompactors = o.NumCompactors
+	opts.SyncWrites = o.SyncWrites
+	opts.ValueThreshold = o.ValueThreshold
+
+	compression, err := parseCompression(o.Compression)
+	if err != nil {
+		return opts, err
+	}
+	opts.Compression = compression
+
+	if len(o.EncryptionKey) > 0 {
+		opts.EncryptionKey = o.EncryptionKey
+		if o.EncryptionKeyRotation > 0 {
+			opts.EncryptionKeyRotationDuration = o.EncryptionKeyRotation
+		}
+		if opts.IndexCacheSize == 0 {
+			opts.IndexCacheSize = encryptedIndexCacheSize
+		}
+	}
+	// Badger panics instead of failing here, so I check it first.
+	if opts.BlockCacheSize == 0 && (opts.Compression != options.None || len(opts.EncryptionKey) > 0) {
+		return opts, fmt.Errorf("block cache size must be set when compression or encryption is enabled")
+	}
+
+	if o.frozen {
+		// A table Badger retires is truncated before it is removed, and a
+		// hard link shares that with the original; so nothing compacts, and
+		// replayed memtables are flushed into L0 without ever stalling on it.
+		opts.NumCompactors = 0
+		opts.CompactL0OnClose = false
+		opts.NumLevelZeroTablesStall = math.MaxInt32
+	}
+
+	if err := applyLogLevel(&opts, o.LogLevel); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func parseCompression(name string) (options.CompressionType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none", "off":
+		return options.None, nil
+	case "", "snappy":
+		return options.Snappy, nil
+	case "zstd":
+		return options.ZSTD, nil
+	default:
+		return options.None, fmt.Errorf("unknown compression %q (want none, snappy or zstd)", name)
+	}
+}
+
+func applyLogLevel(opts *badger.Options, level string) error {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "off", "none":
+		opts.Logger = nil
+	case "debug":
+		*opts = opts.WithLoggingLevel(badger.DEBUG)
+	case "info":
+		*opts = opts.WithLoggingLevel(badger.INFO)
+	case "", "warning", "warn":
+		*opts = opts.WithLoggingLevel(badger.WARNING)
+	case "error":
+		*opts = opts.WithLoggingLevel(badger.ERROR)
+	default:
+		return fmt.Errorf("unknown log level %q (want debug, info, warning, error or off)", level)
+	}
+	return nil
+}
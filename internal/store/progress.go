@@ -0,0 +1,27 @@
+package store
+
+import "sync/atomic"
+
+// progressEvery is how many keys a scan reads between progress updates and
+// cancellation checks.
+const progressEvery = 1024
+
+// Progress counts the keys a scan has read so far. The scan adds to it and
+// whoever started it can read it from another goroutine; a nil *Progress
+// counts nothing.
+type Progress struct {
+	scanned atomic.Int64
+}
+
+func (p *Progress) Add(n int) {
+	if p != nil {
+		p.scanned.Add(int64(n))
+	}
+}
+
+func (p *Progress) Scanned() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.scanned.Load()
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/dgraph-io/ristretto/v2/z"
+)
+
+// scanWorkers picks the goroutine count for full scans; 0 means one per CPU.
+func scanWorkers(n int) int {
+	if n <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// scanKeys visits every live key under prefix, as a plain iterator would,
+// but spreads the work over s.scanWorkers goroutines with Badger's Stream.
+// Stream cuts the keyspace into ranges along SSTable and memtable
+// boundaries and hands them out to the workers, so keys come in no
+// particular order. fn gets the worker number so it can keep its own tally
+// without locking; it must not keep item. The scan adds to p as it goes and
+// stops with ctx.Err() once ctx is done.
+//
+// A Stream cannot be given a read timestamp outside managed mode, so while
+// a snapshot is pinned the scan walks the snapshot on one goroutine.
+func (s *BadgerStore) scanKeys(ctx context.Context, prefix []byte, p *Progress, fn func(worker int, item *badger.Item)) error {
+	if snap := s.acquire(); snap != nil {
+		defer s.release(snap)
+		return iterateKeys(ctx, snap.txn, prefix, p, fn)
+	}
+	return s.scanLatest(ctx, prefix, p, fn)
+}
+
+// scanLatest is scanKeys on the latest state, snapshot or not, for the
+// scans that plan a write.
+func (s *BadgerStore) scanLatest(ctx context.Context, prefix []byte, p *Progress, fn func(worker int, item *badger.Item)) error {
+	if s.scanWorkers == 1 {
+		// Stream prefetches every value on its own goroutine, which only
+		// pays off when the ranges run in parallel; alone I iterate keys.
+		return s.db.View(func(txn *badger.Txn) error {
+			return iterateKeys(ctx, txn, prefix, p, fn)
+		})
+	}
+	// seen counts each worker's keys, reported what of them went to p.
+	// FinishThread runs at the end of every range, not once per worker.
+	seen := make([]int, s.scanWorkers)
+	reported := make([]int, s.scanWorkers)
+	report := func(worker int) {
+		p.Add(seen[worker] - reported[worker])
+		reported[worker] = seen[worker]
+	}
+	stream := s.db.NewStream()
+	stream.NumGo = s.scanWorkers
+	stream.Prefix = prefix
+	stream.LogPrefix = "badger-gui.scan"
+	stream.UseKeyToListWithThreadId = true
+	stream.KeyToListWithThreadId = func(_ []byte, itr *badger.Iterator, worker int) (*pb.KVList, error) {
+		if seen[worker]++; seen[worker]-reported[worker] == progressEvery {
+			report(worker)
+		}
+		// Stream only looks at ctx between ranges, so I skip the rest of
+		// the range myself once it is canceled.
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		// Stream walks all versions and starts each key at the newest; a
+		// plain iterator hides the key when that one is deleted or expired.
+		if item := itr.Item(); !item.IsDeletedOrExpired() {
+			fn(worker, item)
+		}
+		return nil, nil
+	}
+	stream.FinishThread = func(worker int) (*pb.KVList, error) {
+		report(worker)
+		return &pb.KVList{}, nil
+	}
+	// I never return KVs, so there is nothing to send.
+	stream.Send = func(*z.Buffer) error { return nil }
+	if err := stream.Orchestrate(ctx); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func iterateKeys(ctx context.Context, txn *badger.Txn, prefix []byte, p *Progress, fn func(worker int, item *badger.Item)) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	n := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		if n++; n%progressEvery == 0 {
+			p.Add(progressEvery)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		fn(0, it.Item())
+	}
+	p.Add(n % progressEvery)
+	return nil
+}
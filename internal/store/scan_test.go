@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func openScan(t testing.TB, dir string, workers int) *BadgerStore {
+	t.Helper()
+	o, err := ProfileOptions(ProfileInspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.LogLevel = "off"
+	o.ScanWorkers = workers
+	st, err := OpenBadger(dir, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// Stream ends every range with FinishThread, and a worker runs many ranges,
+// so the progress of a parallel scan must still add up to the keys seen.
+func TestParallelScanProgress(t *testing.T) {
+	const keys = 15000
+	dir := t.TempDir()
+	writeTables(t, dir, keys)
+	st := openScan(t, dir, 4)
+	defer st.Close()
+	var p Progress
+	n, err := st.CountKeysMatching(context.Background(), KeyQuery{}, Matcher{}, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != keys || p.Scanned() != keys {
+		t.Errorf("counted %d keys with %d scanned, want %d of both", n, p.Scanned(), keys)
+	}
+}
+
+// writeHistory fills dir with keys in three groups, spread over many small
+// tables, that each went through several versions. Some end deleted, some
+// expired, and some with user meta 1. It returns how many are live, by
+// group and with that meta.
+func writeHistory(t testing.TB, dir string, keys int) (groups map[string]int, meta int) {
+	t.Helper()
+	opts := badger.DefaultOptions(dir).WithLogger(nil).
+		WithMemTableSize(1 << 20).WithBaseTableSize(64 << 10).WithValueThreshold(1024)
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("%s:%06d", []string{"user", "order", "item"}[i%3], i))
+	}
+	groups = map[string]int{}
+	past := uint64(time.Now().Add(-time.Hour).Unix())
+	for round := range 3 {
+		wb := db.NewWriteBatch()
+		for i := 0; i < keys; i++ {
+			e := badger.NewEntry(key(i), make([]byte, 200+round))
+			last := round == 2
+			switch {
+			case last && i%7 == 0:
+				err = wb.Delete(key(i))
+				continue
+			case last && i%11 == 0:
+				e.ExpiresAt = past
+			case last && i%5 == 0:
+				e.UserMeta = 1
+			}
+			if err = wb.SetEntry(e); err != nil {
+				t.Fatal(err)
+			}
+			if last && e.ExpiresAt == 0 {
+				groups[keyGroup(string(key(i)))]++
+				if e.UserMeta == 1 {
+					meta++
+				}
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wb.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return groups, meta
+}
+
+// A parallel scan sees the newest version of each key, and hides the
+// deleted and expired ones, just as the serial iterator does.
+func TestParallelScanMatchesSerial(t *testing.T) {
+	dir := t.TempDir()
+	wantGroups, wantMeta := writeHistory(t, dir, 20000)
+	users, err := NewMatcher(MatchGlob, "user:*5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := []struct {
+		q KeyQuery
+		m Matcher
+	}{
+		{KeyQuery{}, Matcher{}},
+		{KeyQuery{HasMeta: true, UserMeta: 1}, Matcher{}},
+		{KeyQuery{Prefix: "user:"}, users},
+	}
+	var serial []int
+	for _, workers := range []int{1, 4} {
+		st := openScan(t, dir, workers)
+		groups, err := st.GroupKeyCounts(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(groups, wantGroups) {
+			t.Errorf("%d workers: groups %v, want %v", workers, groups, wantGroups)
+		}
+		var counts []int
+		for _, c := range queries {
+			n, err := st.CountKeysMatching(context.Background(), c.q, c.m, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts = append(counts, n)
+		}
+		if counts[1] != wantMeta {
+			t.Errorf("%d workers: %d keys with meta 1, want %d", workers, counts[1], wantMeta)
+		}
+		if serial == nil {
+			serial = counts
+		} else if fmt.Sprint(counts) != fmt.Sprint(serial) {
+			t.Errorf("%d workers counted %v, one worker %v", workers, counts, serial)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGroupKeyCounts compares one worker with four; the gap depends on
+// how many cores the machine has.
+func BenchmarkGroupKeyCounts(b *testing.B) {
+	dir := b.TempDir()
+	writeHistory(b, dir, 100000)
+	for _, workers := range []int{1, 4} {
+		st := openScan(b, dir, workers)
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := st.GroupKeyCounts(context.Background(), nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		st.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// snippetContext is how many bytes of value I keep on each side of a match.
+const snippetContext = 24
+
+// ValueSearch looks for Substring, or Regexp when it is set, in the values
+// under Prefix.
+type ValueSearch struct {
+	Prefix    string
+	Substring []byte
+	Regexp    *regexp.Regexp
+}
+
+// ValueMatch is a key whose value matched, with the bytes around the first
+// match. Snippet[MatchStart:MatchEnd] is the match itself.
+type ValueMatch struct {
+	Key        string
+	Snippet    []byte
+	MatchStart int
+	MatchEnd   int
+}
+
+func (vs ValueSearch) find(v []byte) (int, int, bool) {
+	if vs.Regexp != nil {
+		loc := vs.Regexp.FindIndex(v)
+		if loc == nil {
+			return 0, 0, false
+		}
+		return loc[0], loc[1], true
+	}
+	i := bytes.Index(v, vs.Substring)
+	if i < 0 {
+		return 0, 0, false
+	}
+	return i, i + len(vs.Substring), true
+}
+
+// SearchValues scans at most scanLimit keys after startAfter and returns the
+// matches among them, the last key scanned and whether the scan is done.
+// I scan in slices like this so callers can show results while it runs.
+func (s *BadgerStore) SearchValues(ctx context.Context, vs ValueSearch, startAfter string, scanLimit int, p *Progress) ([]ValueMatch, string, bool, error) {
+	var matches []ValueMatch
+	lastKey := startAfter
+	done := true
+	prefix := []byte(vs.Prefix)
+	err := s.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		if startAfter == "" {
+			it.Seek(prefix)
+		} else {
+			after := []byte(startAfter)
+			it.Seek(after)
+			if it.Valid() && bytes.Equal(it.Item().Key(), after) {
+				it.Next()
+			}
+		}
+
+		scanned := 0
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if scanned >= scanLimit {
+				done = false
+				break
+			}
+			if scanned++; scanned%progressEvery == 0 {
+				p.Add(progressEvery)
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			lastKey = string(item.Key())
+			err := item.Value(func(v []byte) error {
+				start, end, ok := vs.find(v)
+				if !ok {
+					return nil
+				}
+				from := max(0, start-snippetContext)
+				to := min(len(v), end+snippetContext)
+				matches = append(matches, ValueMatch{
+					Key:        lastKey,
+					Snippet:    append([]byte(nil), v[from:to]...),
+					MatchStart: start - from,
+					MatchEnd:   end - from,
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		p.Add(scanned % progressEvery)
+		return nil
+	})
+	return matches, lastKey, done, err
+}
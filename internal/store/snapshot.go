@@ -0,0 +1,94 @@
+package store
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+// A pinned snapshot is one long-lived read transaction that paging, value
+// loads and scans share, so they all see the DB as of its read timestamp.
+// Writes, and the reads that decide what a write does, still see the latest
+// state. A read-only Badger transaction keeps no state but an atomic
+// iterator count, so the UI and the jobs use it side by side; users only
+// keeps it from being discarded under them when it is re-pinned.
+type snapshot struct {
+	txn     *badger.Txn
+	users   int
+	retired bool
+}
+
+// Pin holds a read transaction at the latest state and returns its read
+// timestamp. Pinning again moves the snapshot to the latest state.
+//
+// While a snapshot is pinned, compactions keep the versions it can see, so
+// it should not be held longer than needed.
+func (s *BadgerStore) Pin() uint64 {
+	txn := s.db.NewTransaction(false)
+	s.snapMu.Lock()
+	old := s.snap
+	s.snap = &snapshot{txn: txn}
+	s.snapMu.Unlock()
+	s.retire(old)
+	return txn.ReadTs()
+}
+
+// Unpin lets go of the snapshot; reads see the latest state again.
+func (s *BadgerStore) Unpin() {
+	s.snapMu.Lock()
+	old := s.snap
+	s.snap = nil
+	s.snapMu.Unlock()
+	s.retire(old)
+}
+
+// PinnedAt returns the read timestamp of the pinned snapshot, if any.
+func (s *BadgerStore) PinnedAt() (uint64, bool) {
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if s.snap == nil {
+		return 0, false
+	}
+	return s.snap.txn.ReadTs(), true
+}
+
+// view runs fn in the pinned snapshot, or in a read transaction of its own
+// when there is none.
+func (s *BadgerStore) view(fn func(txn *badger.Txn) error) error {
+	snap := s.acquire()
+	if snap == nil {
+		return s.db.View(fn)
+	}
+	defer s.release(snap)
+	if s.db.IsClosed() {
+		return badger.ErrDBClosed
+	}
+	return fn(snap.txn)
+}
+
+func (s *BadgerStore) acquire() *snapshot {
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if s.snap != nil {
+		s.snap.users++
+	}
+	return s.snap
+}
+
+func (s *BadgerStore) release(snap *snapshot) {
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if snap.users--; snap.users == 0 && snap.retired {
+		snap.txn.Discard()
+	}
+}
+
+func (s *BadgerStore) retire(snap *snapshot) {
+	if snap == nil {
+		return
+	}
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	snap.retired = true
+	if snap.users == 0 {
+		snap.txn.Discard()
+	}
+}
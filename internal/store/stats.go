@@ -0,0 +1,137 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4/y"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// Stats is what the statistics dashboard shows: the shape of the LSM tree,
+// how far compaction is behind, and how well the caches do.
+type Stats struct {
+	At         time.Time
+	LSMSize    int64 // as Badger last measured it, about once a minute
+	VlogSize   int64
+	MaxVersion uint64
+	Levels     []LevelStats
+	Tables     []TableStats // by level, then by smallest key
+	BlockCache CacheStats
+	IndexCache CacheStats
+	// Compactors is how many compaction goroutines run; none do when the
+	// DB is opened read-only.
+	Compactors int
+	// Pending counts the levels Badger will compact next: those whose
+	// adjusted score is at least 1.
+	Pending int
+}
+
+type LevelStats struct {
+	Level      int
+	Tables     int
+	Keys       uint64
+	Size       int64
+	TargetSize int64
+	StaleSize  int64
+	Base       bool // the level L0 compacts into
+	// Fill is how full the level is against what triggers its compaction:
+	// the table count for L0, the live size against the target below it.
+	Fill float64
+	// Adjusted is Badger's compaction priority; it only sets it for the
+	// levels that are due, where it is at least 1.
+	Adjusted float64
+}
+
+type TableStats struct {
+	ID               uint64
+	Level            int
+	Smallest         string
+	Biggest          string
+	Keys             uint32
+	Size             int64
+	UncompressedSize int64
+	StaleSize        int64
+	MaxVersion       uint64
+}
+
+type CacheStats struct {
+	Enabled bool
+	MaxCost int64
+	Hits    uint64
+	Misses  uint64
+	Ratio   float64
+}
+
+func (s *BadgerStore) Stats() Stats {
+	st := Stats{At: time.Now(), MaxVersion: s.db.MaxVersion()}
+	st.LSMSize, st.VlogSize = s.db.Size()
+	opts := s.db.Opts()
+	if !opts.ReadOnly {
+		st.Compactors = opts.NumCompactors
+	}
+	tables := s.db.Tables()
+	keys := map[int]uint64{}
+	st.Tables = make([]TableStats, len(tables))
+	for i, t := range tables {
+		keys[t.Level] += uint64(t.KeyCount)
+		st.Tables[i] = TableStats{
+			ID:               t.ID,
+			Level:            t.Level,
+			Smallest:         tableKey(t.Left),
+			Biggest:          tableKey(t.Right),
+			Keys:             t.KeyCount,
+			Size:             int64(t.OnDiskSize),
+			UncompressedSize: int64(t.UncompressedSize),
+			StaleSize:        int64(t.StaleDataSize),
+			MaxVersion:       t.MaxVersion,
+		}
+	}
+	sort.SliceStable(st.Tables, func(i, j int) bool {
+		a, b := st.Tables[i], st.Tables[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return a.Smallest < b.Smallest
+	})
+	for _, l := range s.db.Levels() {
+		ls := LevelStats{
+			Level:      l.Level,
+			Tables:     l.NumTables,
+			Keys:       keys[l.Level],
+			Size:       l.Size,
+			TargetSize: l.TargetSize,
+			StaleSize:  l.StaleDatSize,
+			Base:       l.IsBaseLevel,
+			Adjusted:   l.Adjusted,
+		}
+		switch {
+		case l.Level == 0 && opts.NumLevelZeroTables > 0:
+			ls.Fill = float64(l.NumTables) / float64(opts.NumLevelZeroTables)
+		case l.Level > 0 && l.TargetSize > 0:
+			ls.Fill = float64(l.Size-l.StaleDatSize) / float64(l.TargetSize)
+		}
+		st.Levels = append(st.Levels, ls)
+		if l.Adjusted >= 1 {
+			st.Pending++
+		}
+	}
+	st.BlockCache = cacheStats(s.db.BlockCacheMetrics(), opts.BlockCacheSize)
+	st.IndexCache = cacheStats(s.db.IndexCacheMetrics(), opts.IndexCacheSize)
+	return st
+}
+
+// tableKey drops the version Badger appends to the keys it keeps in tables.
+func tableKey(k []byte) string {
+	if len(k) < 8 {
+		return string(k)
+	}
+	return string(y.ParseKey(k))
+}
+
+func cacheStats(m *ristretto.Metrics, size int64) CacheStats {
+	if m == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Enabled: true, MaxCost: size, Hits: m.Hits(), Misses: m.Misses(), Ratio: m.Ratio()}
+}
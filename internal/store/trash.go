@@ -0,0 +1,614 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Trash entry kinds, after the change that captured them.
+const (
+	TrashDelete        = "delete"
+	TrashPatternDelete = "pattern delete"
+	TrashSave          = "save"
+	TrashImport        = "import"
+	TrashRestore       = "restore"
+)
+
+// ErrTrashDisabled is returned when there is no journal, as with an in-memory DB.
+var ErrTrashDisabled = errors.New("no undo journal for this database")
+
+// TrashPath is where the undo journal of the DB at dbPath lives: next to
+// the DB directory, never inside it, so Badger does not trip over it.
+func TrashPath(dbPath string) string {
+	return filepath.Clean(dbPath) + ".trash.jsonl"
+}
+
+// TrashEntry is a key as it was just before a delete or save changed it.
+// Entries that one change captured share an Op.
+type TrashEntry struct {
+	ID   int
+	Op   int
+	Kind string
+	At   time.Time
+	Key  string
+	// Existed is false when a save created the key; restoring it deletes it.
+	Existed   bool
+	Value     []byte // only filled in by the restore path; see Size
+	Size      int
+	ExpiresAt uint64
+	UserMeta  byte
+	Restored  bool
+}
+
+// journalRecord is one line of the journal. Keys and values are bytes so
+// binary data survives JSON. A line with Restored or Void only marks earlier
+// entries: Void those captured for a write that then failed.
+type journalRecord struct {
+	ID        int       `json:"id,omitempty"`
+	Op        int       `json:"op,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	At        time.Time `json:"at"`
+	Key       []byte    `json:"key,omitempty"`
+	Existed   bool      `json:"existed,omitempty"`
+	Value     []byte    `json:"value,omitempty"`
+	ExpiresAt uint64    `json:"expires_at,omitempty"`
+	UserMeta  byte      `json:"user_meta,omitempty"`
+	Restored  []int     `json:"restored,omitempty"`
+	Void      []int     `json:"void,omitempty"`
+}
+
+// journalLine is an entry as the index holds it: without its value, which
+// stays in the file at off.
+type journalLine struct {
+	TrashEntry
+	void bool
+	off  int64
+	n    int
+}
+
+// trashJournal is an append-only JSONL file. When the DB is encrypted each
+// line is sealed with the same key, so old values do not leak to disk in
+// the clear.
+type trashJournal struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD
+	// index lists the entries oldest first and size is how much of the
+	// file it covers. I decode the file once and after that only what was
+	// appended, so the trash and undo stay cheap however long the journal
+	// grows; values are read back by offset when they are restored.
+	index  []journalLine
+	byID   map[int]int
+	size   int64
+	nextID int
+	nextOp int
+}
+
+func openTrash(path string, key []byte) (*trashJournal, error) {
+	if path == "" {
+		return nil, nil
+	}
+	t := &trashJournal{path: path}
+	if len(key) > 0 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("undo journal: %w", err)
+		}
+		if t.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("undo journal: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// beginOp hands out the Op for one change; the caller holds t.mu, as for
+// every method below.
+func (t *trashJournal) beginOp() (int, error) {
+	if err := t.refresh(); err != nil {
+		return 0, err
+	}
+	t.nextOp++
+	return t.nextOp, nil
+}
+
+// capture records entries under op and syncs the file before returning, so
+// the change they precede can be undone even after a crash. It returns the
+// ids it gave them, also when it fails, as some may have been written.
+func (t *trashJournal) capture(op int, kind string, entries []TrashEntry) ([]int, error) {
+	if err := t.refresh(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	recs := make([]journalRecord, len(entries))
+	ids := make([]int, len(entries))
+	for i, e := range entries {
+		t.nextID++
+		ids[i] = t.nextID
+		recs[i] = journalRecord{
+			ID: t.nextID, Op: op, Kind: kind, At: now,
+			Key: []byte(e.Key), Existed: e.Existed, Value: e.Value,
+			ExpiresAt: e.ExpiresAt, UserMeta: e.UserMeta,
+		}
+	}
+	return ids, t.append(recs)
+}
+
+func (t *trashJournal) markRestored(ids []int) error {
+	return t.append([]journalRecord{{At: time.Now().UTC(), Restored: ids}})
+}
+
+// void takes entries out of the trash and out of Undo's reach: they were
+// captured for a write that did not go through, and restoring them later
+// would put back values that are stale by then.
+func (t *trashJournal) void(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return t.append([]journalRecord{{At: time.Now().UTC(), Void: ids}})
+}
+
+func (t *trashJournal) append(recs []journalRecord) error {
+	if err := t.refresh(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	// I write after the last whole line, over one a crash cut short.
+	if err := f.Truncate(t.size); err != nil {
+		f.Close()
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	if _, err := f.Seek(t.size, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range recs {
+		line, err := t.encode(r)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	return t.refresh()
+}
+
+func (t *trashJournal) encode(r journalRecord) ([]byte, error) {
+	line, err := json.Marshal(r)
+	if err != nil || t.aead == nil {
+		return line, err
+	}
+	nonce := make([]byte, t.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := t.aead.Seal(nonce, nonce, line, nil)
+	return base64.StdEncoding.AppendEncode(nil, sealed), nil
+}
+
+func (t *trashJournal) decode(line []byte) (journalRecord, error) {
+	var r journalRecord
+	if t.aead != nil {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		n := t.aead.NonceSize()
+		if err != nil || len(sealed) < n {
+			return r, errors.New("undo journal: line is not encrypted with this key")
+		}
+		if line, err = t.aead.Open(nil, sealed[:n], sealed[n:], nil); err != nil {
+			return r, errors.New("undo journal: line is not encrypted with this key")
+		}
+	}
+	if err := json.Unmarshal(line, &r); err != nil {
+		return r, fmt.Errorf("undo journal: %w", err)
+	}
+	return r, nil
+}
+
+// refresh indexes what was appended to the file since the last call. A
+// file that shrank was replaced, so I index it again from the start; a
+// missing file is empty.
+func (t *trashJournal) refresh() error {
+	info, err := os.Stat(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.index, t.byID, t.size = nil, nil, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	if info.Size() < t.size {
+		t.index, t.byID, t.size = nil, nil, 0
+	}
+	if info.Size() == t.size {
+		return nil
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.size, io.SeekStart); err != nil {
+		return fmt.Errorf("undo journal: %w", err)
+	}
+	rd := bufio.NewReader(f)
+	for {
+		line, err := rd.ReadBytes('\n')
+		// A line cut short by a crash has no newline; I leave it out.
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			if len(line) > 1 {
+				r, derr := t.decode(line[:len(line)-1])
+				if derr != nil {
+					return derr
+				}
+				t.add(r, t.size, len(line)-1)
+			}
+			t.size += int64(len(line))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("undo journal: %w", err)
+		}
+	}
+}
+
+func (t *trashJournal) add(r journalRecord, off int64, n int) {
+	if t.byID == nil {
+		t.byID = map[int]int{}
+	}
+	for _, id := range r.Restored {
+		if i, ok := t.byID[id]; ok {
+			t.index[i].Restored = true
+		}
+	}
+	for _, id := range r.Void {
+		if i, ok := t.byID[id]; ok {
+			t.index[i].void = true
+		}
+	}
+	t.nextID = max(t.nextID, r.ID)
+	t.nextOp = max(t.nextOp, r.Op)
+	if r.ID == 0 {
+		return
+	}
+	t.byID[r.ID] = len(t.index)
+	t.index = append(t.index, journalLine{
+		TrashEntry: TrashEntry{
+			ID: r.ID, Op: r.Op, Kind: r.Kind, At: r.At, Key: string(r.Key),
+			Existed: r.Existed, Size: len(r.Value), ExpiresAt: r.ExpiresAt, UserMeta: r.UserMeta,
+		},
+		off: off,
+		n:   n,
+	})
+}
+
+// entries lists the journal oldest first, without values and without the
+// entries that were voided.
+func (t *trashJournal) entries() ([]TrashEntry, error) {
+	if err := t.refresh(); err != nil {
+		return nil, err
+	}
+	out := make([]TrashEntry, 0, len(t.index))
+	for _, l := range t.index {
+		if !l.void {
+			out = append(out, l.TrashEntry)
+		}
+	}
+	return out, nil
+}
+
+// lookup returns the entries with the given ids, values included; an id
+// the journal does not have is left out.
+func (t *trashJournal) lookup(ids []int) ([]TrashEntry, error) {
+	if err := t.refresh(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("undo journal: %w", err)
+	}
+	defer f.Close()
+	out := make([]TrashEntry, 0, len(ids))
+	var buf []byte
+	for _, id := range ids {
+		i, ok := t.byID[id]
+		if !ok {
+			continue
+		}
+		l := t.index[i]
+		buf = slices.Grow(buf[:0], l.n)[:l.n]
+		if _, err := f.ReadAt(buf, l.off); err != nil {
+			return nil, fmt.Errorf("undo journal: %w", err)
+		}
+		r, err := t.decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		e := l.TrashEntry
+		e.Value = r.Value
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// picked returns the entries pick chooses that are not restored yet, with
+// their values.
+func (t *trashJournal) picked(pick func(TrashEntry) bool) ([]TrashEntry, error) {
+	all, err := t.entries()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, e := range all {
+		if !e.Restored && pick(e) {
+			ids = append(ids, e.ID)
+		}
+	}
+	return t.lookup(ids)
+}
+
+// restoreChunk is how many entries one restore transaction writes.
+const restoreChunk = 256
+
+// ErrNothingToUndo is returned by Undo when every change was restored.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+func oldState(txn *badger.Txn, key string) (TrashEntry, error) {
+	item, err := txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return TrashEntry{Key: key}, nil
+	}
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	return trashEntry(item)
+}
+
+func trashEntry(item *badger.Item) (TrashEntry, error) {
+	value, err := item.ValueCopy(nil)
+	return TrashEntry{
+		Key:       string(item.Key()),
+		Existed:   true,
+		Value:     value,
+		ExpiresAt: item.ExpiresAt(),
+		UserMeta:  item.UserMeta(),
+	}, err
+}
+
+// capture journals entries as one change. Without a journal it does nothing.
+func (s *BadgerStore) capture(kind string, entries ...TrashEntry) ([]int, error) {
+	op, err := s.trashOp()
+	if err != nil {
+		return nil, err
+	}
+	return s.captureOp(op, kind, entries)
+}
+
+// trashOp starts a change whose entries are captured in several steps.
+func (s *BadgerStore) trashOp() (int, error) {
+	if s.trash == nil {
+		return 0, nil
+	}
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	return s.trash.beginOp()
+}
+
+// captureOp returns the ids of the entries, for discard when the write they
+// precede fails.
+func (s *BadgerStore) captureOp(op int, kind string, entries []TrashEntry) ([]int, error) {
+	if s.trash == nil || len(entries) == 0 {
+		return nil, nil
+	}
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	return s.trash.capture(op, kind, entries)
+}
+
+// discard voids the entries captured for a transaction that failed, so
+// wrote nothing, and returns err.
+func (s *BadgerStore) discard(ids []int, err error) error {
+	if s.trash == nil || len(ids) == 0 {
+		return err
+	}
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	if verr := s.trash.void(ids); verr != nil {
+		return errors.Join(err, verr)
+	}
+	return err
+}
+
+// discardUnchanged is discard for a write batch or a prefix drop, which can
+// fail after writing part of what they were given: I only void the entries
+// whose key is still the way they captured it.
+func (s *BadgerStore) discardUnchanged(ids []int, err error) error {
+	if s.trash == nil || len(ids) == 0 {
+		return err
+	}
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	var verr error
+	for start := 0; start < len(ids) && verr == nil; start += restoreChunk {
+		var entries []TrashEntry
+		if entries, verr = s.trash.lookup(ids[start:min(start+restoreChunk, len(ids))]); verr != nil {
+			break
+		}
+		var void []int
+		verr = s.db.View(func(txn *badger.Txn) error {
+			for _, e := range entries {
+				now, err := oldState(txn, e.Key)
+				if err != nil {
+					return err
+				}
+				if sameState(now, e) {
+					void = append(void, e.ID)
+				}
+			}
+			return nil
+		})
+		if verr == nil {
+			verr = s.trash.void(void)
+		}
+	}
+	if verr != nil {
+		return errors.Join(err, verr)
+	}
+	return err
+}
+
+func sameState(a, b TrashEntry) bool {
+	if a.Existed != b.Existed {
+		return false
+	}
+	return !a.Existed || bytes.Equal(a.Value, b.Value) && a.ExpiresAt == b.ExpiresAt && a.UserMeta == b.UserMeta
+}
+
+// Trash lists the undo journal, oldest entry first, without values.
+func (s *BadgerStore) Trash() ([]TrashEntry, error) {
+	if s.trash == nil {
+		return nil, ErrTrashDisabled
+	}
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	return s.trash.entries()
+}
+
+// Restore puts the keys of the given entries back the way they were
+// captured; an entry a save created is deleted again. A TTL that has passed
+// since is cleared, or the key would vanish straight away. Restores are
+// journaled too, so they can be undone from the trash.
+func (s *BadgerStore) Restore(ids []int) ([]TrashEntry, error) {
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	return s.restoreWhere(func(e TrashEntry) bool { return want[e.ID] })
+}
+
+// Undo restores the latest change that is not restored yet. Restores are
+// skipped, so each Undo goes one change further back.
+func (s *BadgerStore) Undo() ([]TrashEntry, error) {
+	all, err := s.Trash()
+	if err != nil {
+		return nil, err
+	}
+	op := 0
+	for i := len(all) - 1; i >= 0 && op == 0; i-- {
+		if e := all[i]; e.Kind != TrashRestore && !e.Restored {
+			op = e.Op
+		}
+	}
+	if op == 0 {
+		return nil, ErrNothingToUndo
+	}
+	return s.restoreWhere(func(e TrashEntry) bool { return e.Op == op })
+}
+
+func (s *BadgerStore) restoreWhere(pick func(TrashEntry) bool) ([]TrashEntry, error) {
+	if s.trash == nil {
+		return nil, ErrTrashDisabled
+	}
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.trash.mu.Lock()
+	entries, err := s.trash.picked(pick)
+	s.trash.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := s.trashOp()
+	if err != nil {
+		return nil, err
+	}
+	now := uint64(time.Now().Unix())
+	var done []TrashEntry
+	var notLogged error
+	for start := 0; start < len(entries); start += restoreChunk {
+		chunk := entries[start:min(start+restoreChunk, len(entries))]
+		audits := make([]AuditEntry, 0, len(chunk))
+		var captured []int
+		err := s.db.Update(func(txn *badger.Txn) error {
+			audits = audits[:0]
+			olds := make([]TrashEntry, 0, len(chunk))
+			for _, e := range chunk {
+				var old TrashEntry
+				var err error
+				if e.Existed {
+					o := SetOptions{ExpiresAt: e.ExpiresAt, UserMeta: e.UserMeta}
+					if o.ExpiresAt != 0 && o.ExpiresAt <= now {
+						o.ExpiresAt = 0
+					}
+					old, err = s.set(txn, e.Key, e.Value, o)
+				} else {
+					old, err = s.delete(txn, e.Key)
+				}
+				if err != nil {
+					return err
+				}
+				if old.Existed || e.Existed {
+					olds = append(olds, old)
+				}
+				audits = append(audits, auditChange(AuditRestore, old, e.Value, e.Existed))
+			}
+			var err error
+			captured, err = s.captureOp(op, TrashRestore, olds)
+			return err
+		})
+		if err != nil {
+			return done, s.discard(captured, err)
+		}
+		ids := make([]int, len(chunk))
+		for i, e := range chunk {
+			ids[i] = e.ID
+			chunk[i].Value = nil
+		}
+		s.trash.mu.Lock()
+		err = s.trash.markRestored(ids)
+		s.trash.mu.Unlock()
+		if err != nil {
+			return done, err
+		}
+		done = append(done, chunk...)
+		if err := s.audit(audits...); err != nil && notLogged == nil {
+			notLogged = auditErr(err)
+		}
+	}
+	return done, notLogged
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func openJournaled(t *testing.T) *BadgerStore {
+	t.Helper()
+	dir := t.TempDir()
+	o, err := ProfileOptions(ProfileInspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ReadOnly = false
+	o.LogLevel = "off"
+	o.TrashPath = filepath.Join(dir, "db.trash.jsonl")
+	st, err := OpenBadger(filepath.Join(dir, "db"), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func trashKeys(t *testing.T, st *BadgerStore, op int) []string {
+	t.Helper()
+	all, err := st.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range all {
+		if e.Op == op {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+// A write batch that fails can still have written part of its keys. The
+// entries of the keys it did not change must not be undoable, or Undo
+// would put back what they held when the batch ran over later edits.
+func TestFailedWriteLeavesNothingToUndo(t *testing.T) {
+	st := openJournaled(t)
+	if err := st.Set("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Set("a", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	op, err := st.trashOp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	olds := []TrashEntry{{Key: "a", Existed: true, Value: []byte("2")}, {Key: "b"}}
+	ids, err := st.captureOp(op, TrashImport, olds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only b made it before the batch failed.
+	err = st.db.Update(func(txn *badger.Txn) error { return txn.Set([]byte("b"), []byte("x")) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	if err := st.discardUnchanged(ids, boom); !errors.Is(err, boom) {
+		t.Fatalf("discardUnchanged returned %v, want %v", err, boom)
+	}
+	if got := trashKeys(t, st, op); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("trash of the failed import has %q, want only b", got)
+	}
+
+	if _, err := st.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Get("b"); !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("b after undo: %v, want it deleted", err)
+	}
+	if v, err := st.Get("a"); err != nil || string(v) != "2" {
+		t.Errorf("a after undo = %q, %v; want 2", v, err)
+	}
+
+	// A failed transaction wrote nothing, so all its entries go.
+	op, err = st.trashOp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = st.captureOp(op, TrashSave, []TrashEntry{{Key: "a", Existed: true, Value: []byte("2")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.discard(ids, boom); !errors.Is(err, boom) {
+		t.Fatalf("discard returned %v, want %v", err, boom)
+	}
+	if got := trashKeys(t, st, op); len(got) != 0 {
+		t.Fatalf("trash of the failed save has %q, want nothing", got)
+	}
+
+	if _, err := st.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := st.Get("a"); err != nil || string(v) != "1" {
+		t.Errorf("a after the second undo = %q, %v; want 1", v, err)
+	}
+}
+
+// The index follows what is appended, drops a line a crash cut short and
+// reads the same entries as a journal opened afresh.
+func TestTrashIndexFollowsAppends(t *testing.T) {
+	st := openJournaled(t)
+	for _, v := range []string{"1", "2", "3"} {
+		if err := st.Set("k", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Trash(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(st.trash.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"id":99,"op":99,"kind":"sa`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := st.Set("k", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := openTrash(st.trash.path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := st.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fresh.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || len(want) != 4 {
+		t.Fatalf("got %d entries afresh and %d indexed, want 4", len(got), len(want))
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("entry %d: %+v afresh, %+v indexed", i, got[i], want[i])
+		}
+	}
+	vals, err := fresh.lookup([]int{got[1].ID, got[3].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 2 || string(vals[0].Value) != "1" || string(vals[1].Value) != "3" {
+		t.Errorf("values read back: %+v", vals)
+	}
+}
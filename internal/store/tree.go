@@ -0,0 +1,115 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// TreeNode is one child of a prefix in the key tree. Prefix is the full key
+// prefix up to and including the delimiter; for a leaf it is the whole key.
+type TreeNode struct {
+	Prefix string
+	Count  int
+	Leaf   bool
+}
+
+// ListChildren pages the direct children of prefix, splitting keys on any
+// byte in delims. Keys under one child are contiguous, so each page is one
+// prefix iteration; counting a child walks its keys without loading values.
+// startAfter is the last child Prefix of the previous page. p counts the
+// keys walked, and the walk stops with ctx.Err() once ctx is done.
+func (s *BadgerStore) ListChildren(ctx context.Context, prefix, delims, startAfter string, limit int, p *Progress) ([]TreeNode, bool, error) {
+	if limit <= 0 {
+		return nil, false, nil
+	}
+	var nodes []TreeNode
+	var hasMore bool
+	pfx := []byte(prefix)
+	err := s.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = pfx
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		switch after := []byte(startAfter); {
+		case startAfter == "":
+			it.Seek(pfx)
+		case len(after) > len(pfx) && strings.ContainsRune(delims, rune(after[len(after)-1])):
+			// I skip every key under the last prefix in one seek. A key
+			// equal to the parent prefix ends in a delimiter too, but it is
+			// a leaf with every other child under it.
+			next := prefixSuccessor(after)
+			if next == nil {
+				return nil
+			}
+			it.Seek(next)
+		default:
+			it.Seek(after)
+			if it.Valid() && bytes.Equal(it.Item().Key(), after) {
+				it.Next()
+			}
+		}
+
+		scanned := 0
+		defer func() { p.Add(scanned % progressEvery) }()
+		for ; it.ValidForPrefix(pfx); it.Next() {
+			// Counting a wide prefix reads every key under it.
+			if scanned++; scanned%progressEvery == 0 {
+				p.Add(progressEvery)
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			key := it.Item().Key()
+			if n := len(nodes); n > 0 && !nodes[n-1].Leaf && bytes.HasPrefix(key, []byte(nodes[n-1].Prefix)) {
+				nodes[n-1].Count++
+				continue
+			}
+			if len(nodes) >= limit {
+				hasMore = true
+				break
+			}
+			child, leaf := childOf(key, len(pfx), delims)
+			nodes = append(nodes, TreeNode{Prefix: string(child), Count: 1, Leaf: leaf})
+		}
+		return nil
+	})
+	return nodes, hasMore, err
+}
+
+// childOf cuts key after the first delimiter past the parent prefix.
+func childOf(key []byte, from int, delims string) ([]byte, bool) {
+	if idx := bytes.IndexAny(key[from:], delims); idx >= 0 {
+		return key[:from+idx+1], false
+	}
+	return key, true
+}
+
+// prefixSuccessor returns the smallest key greater than every key that
+// starts with p, or nil when there is none.
+func prefixSuccessor(p []byte) []byte {
+	out := append([]byte(nil), p...)
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i] < 0xff {
+			out[i]++
+			return out[:i+1]
+		}
+	}
+	return nil
+}
+
+// ParentPrefix strips the last segment: "a/b/c/" and "a/b/cd" both give "a/b/".
+func ParentPrefix(prefix, delims string) string {
+	trimmed := prefix
+	if trimmed != "" && strings.ContainsRune(delims, rune(trimmed[len(trimmed)-1])) {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	if idx := strings.LastIndexAny(trimmed, delims); idx >= 0 {
+		return trimmed[:idx+1]
+	}
+	return ""
+}
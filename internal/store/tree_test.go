@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var treeKeys = []string{
+	"a/", "a/b/1", "a/b/2", "a/b:3", "a/c:1", "a/c:2/x", "a/d", "a/e/", "a/e/x", "a:f", "b",
+}
+
+// treeAll pages the children of prefix limit at a time.
+func treeAll(t *testing.T, st *BadgerStore, prefix, delims string, limit int) string {
+	t.Helper()
+	var out []string
+	after := ""
+	for range 100 {
+		nodes, more, err := st.ListChildren(context.Background(), prefix, delims, after, limit, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range nodes {
+			leaf := ""
+			if n.Leaf {
+				leaf = " leaf"
+			}
+			out = append(out, fmt.Sprintf("%s=%d%s", n.Prefix, n.Count, leaf))
+		}
+		if !more {
+			return strings.Join(out, ", ")
+		}
+		after = nodes[len(nodes)-1].Prefix
+	}
+	t.Fatalf("%q: paging does not end", prefix)
+	return ""
+}
+
+func TestListChildren(t *testing.T) {
+	st := openMemory(t)
+	setKeys(t, st, treeKeys...)
+	tests := []struct {
+		prefix, delims string
+		want           string
+	}{
+		{"", "/", "a/=9, a:f=1 leaf, b=1 leaf"},
+		{"", "/:", "a/=9, a:=1, b=1 leaf"},
+		// The key equal to the prefix is a leaf of its own, and a page that
+		// ends on it still goes on to the rest.
+		{"a/", "/", "a/=1 leaf, a/b/=2, a/b:3=1 leaf, a/c:1=1 leaf, a/c:2/=1, a/d=1 leaf, a/e/=2"},
+		{"a/", "/:", "a/=1 leaf, a/b/=2, a/b:=1, a/c:=2, a/d=1 leaf, a/e/=2"},
+		{"a/", ":", "a/=1 leaf, a/b/1=1 leaf, a/b/2=1 leaf, a/b:=1, a/c:=2, a/d=1 leaf, a/e/=1 leaf, a/e/x=1 leaf"},
+		{"a/c:", "/:", "a/c:1=1 leaf, a/c:2/=1"},
+		{"a/e/", "/", "a/e/=1 leaf, a/e/x=1 leaf"},
+		{"c", "/", ""},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 100} {
+			if got := treeAll(t, st, tt.prefix, tt.delims, limit); got != tt.want {
+				t.Errorf("%q split on %q by %d: %s, want %s", tt.prefix, tt.delims, limit, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParentPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, delims, want string
+	}{
+		{"a/b/c/", "/", "a/b/"},
+		{"a/b/cd", "/", "a/b/"},
+		{"a/", "/", ""},
+		{"a", "/", ""},
+		{"", "/", ""},
+		{"/", "/", ""},
+		{"a:b/c:", "/:", "a:b/"},
+		{"a:b/c", "/:", "a:b/"},
+		{"a:b/c", "/", "a:b/"},
+		{"a:b/c", ":", "a:"},
+	}
+	for _, tt := range tests {
+		if got := ParentPrefix(tt.prefix, tt.delims); got != tt.want {
+			t.Errorf("ParentPrefix(%q, %q) = %q, want %q", tt.prefix, tt.delims, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"badge-reader/internal/store"
+)
+
+// Source is what an export reads from; the store implements it.
+type Source interface {
+	EachEntry(ctx context.Context, q store.KeyQuery, m *store.Matcher, p *store.Progress, fn func(key []byte, e store.Entry) error) error
+}
+
+// ExportOptions selects the keys to export and how to write them. The zero
+// value exports the whole DB as JSONL with text values.
+type ExportOptions struct {
+	Query    store.KeyQuery
+	Matcher  *store.Matcher // nil exports every key of Query
+	Format   Format
+	Encoding Encoding
+	// Flatten gives each field of a JSON object value its own CSV column,
+	// named value.<path>; other values stay in the value column.
+	Flatten bool
+	// Overwrite lets ExportFile replace a file that exists.
+	Overwrite bool
+}
+
+// Record is one JSONL line. A key that is not UTF-8 goes in KeyBase64.
+type Record struct {
+	Key       string          `json:"key,omitempty"`
+	KeyBase64 string          `json:"key_base64,omitempty"`
+	Value     json.RawMessage `json:"value"`
+	Encoding  string          `json:"encoding"`
+	ExpiresAt uint64          `json:"expires_at,omitempty"`
+	UserMeta  byte            `json:"user_meta,omitempty"`
+}
+
+// csvColumns come first in every CSV export, in this order.
+var csvColumns = []string{"key", "key_encoding", "value", "encoding", "expires_at", "user_meta"}
+
+// Export streams the selected entries to w and returns how many it wrote.
+// Only one value is held at a time, whatever the size of the DB.
+func Export(ctx context.Context, src Source, w io.Writer, o ExportOptions, p *store.Progress) (int, error) {
+	bw := bufio.NewWriter(w)
+	var n int
+	var err error
+	switch o.Format {
+	case FormatCSV:
+		n, err = exportCSV(ctx, src, bw, o, p)
+	default:
+		n, err = exportJSONL(ctx, src, bw, o, p)
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
+
+// ExportFile exports to a new file at path, and refuses a file that exists
+// unless o.Overwrite is set. A failed or canceled export removes the file
+// it created, so a partial one is never mistaken for a full export, and
+// leaves a file it was to replace as it was.
+func ExportFile(ctx context.Context, src Source, path string, o ExportOptions, p *store.Progress) (int, error) {
+	if o.Overwrite {
+		return exportReplacing(ctx, src, path, o, p)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := Export(ctx, src, f, o, p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return n, err
+}
+
+// exportReplacing writes next to path and renames the finished export over
+// it.
+func exportReplacing(ctx context.Context, src Source, path string, o ExportOptions, p *store.Progress) (int, error) {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.partial")
+	if err != nil {
+		return 0, err
+	}
+	n, err := Export(ctx, src, f, o, p)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return n, err
+}
+
+func exportJSONL(ctx context.Context, src Source, w io.Writer, o ExportOptions, p *store.Progress) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+	err := src.EachEntry(ctx, o.Query, o.Matcher, p, func(key []byte, e store.Entry) error {
+		r := Record{ExpiresAt: e.ExpiresAt, UserMeta: e.UserMeta}
+		if k, binary := encodeKey(key); binary {
+			r.KeyBase64 = k
+		} else {
+			r.Key = k
+		}
+		cell, used := encodeValue(e.Value, o.Encoding)
+		r.Encoding = used.String()
+		if used == EncodingJSON {
+			r.Value = json.RawMessage(cell)
+		} else {
+			r.Value, _ = json.Marshal(cell)
+		}
+		n++
+		return enc.Encode(r)
+	})
+	return n, err
+}
+
+func exportCSV(ctx context.Context, src Source, w io.Writer, o ExportOptions, p *store.Progress) (int, error) {
+	header := append([]string(nil), csvColumns...)
+	fields := map[string]int{}
+	if o.Flatten {
+		// A CSV header comes first, so a first pass collects the fields.
+		paths := map[string]bool{}
+		err := src.EachEntry(ctx, o.Query, o.Matcher, p, func(_ []byte, e store.Entry) error {
+			if obj, ok := jsonObject(e.Value); ok {
+				flatten("", obj, func(path, _ string) { paths[path] = true })
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		sorted := make([]string, 0, len(paths))
+		for path := range paths {
+			sorted = append(sorted, path)
+		}
+		sort.Strings(sorted)
+		for _, path := range sorted {
+			fields[path] = len(header)
+			header = append(header, "value."+path)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	n := 0
+	row := make([]string, len(header))
+	err := src.EachEntry(ctx, o.Query, o.Matcher, p, func(key []byte, e store.Entry) error {
+		clear(row)
+		k, binary := encodeKey(key)
+		row[0] = k
+		if binary {
+			row[1] = EncodingBase64.String()
+		}
+		if e.ExpiresAt != 0 {
+			row[4] = strconv.FormatUint(e.ExpiresAt, 10)
+		}
+		if e.UserMeta != 0 {
+			row[5] = strconv.Itoa(int(e.UserMeta))
+		}
+		if o.Flatten && flattenRow(e.Value, fields, row) {
+			row[3] = EncodingJSON.String()
+		} else {
+			clear(row[len(csvColumns):])
+			cell, used := encodeValue(e.Value, o.Encoding)
+			row[2], row[3] = cell, used.String()
+		}
+		n++
+		return cw.Write(row)
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return n, err
+}
+
+// flattenRow spreads an object value over the field columns, leaving the
+// value column empty and the encoding json. It reports
+// false when v is not an object or has a field the header lacks, as when
+// it changed between the two passes; the value column takes it then.
+func flattenRow(v []byte, fields map[string]int, row []string) bool {
+	obj, ok := jsonObject(v)
+	if !ok {
+		return false
+	}
+	fit := true
+	flatten("", obj, func(path, cell string) {
+		if i, ok := fields[path]; ok {
+			row[i] = cell
+		} else {
+			fit = false
+		}
+	})
+	return fit
+}
+
+func jsonObject(v []byte) (map[string]any, bool) {
+	var obj map[string]any
+	// I keep numbers as written; float64 would round large IDs.
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+	if err := d.Decode(&obj); err != nil || obj == nil || d.More() {
+		return nil, false
+	}
+	return obj, true
+}
+
+// flatten walks nested objects and hands each leaf to fn under its dotted
+// path. Strings are written as they are, unless they are empty or read as
+// JSON, which the import would take for a missing field or a number; those
+// are quoted, like arrays and other scalars are written as JSON.
+func flatten(prefix string, obj map[string]any, fn func(path, cell string)) {
+	for k, v := range obj {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(path, v, fn)
+		case string:
+			if v != "" && !json.Valid([]byte(v)) {
+				fn(path, v)
+				break
+			}
+			b, _ := json.Marshal(v)
+			fn(path, string(b))
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				b = []byte(fmt.Sprint(v))
+			}
+			fn(path, string(b))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"badge-reader/internal/store"
+)
+
+// memSource serves entries from a map, in key order.
+type memSource struct {
+	entries map[string]store.Entry
+	fail    error // returned after the first entry
+}
+
+func (s memSource) EachEntry(ctx context.Context, q store.KeyQuery, m *store.Matcher, p *store.Progress, fn func(key []byte, e store.Entry) error) error {
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if s.fail != nil && i > 0 {
+			return s.fail
+		}
+		if err := fn([]byte(k), s.entries[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportFileKeepsExistingFile(t *testing.T) {
+	src := memSource{entries: map[string]store.Entry{"a": {Value: []byte("1")}, "b": {Value: []byte("2")}}}
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	if err := os.WriteFile(path, []byte("precious"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	check := func(want string) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("file holds %q, want %q", data, want)
+		}
+	}
+
+	if _, err := ExportFile(context.Background(), src, path, ExportOptions{}, nil); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("export over an existing file: got %v, want fs.ErrExist", err)
+	}
+	check("precious")
+
+	failing := memSource{entries: src.entries, fail: errors.New("disk on fire")}
+	if _, err := ExportFile(context.Background(), failing, path, ExportOptions{Overwrite: true}, nil); err == nil {
+		t.Fatal("failing export: got no error")
+	}
+	check("precious")
+
+	n, err := ExportFile(context.Background(), src, path, ExportOptions{Overwrite: true}, nil)
+	if err != nil || n != 2 {
+		t.Fatalf("overwrite: n=%d err=%v", n, err)
+	}
+	check(`{"key":"a","value":"1","encoding":"text"}` + "\n" + `{"key":"b","value":"2","encoding":"text"}` + "\n")
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode %v, want the replaced file's 0600", info.Mode().Perm())
+	}
+	left, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*partial"))
+	if len(left) > 0 {
+		t.Errorf("temporary files left: %v", left)
+	}
+}
+
+func TestExportFileRemovesOwnPartialFile(t *testing.T) {
+	src := memSource{entries: map[string]store.Entry{"a": {Value: []byte("1")}, "b": {Value: []byte("2")}}, fail: context.Canceled}
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	if _, err := ExportFile(context.Background(), src, path, ExportOptions{}, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("partial export left behind: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package transfer moves keys and values between the store and JSONL or
+// CSV files.
+package transfer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is the layout of an export or import file.
+type Format int
+
+const (
+	FormatJSONL Format = iota
+	FormatCSV
+)
+
+var formatNames = []string{"jsonl", "csv"}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return "unknown"
+	}
+	return formatNames[f]
+}
+
+func ParseFormat(s string) (Format, error) {
+	for i, name := range formatNames {
+		if strings.EqualFold(s, name) {
+			return Format(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %q (want %s)", s, strings.Join(formatNames, ", "))
+}
+
+// FormatForPath picks CSV for a .csv file and JSONL for anything else.
+func FormatForPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+	return FormatJSONL
+}
+
+// Encoding says how a value is written as text.
+type Encoding int
+
+const (
+	EncodingText   Encoding = iota // the bytes as a string, when they are UTF-8
+	EncodingBase64                 // standard base64
+	EncodingHex                    // lowercase hex
+	EncodingJSON                   // the value itself, when it is valid JSON
+)
+
+var encodingNames = []string{"text", "base64", "hex", "json"}
+
+func (e Encoding) String() string {
+	if e < 0 || int(e) >= len(encodingNames) {
+		return "unknown"
+	}
+	return encodingNames[e]
+}
+
+// Next cycles through the encodings in the order above.
+func (e Encoding) Next() Encoding {
+	return (e + 1) % Encoding(len(encodingNames))
+}
+
+func ParseEncoding(s string) (Encoding, error) {
+	for i, name := range encodingNames {
+		if strings.EqualFold(s, name) {
+			return Encoding(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown encoding %q (want %s)", s, strings.Join(encodingNames, ", "))
+}
+
+// encodeValue writes v in enc, or falls back when v cannot be: JSON that
+// is not valid goes out as text, and text that is not UTF-8 as base64. It
+// returns the encoding it used, which the record carries.
+func encodeValue(v []byte, enc Encoding) (string, Encoding) {
+	switch enc {
+	case EncodingJSON:
+		if json.Valid(v) {
+			var buf bytes.Buffer
+			// I compact it so a value never breaks a JSONL line.
+			if err := json.Compact(&buf, v); err == nil {
+				return buf.String(), EncodingJSON
+			}
+		}
+		return encodeValue(v, EncodingText)
+	case EncodingText:
+		if utf8.Valid(v) {
+			return string(v), EncodingText
+		}
+		return encodeValue(v, EncodingBase64)
+	case EncodingHex:
+		return hex.EncodeToString(v), EncodingHex
+	default:
+		return base64.StdEncoding.EncodeToString(v), EncodingBase64
+	}
+}
+
+// decodeValue is the reverse of encodeValue.
+func decodeValue(s string, enc Encoding) ([]byte, error) {
+	switch enc {
+	case EncodingText, EncodingJSON:
+		return []byte(s), nil
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return nil, fmt.Errorf("unknown encoding %d", enc)
+}
+
+// encodeKey keeps a UTF-8 key readable and base64 encodes any other.
+func encodeKey(k []byte) (string, bool) {
+	if utf8.Valid(k) {
+		return string(k), false
+	}
+	return base64.StdEncoding.EncodeToString(k), true
+}
@@ -0,0 +1,458 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Sink is what an import writes to; the store implements it.
+type Sink interface {
+	Import(ctx context.Context, next func() (store.ImportEntry, error), c store.Conflict, dryRun bool, p *store.Progress) store.ImportSummary
+}
+
+// ImportOptions says how to read a file into keys. Column names are CSV
+// header names or JSONL field names, and default to those of an export,
+// so an exported file imports as it is.
+type ImportOptions struct {
+	Format Format
+	// KeyColumn holds the key ("key"). KeyTemplate builds it from other
+	// columns instead, as in user:{id}; a dotted name such as {value.id}
+	// reaches into a JSON object.
+	KeyColumn   string
+	KeyTemplate string
+	ValueColumn string // "value"
+	// Encoding reads values of records that do not name their own in an
+	// encoding column.
+	Encoding   Encoding
+	TTLColumn  string // "expires_at": unix seconds, a duration from now or RFC 3339
+	MetaColumn string // "user_meta": 0-255 or 0x..
+	Conflict   store.Conflict
+	DryRun     bool
+}
+
+func (o ImportOptions) withDefaults() ImportOptions {
+	for _, c := range []struct {
+		name *string
+		def  string
+	}{{&o.KeyColumn, "key"}, {&o.ValueColumn, "value"}, {&o.TTLColumn, "expires_at"}, {&o.MetaColumn, "user_meta"}} {
+		if *c.name == "" {
+			*c.name = c.def
+		}
+	}
+	return o
+}
+
+// ImportFile imports the file at path. It first reads the whole file, so
+// a record that cannot be read stops the import before anything is
+// written rather than halfway. Failing on existing keys checks them in the
+// same pass with a dry run.
+func ImportFile(ctx context.Context, sink Sink, path string, o ImportOptions, p *store.Progress) store.ImportSummary {
+	if !o.DryRun {
+		check := o
+		check.DryRun = true
+		var checker Sink = readAll{}
+		if o.Conflict == store.ConflictFail {
+			checker = sink
+		}
+		if sum := importPath(ctx, checker, path, check, nil); sum.Err != nil {
+			return store.ImportSummary{Err: fmt.Errorf("nothing written: %w", sum.Err)}
+		}
+	}
+	return importPath(ctx, sink, path, o, p)
+}
+
+// readAll is a Sink that only reads every record, without the DB.
+type readAll struct{}
+
+func (readAll) Import(ctx context.Context, next func() (store.ImportEntry, error), c store.Conflict, dryRun bool, p *store.Progress) store.ImportSummary {
+	for {
+		_, err := next()
+		if errors.Is(err, io.EOF) {
+			return store.ImportSummary{}
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return store.ImportSummary{Err: err}
+		}
+	}
+}
+
+func importPath(ctx context.Context, sink Sink, path string, o ImportOptions, p *store.Progress) store.ImportSummary {
+	f, err := os.Open(path)
+	if err != nil {
+		return store.ImportSummary{Err: err}
+	}
+	defer f.Close()
+	return Import(ctx, sink, f, o, p)
+}
+
+// Import reads records from r and hands them to sink as they are read.
+func Import(ctx context.Context, sink Sink, r io.Reader, o ImportOptions, p *store.Progress) store.ImportSummary {
+	o = o.withDefaults()
+	var next func() (record, error)
+	if o.Format == FormatCSV {
+		cr, err := newCSVRecords(r, o)
+		if err != nil {
+			return store.ImportSummary{Err: err}
+		}
+		next = cr.next
+	} else {
+		next = newJSONLRecords(r).next
+	}
+	now := time.Now()
+	return sink.Import(ctx, func() (store.ImportEntry, error) {
+		rec, err := next()
+		if err != nil {
+			return store.ImportEntry{}, err
+		}
+		e, err := rec.entry(o, now)
+		if err != nil {
+			return e, fmt.Errorf("line %d: %w", rec.line, err)
+		}
+		return e, nil
+	}, o.Conflict, o.DryRun, p)
+}
+
+// record is one line of JSONL or row of CSV. JSONL fields are kept as raw
+// JSON, CSV cells as text.
+type record struct {
+	line   int
+	fields map[string]json.RawMessage
+	whole  []byte // the JSONL line, the value when it has no value field
+	cells  map[string]string
+	// flat lists the value.<path> columns of a flattened CSV export.
+	flat []string
+}
+
+// text is a column as text: JSON strings unquoted, other JSON as written.
+// A dotted name not found as it is reaches into a JSON object.
+func (r record) text(name string) (string, bool) {
+	if r.cells != nil {
+		if s, ok := r.cells[name]; ok {
+			return s, true
+		}
+	} else if v, ok := r.fields[name]; ok {
+		return jsonText(v), true
+	}
+	v, ok := r.nested(name)
+	if !ok {
+		return "", false
+	}
+	return jsonText(v), true
+}
+
+// raw is a column as JSON. CSV cells are JSON text themselves.
+func (r record) raw(name string) (json.RawMessage, bool) {
+	if r.cells != nil {
+		if s, ok := r.cells[name]; ok {
+			return json.RawMessage(s), true
+		}
+	} else if v, ok := r.fields[name]; ok {
+		return v, true
+	}
+	return r.nested(name)
+}
+
+func (r record) nested(name string) (json.RawMessage, bool) {
+	for i := strings.IndexByte(name, '.'); i > 0; i = nextDot(name, i) {
+		outer, ok := r.raw(name[:i])
+		if !ok {
+			continue
+		}
+		if s, isString := jsonString(outer); isString {
+			// A CSV or text-encoded value holding a JSON object.
+			outer = json.RawMessage(s)
+		}
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(outer, &obj) != nil {
+			continue
+		}
+		if v, ok := (record{fields: obj}).raw(name[i+1:]); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func nextDot(s string, i int) int {
+	j := strings.IndexByte(s[i+1:], '.')
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+func jsonString(v json.RawMessage) (string, bool) {
+	var s string
+	if len(v) == 0 || v[0] != '"' || json.Unmarshal(v, &s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+func jsonText(v json.RawMessage) string {
+	if s, ok := jsonString(v); ok {
+		return s
+	}
+	if string(v) == "null" {
+		return ""
+	}
+	return string(v)
+}
+
+func (r record) entry(o ImportOptions, now time.Time) (store.ImportEntry, error) {
+	var e store.ImportEntry
+	var err error
+	if e.Key, err = r.key(o); err != nil {
+		return e, err
+	}
+	if e.Value, err = r.value(o); err != nil {
+		return e, fmt.Errorf("key %q: %w", e.Key, err)
+	}
+	if s, ok := r.text(o.TTLColumn); ok && s != "" {
+		if e.ExpiresAt, err = parseExpiry(s, now); err != nil {
+			return e, err
+		}
+	}
+	if s, ok := r.text(o.MetaColumn); ok && s != "" {
+		meta, err := strconv.ParseUint(s, 0, 8)
+		if err != nil {
+			return e, fmt.Errorf("invalid %s %q: want 0-255 or 0x..", o.MetaColumn, s)
+		}
+		e.UserMeta = byte(meta)
+	}
+	return e, nil
+}
+
+func (r record) key(o ImportOptions) (string, error) {
+	if o.KeyTemplate != "" {
+		return r.expand(o.KeyTemplate)
+	}
+	// A binary key of an export is in key_base64, or flagged in CSV.
+	if s, ok := r.text("key_base64"); ok && s != "" && r.cells == nil {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return string(b), err
+	}
+	k, ok := r.text(o.KeyColumn)
+	if !ok || k == "" {
+		return "", fmt.Errorf("no %s", o.KeyColumn)
+	}
+	if enc, _ := r.text("key_encoding"); enc != "" {
+		e, err := ParseEncoding(enc)
+		if err != nil {
+			return "", err
+		}
+		b, err := decodeValue(k, e)
+		return string(b), err
+	}
+	return k, nil
+}
+
+// expand fills the {name} placeholders of a key template.
+func (r record) expand(tmpl string) (string, error) {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			b.WriteString(tmpl)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("key template: unclosed {")
+		}
+		name := tmpl[open+1 : open+end]
+		v, ok := r.text(name)
+		if !ok {
+			return "", fmt.Errorf("key template: no %s", name)
+		}
+		b.WriteString(tmpl[:open])
+		b.WriteString(v)
+		tmpl = tmpl[open+end+1:]
+	}
+}
+
+func (r record) value(o ImportOptions) ([]byte, error) {
+	enc := o.Encoding
+	if s, _ := r.text("encoding"); s != "" {
+		var err error
+		if enc, err = ParseEncoding(s); err != nil {
+			return nil, err
+		}
+	}
+	if r.cells == nil {
+		v, ok := r.fields[o.ValueColumn]
+		if !ok {
+			// I take a plain JSON line as the value itself.
+			return bytes.TrimSpace(r.whole), nil
+		}
+		if enc == EncodingJSON {
+			return append([]byte(nil), v...), nil
+		}
+		return decodeValue(jsonText(v), enc)
+	}
+
+	s, ok := r.cells[o.ValueColumn]
+	if s == "" && len(r.flat) > 0 {
+		// An object with no field set, such as {}, leaves nothing but its
+		// encoding behind.
+		if v, found := r.unflatten(o.ValueColumn); found || enc == EncodingJSON {
+			return v, nil
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("no %s column", o.ValueColumn)
+	}
+	if enc == EncodingJSON && !json.Valid([]byte(s)) {
+		return nil, errors.New("value is not valid JSON")
+	}
+	return decodeValue(s, enc)
+}
+
+// unflatten puts the value.<path> columns of a row back into an object and
+// reports whether any was set. Cells that are valid JSON are taken as JSON,
+// as the export quotes the strings that would read otherwise.
+func (r record) unflatten(column string) ([]byte, bool) {
+	obj := map[string]any{}
+	found := false
+	for _, name := range r.flat {
+		cell := r.cells[name]
+		if cell == "" {
+			continue
+		}
+		found = true
+		var v any = cell
+		if json.Valid([]byte(cell)) {
+			v = json.RawMessage(cell)
+		}
+		parts := strings.Split(strings.TrimPrefix(name, column+"."), ".")
+		m := obj
+		for _, p := range parts[:len(parts)-1] {
+			sub, ok := m[p].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				m[p] = sub
+			}
+			m = sub
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	// The cells that went in are valid JSON, so this does not fail.
+	b, _ := json.Marshal(obj)
+	return b, found
+}
+
+// parseExpiry reads an expiry as unix seconds, a duration from now or an
+// RFC 3339 time.
+func parseExpiry(s string, now time.Time) (uint64, error) {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return uint64(now.Add(d).Unix()), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return uint64(t.Unix()), nil
+	}
+	return 0, fmt.Errorf("invalid expiry %q: use unix seconds, a duration such as 24h or RFC 3339", s)
+}
+
+type jsonlRecords struct {
+	rd   *bufio.Reader
+	line int
+}
+
+func newJSONLRecords(r io.Reader) *jsonlRecords {
+	return &jsonlRecords{rd: bufio.NewReaderSize(r, 64<<10)}
+}
+
+func (j *jsonlRecords) next() (record, error) {
+	for {
+		line, err := j.rd.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return record{}, err
+		}
+		j.line++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec := record{line: j.line, whole: line}
+		if jerr := json.Unmarshal(line, &rec.fields); jerr != nil {
+			return rec, fmt.Errorf("line %d: %w", j.line, jerr)
+		}
+		return rec, nil
+	}
+}
+
+type csvRecords struct {
+	cr     *csv.Reader
+	header []string
+	flat   []string
+}
+
+func newCSVRecords(r io.Reader, o ImportOptions) (*csvRecords, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	c := &csvRecords{cr: cr, header: header}
+	for _, name := range header {
+		if strings.HasPrefix(name, o.ValueColumn+".") {
+			c.flat = append(c.flat, name)
+		}
+	}
+	return c, nil
+}
+
+func (c *csvRecords) next() (record, error) {
+	row, err := c.cr.Read()
+	if err != nil {
+		return record{}, err
+	}
+	line, _ := c.cr.FieldPos(0)
+	rec := record{line: line, cells: make(map[string]string, len(row)), flat: c.flat}
+	for i, name := range c.header {
+		rec.cells[name] = row[i]
+	}
+	return rec, nil
+}
+
+// DescribeImport sums up the counts of an import, leaving out zeros.
+func DescribeImport(sum store.ImportSummary) string {
+	parts := []string{fmt.Sprintf("%d created", sum.Created)}
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{sum.Overwritten, "overwritten"}, {sum.Skipped, "skipped"}, {sum.Expired, "expired"}, {sum.Failed, "failed"}} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Plural counts n of word: "1 key", "2,048 keys".
+func Plural(n int, word string) string {
+	if n != 1 {
+		word += "s"
+	}
+	return humanize.Comma(int64(n)) + " " + word
+}
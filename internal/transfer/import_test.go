@@ -0,0 +1,119 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"badge-reader/internal/store"
+)
+
+// memSink collects what an import would write.
+type memSink struct {
+	got map[string]store.Entry
+}
+
+func (s *memSink) Import(ctx context.Context, next func() (store.ImportEntry, error), c store.Conflict, dryRun bool, p *store.Progress) store.ImportSummary {
+	var sum store.ImportSummary
+	for {
+		e, err := next()
+		if errors.Is(err, io.EOF) {
+			return sum
+		}
+		if err != nil {
+			sum.Err = err
+			return sum
+		}
+		if !dryRun {
+			s.got[e.Key] = e.Entry
+		}
+		sum.Created++
+	}
+}
+
+// A flattened CSV imports back to the same values, objects without any
+// field set and strings that look like JSON included.
+func TestFlattenedCSVRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"empty":   `{}`,
+		"blank":   `{"name":""}`,
+		"mixed":   `{"n":"123","s":"true","x":1,"a":{"b":"c","e":""},"l":[1,"2"]}`,
+		"quoted":  `{"q":"\"hi\""}`,
+		"text":    `not json`,
+		"array":   `[1,2]`,
+		"nothing": ``,
+	}
+	src := memSource{entries: map[string]store.Entry{}}
+	for k, v := range values {
+		src.entries[k] = store.Entry{Value: []byte(v)}
+	}
+	var buf bytes.Buffer
+	if _, err := Export(context.Background(), src, &buf, ExportOptions{Format: FormatCSV, Flatten: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &memSink{got: map[string]store.Entry{}}
+	sum := Import(context.Background(), sink, &buf, ImportOptions{Format: FormatCSV}, nil)
+	if sum.Err != nil {
+		t.Fatalf("import: %v\n%s", sum.Err, buf.String())
+	}
+	for k, want := range values {
+		e, ok := sink.got[k]
+		if !ok {
+			t.Errorf("%s: not imported", k)
+			continue
+		}
+		if !sameValue(e.Value, []byte(want)) {
+			t.Errorf("%s: imported %s, want %s", k, e.Value, want)
+		}
+	}
+}
+
+// sameValue compares JSON values by what they hold, other values byte for
+// byte.
+func sameValue(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// A record that cannot be read stops the import before the records ahead
+// of it are written.
+func TestImportFileReadsAllFirst(t *testing.T) {
+	var lines bytes.Buffer
+	for i := range store.ImportChunk + 10 {
+		fmt.Fprintf(&lines, `{"key":"k%d","value":"v"}`+"\n", i)
+	}
+	lines.WriteString(`{"key":"bad","value":"%%%","encoding":"base64"}` + "\n")
+	path := filepath.Join(t.TempDir(), "in.jsonl")
+	if err := os.WriteFile(path, lines.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &memSink{got: map[string]store.Entry{}}
+	sum := ImportFile(context.Background(), sink, path, ImportOptions{}, nil)
+	if sum.Err == nil || !strings.Contains(sum.Err.Error(), "nothing written") {
+		t.Fatalf("import: %v, want it stopped with nothing written", sum.Err)
+	}
+	if len(sink.got) != 0 {
+		t.Errorf("%d keys written", len(sink.got))
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for n, want := range map[int]string{0: "0 keys", 1: "1 key", 2: "2 keys", 2048: "2,048 keys"} {
+		if got := Plural(n, "key"); got != want {
+			t.Errorf("Plural(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
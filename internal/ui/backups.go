@@ -0,0 +1,365 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"badge-reader/internal/backup"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+// The backups panel lists the catalog next to the DB, newest first, and
+// takes, verifies and restores backups as jobs.
+type backupsState struct {
+	active  bool
+	loading bool
+	entries []backup.Info
+	cursor  int
+	err     string
+	// verified holds the outcome of the last verify of each file.
+	verified map[string]string
+
+	prompt      backupPrompt
+	input       textinput.Model
+	incremental bool
+	prefixed    bool // only the list's prefix
+	restore     []backup.Info
+}
+
+type backupPrompt int
+
+const (
+	backupPromptNone backupPrompt = iota
+	backupPromptCreate
+	backupPromptRestore
+)
+
+type backupsMsg struct {
+	entries []backup.Info
+	err     error
+}
+
+type backupDoneMsg struct {
+	job  int
+	info backup.Info
+	err  error
+}
+
+type verifyBackupMsg struct {
+	job      int
+	path     string
+	keys     int
+	recorded backup.Info
+	known    bool
+	err      error
+}
+
+type restoreBackupMsg struct {
+	job   int
+	dir   string
+	files int
+	err   error
+}
+
+func newBackupInput() textinput.Model {
+	in := textinput.New()
+	in.CharLimit = 1024
+	in.Prompt = "File: "
+	return in
+}
+
+func loadBackupsCmd(catalog string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := backup.Catalog(catalog)
+		// I show the latest backup first.
+		for i, k := 0, len(entries)-1; i < k; i, k = i+1, k-1 {
+			entries[i], entries[k] = entries[k], entries[i]
+		}
+		return backupsMsg{entries: entries, err: err}
+	}
+}
+
+func createBackupCmd(s Store, j job, catalog, path string, o backup.Options) tea.Cmd {
+	return func() tea.Msg {
+		info, err := backup.Create(j.ctx, s, catalog, path, o, j.progress)
+		return backupDoneMsg{job: j.id, info: info, err: err}
+	}
+}
+
+func verifyBackupCmd(s Store, j job, catalog, path string) tea.Cmd {
+	return func() tea.Msg {
+		keys, recorded, known, err := backup.Verify(j.ctx, catalog, path, s.EncryptionKey())
+		return verifyBackupMsg{job: j.id, path: path, keys: keys, recorded: recorded, known: known, err: err}
+	}
+}
+
+func restoreBackupCmd(s Store, j job, dir string, chain []backup.Info) tea.Cmd {
+	return func() tea.Msg {
+		paths := make([]string, len(chain))
+		for i, info := range chain {
+			paths[i] = info.Path
+		}
+		target, err := s.OpenRestoreTarget(dir)
+		if err != nil {
+			return restoreBackupMsg{job: j.id, dir: dir, err: err}
+		}
+		err = backup.Restore(j.ctx, target, paths, false, s.EncryptionKey())
+		if cerr := target.Close(); err == nil {
+			err = cerr
+		}
+		return restoreBackupMsg{job: j.id, dir: dir, files: len(paths), err: err}
+	}
+}
+
+func (m Model) toggleBackups() (Model, tea.Cmd) {
+	if m.backups.active {
+		m.backups.active = false
+		m.status = "List focused."
+		return m, nil
+	}
+	m.backups.active = true
+	m.backups.loading = true
+	m.status = "Backups. ↑/↓ select · n full · i incremental · v verify · r restore · B/Esc close"
+	return m, loadBackupsCmd(m.backupCatalog)
+}
+
+func (m Model) updateBackupsKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.backups.prompt != backupPromptNone {
+		return m.updateBackupPrompt(msg)
+	}
+	n := len(m.backups.entries)
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "B":
+		return m.toggleBackups()
+	case "up":
+		m.backups.cursor = max(0, m.backups.cursor-1)
+	case "down":
+		m.backups.cursor = clamp(m.backups.cursor+1, 0, max(0, n-1))
+	case "n", "i":
+		return m.openBackupPrompt(msg.String() == "i")
+	case "v":
+		if m.backups.cursor >= n {
+			return m, nil
+		}
+		info := m.backups.entries[m.backups.cursor]
+		m, j, tick := m.startJob(jobBackup, "Verify "+filepath.Base(info.Path))
+		m.status = fmt.Sprintf("Verifying %s in memory…", filepath.Base(info.Path))
+		return m, tea.Batch(tick, verifyBackupCmd(m.store, j, m.backupCatalog, info.Path))
+	case "r":
+		if m.backups.cursor >= n {
+			return m, nil
+		}
+		chain, err := backup.Chain(m.backupCatalog, m.backups.entries[m.backups.cursor].Path)
+		if err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Error: %v", err))
+			return m, nil
+		}
+		m.backups.restore = chain
+		m.backups.prompt = backupPromptRestore
+		m.backups.input.Prompt = "Into new directory: "
+		m.backups.input.SetValue(filepath.Clean(m.dbPath) + "-restored-" + time.Now().Format("20060102-150405"))
+		m.backups.input.CursorEnd()
+		m.status = fmt.Sprintf("Restore %d backup files into a new, empty directory. (Enter restore · Esc cancel)", len(chain))
+		return m, m.backups.input.Focus()
+	}
+	return m, nil
+}
+
+func (m Model) openBackupPrompt(incremental bool) (Model, tea.Cmd) {
+	if incremental && m.backupCatalog == "" {
+		m.status = errStyle.Render("Error: an in-memory DB has no backup catalog to carry on from.")
+		return m, nil
+	}
+	m.backups.prompt = backupPromptCreate
+	m.backups.incremental = incremental
+	m.backups.prefixed = false
+	path := "backup.bak.gz"
+	if m.backupCatalog != "" {
+		path = backup.DefaultPath(m.dbPath, incremental, time.Now())
+	}
+	m.backups.input.Prompt = "File: "
+	m.backups.input.SetValue(path)
+	m.backups.input.CursorEnd()
+	m.status = "Back up to a new file; .gz compresses. (Enter start · Tab list prefix only · Esc cancel)"
+	return m, m.backups.input.Focus()
+}
+
+func (m Model) updateBackupPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.backups.prompt = backupPromptNone
+		m.backups.input.Blur()
+		m.status = "Canceled."
+		return m, nil
+	case "tab":
+		if m.backups.prompt == backupPromptCreate && m.keyQuery.Prefix != "" {
+			m.backups.prefixed = !m.backups.prefixed
+		}
+		return m, nil
+	case "enter":
+		value := strings.TrimSpace(m.backups.input.Value())
+		if value == "" {
+			return m, nil
+		}
+		prompt := m.backups.prompt
+		m.backups.prompt = backupPromptNone
+		m.backups.input.Blur()
+		if prompt == backupPromptRestore {
+			m, j, tick := m.startJob(jobBackup, "Restore into "+value)
+			m.status = fmt.Sprintf("Restoring into %s…", value)
+			return m, tea.Batch(tick, restoreBackupCmd(m.store, j, value, m.backups.restore))
+		}
+		o := backup.Options{Incremental: m.backups.incremental, Key: m.store.EncryptionKey()}
+		if m.backups.prefixed {
+			o.Prefix = m.keyQuery.Prefix
+		}
+		m, j, tick := m.startJob(jobBackup, "Back up to "+filepath.Base(value))
+		m.status = fmt.Sprintf("Backing up to %s… (J shows jobs)", value)
+		return m, tea.Batch(tick, createBackupCmd(m.store, j, m.backupCatalog, value, o))
+	}
+	var cmd tea.Cmd
+	m.backups.input, cmd = m.backups.input.Update(msg)
+	return m, cmd
+}
+
+func (m Model) handleBackups(msg backupsMsg) (Model, tea.Cmd) {
+	if !m.backups.active {
+		return m, nil
+	}
+	m.backups.loading = false
+	m.backups.err = ""
+	if msg.err != nil {
+		m.backups.err = msg.err.Error()
+		return m, nil
+	}
+	m.backups.entries = msg.entries
+	m.backups.cursor = clamp(m.backups.cursor, 0, max(0, len(msg.entries)-1))
+	return m, nil
+}
+
+func (m Model) handleBackupDone(msg backupDoneMsg) (Model, tea.Cmd) {
+	m = m.endJob(msg.job, msg.err)
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.status = "Backup canceled; the partial file was removed."
+	case msg.err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: backup failed: %v", msg.err))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("%s written to %s (%s).", backup.Describe(msg.info), msg.info.Path, humanize.IBytes(uint64(msg.info.Size))))
+	}
+	if m.backups.active {
+		return m, loadBackupsCmd(m.backupCatalog)
+	}
+	return m, nil
+}
+
+func (m Model) handleVerifyBackup(msg verifyBackupMsg) (Model, tea.Cmd) {
+	m = m.endJob(msg.job, msg.err)
+	if m.backups.verified == nil {
+		m.backups.verified = map[string]string{}
+	}
+	name := filepath.Base(msg.path)
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.status = fmt.Sprintf("Verify of %s canceled.", name)
+		return m, nil
+	case msg.err != nil:
+		m.backups.verified[msg.path] = "unreadable"
+		m.status = errStyle.Render(fmt.Sprintf("Error: %s does not load: %v", name, msg.err))
+	case !msg.known:
+		m.backups.verified[msg.path] = "loads"
+		m.status = fmt.Sprintf("%s loads with %d keys.", name, msg.keys)
+	case msg.keys != msg.recorded.Keys:
+		m.backups.verified[msg.path] = fmt.Sprintf("%d of %d keys", msg.keys, msg.recorded.Keys)
+		m.status = errStyle.Render(fmt.Sprintf("Warning: %s loads with %d keys but %d were backed up.", name, msg.keys, msg.recorded.Keys))
+	default:
+		m.backups.verified[msg.path] = "verified"
+		m.status = okStyle.Render(fmt.Sprintf("%s is good: %d keys, as backed up.", name, msg.keys))
+	}
+	return m, nil
+}
+
+func (m Model) handleRestoreBackup(msg restoreBackupMsg) (Model, tea.Cmd) {
+	m = m.endJob(msg.job, msg.err)
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.status = fmt.Sprintf("Restore into %s canceled; the directory holds part of it.", msg.dir)
+	case notLogged(msg.err):
+		m.status = errStyle.Render(fmt.Sprintf("Warning: restored %d backup files into %s, but %v", msg.files, msg.dir, msg.err))
+	case msg.err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: restore into %s failed: %v", msg.dir, msg.err))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("Restored %d backup files into %s.", msg.files, msg.dir))
+	}
+	return m, nil
+}
+
+func (m Model) backupPromptText() string {
+	if m.backups.prompt == backupPromptRestore {
+		return fmt.Sprintf("Restore %d backup files. %s  (Enter restore · Esc cancel)", len(m.backups.restore), m.backups.input.View())
+	}
+	what := "Full backup"
+	if m.backups.incremental {
+		what = "Incremental backup"
+	}
+	scope := "all keys"
+	if m.backups.prefixed {
+		scope = fmt.Sprintf("prefix '%s'", m.showKey(m.keyQuery.Prefix))
+	}
+	return fmt.Sprintf("%s of %s: %s  (Enter start · Tab list prefix only · Esc cancel)", what, scope, m.backups.input.View())
+}
+
+func (m Model) backupsView(width int) string {
+	lines := []string{"Backups (↑/↓ select · n full · i incremental · v verify · r restore · B/Esc close)"}
+	switch {
+	case m.backupCatalog == "":
+		lines = append(lines, "An in-memory DB keeps no catalog; backups taken here are not listed.")
+	case m.backups.loading:
+		lines = append(lines, "Loading…")
+	case m.backups.err != "":
+		lines = append(lines, errStyle.Render("Error: "+m.backups.err))
+	case len(m.backups.entries) == 0:
+		lines = append(lines, "No backups yet.")
+	}
+	perPage := max(5, m.height/2-2)
+	start := 0
+	if m.backups.cursor >= perPage {
+		start = m.backups.cursor - perPage + 1
+	}
+	end := min(len(m.backups.entries), start+perPage)
+	for i := start; i < end; i++ {
+		b := m.backups.entries[i]
+		kind, versions := "full", fmt.Sprintf("≤ %d", b.Version)
+		switch {
+		case b.Empty():
+			kind, versions = "incr", "no changes"
+		case b.Incremental():
+			kind, versions = "incr", fmt.Sprintf("%d–%d", b.Since, b.Version)
+		}
+		if b.Prefix != "" {
+			kind += " " + m.showKey(b.Prefix)
+		}
+		line := fmt.Sprintf("%s  %-16s  %-12s %10s keys %9s  %-32s %s",
+			b.At.Local().Format(time.DateTime), truncateString(kind, 16), versions,
+			humanize.Comma(int64(b.Keys)), humanize.IBytes(uint64(b.Size)),
+			truncateString(filepath.Base(b.Path), 32), m.backups.verified[b.Path])
+		line = truncateString(line, width-4)
+		if i == m.backups.cursor {
+			line = selectedRowStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if end < len(m.backups.entries) {
+		lines = append(lines, inspectorStyle.Render(fmt.Sprintf("… %d more", len(m.backups.entries)-end)))
+	}
+	return paneStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
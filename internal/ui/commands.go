@@ -1,23 +1,35 @@
 package ui
 
 import (
-	"path"
+	"errors"
 	"sort"
+	"time"
+
+	"badge-reader/internal/store"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func loadValueCmd(store Store, key string) tea.Cmd {
 	return func() tea.Msg {
-		out, err := store.Get(key)
-		return loadValueMsg{key: key, value: out, err: err}
+		e, err := store.GetEntry(key)
+		return loadValueMsg{key: key, value: e.Value, expiresAt: e.ExpiresAt, userMeta: e.UserMeta, err: err}
+	}
+}
+
+// refreshValueCmd reloads the shown value in place, keeping the scroll.
+func refreshValueCmd(store Store, key string) tea.Cmd {
+	return func() tea.Msg {
+		e, err := store.GetEntry(key)
+		return loadValueMsg{key: key, value: e.Value, expiresAt: e.ExpiresAt, userMeta: e.UserMeta, err: err, refresh: true}
 	}
 }
 
-func loadKeysCmd(store Store, startAfter string, limit int) tea.Cmd {
+func loadKeysCmd(s Store, q store.KeyQuery, startAfter string, limit int) tea.Cmd {
 	return func() tea.Msg {
-		keys, lastKey, hasMore, err := store.ListKeysPage(startAfter, limit)
+		keys, lastKey, hasMore, err := s.ListKeys(q, startAfter, limit)
 		return loadKeysMsg{
+			query:      q,
 			keys:       keys,
 			lastKey:    lastKey,
 			hasMore:    hasMore,
@@ -27,18 +39,37 @@ func loadKeysCmd(store Store, startAfter string, limit int) tea.Cmd {
 	}
 }
 
-func countFilterCmd(store Store, term string) tea.Cmd {
+// loadKeysBeforeCmd pages against the list's order, nearest key first.
+func loadKeysBeforeCmd(s Store, q store.KeyQuery, before string, limit int) tea.Cmd {
+	return func() tea.Msg {
+		back := q
+		back.Reverse = !q.Reverse
+		keys, lastKey, hasMore, err := s.ListKeys(back, before, limit)
+		return loadKeysMsg{
+			query:      q,
+			keys:       keys,
+			lastKey:    lastKey,
+			hasMore:    hasMore,
+			startAfter: before,
+			before:     true,
+			err:        err,
+		}
+	}
+}
+
+func countFilterCmd(s Store, j job, gen int, q store.KeyQuery, mt store.Matcher) tea.Cmd {
 	return func() tea.Msg {
-		count, err := store.CountKeysMatching(term)
-		return filterCountMsg{term: term, count: count, err: err}
+		count, err := s.CountKeysMatching(j.ctx, q, mt, j.progress)
+		return filterCountMsg{job: j.id, gen: gen, count: count, err: err}
 	}
 }
 
-func loadGroupCountsCmd(store Store) tea.Cmd {
+func loadGroupCountsCmd(store Store, j job) tea.Cmd {
 	return func() tea.Msg {
-		counts, err := store.GroupKeyCounts()
+		start := time.Now()
+		counts, err := store.GroupKeyCounts(j.ctx, j.progress)
 		if err != nil {
-			return groupCountsMsg{err: err}
+			return groupCountsMsg{job: j.id, err: err}
 		}
 		out := make([]groupCount, 0, len(counts))
 		for k, v := range counts {
@@ -50,54 +81,30 @@ func loadGroupCountsCmd(store Store) tea.Cmd {
 			}
 			return out[i].count > out[j].count
 		})
-		return groupCountsMsg{counts: out}
+		return groupCountsMsg{job: j.id, counts: out, took: time.Since(start)}
 	}
 }
 
-func saveValueCmd(store Store, key string, value []byte) tea.Cmd {
+func saveValueCmd(s Store, key string, value []byte, opts store.SetOptions) tea.Cmd {
 	return func() tea.Msg {
-		err := store.Set(key, value)
+		err := s.SetWith(key, value, opts)
 		return saveResultMsg{key: key, err: err}
 	}
 }
 
+// notLogged tells a change the audit log missed, which is still made, from
+// one that failed.
+func notLogged(err error) bool {
+	return errors.Is(err, store.ErrNotAudited)
+}
+
+func keyNotFound(err error) bool {
+	return errors.Is(err, store.ErrKeyNotFound)
+}
+
 func deleteKeyCmd(store Store, key string) tea.Cmd {
 	return func() tea.Msg {
 		err := store.Delete(key)
 		return deleteResultMsg{key: key, err: err}
 	}
 }
-
-func deletePatternCmd(store Store, pattern string) tea.Cmd {
-	return func() tea.Msg {
-		var deleted []string
-		startAfter := ""
-		for {
-			keys, lastKey, hasMore, err := store.ListKeysPage(startAfter, 1000)
-			if err != nil {
-				return deletePatternResultMsg{pattern: pattern, err: err}
-			}
-			for _, k := range keys {
-				ok, err := matchPattern(pattern, k)
-				if err != nil {
-					return deletePatternResultMsg{pattern: pattern, err: err}
-				}
-				if ok {
-					if err := store.Delete(k); err != nil {
-						return deletePatternResultMsg{pattern: pattern, err: err}
-					}
-					deleted = append(deleted, k)
-				}
-			}
-			if !hasMore || len(keys) == 0 {
-				break
-			}
-			startAfter = lastKey
-		}
-		return deletePatternResultMsg{pattern: pattern, keys: deleted}
-	}
-}
-
-func matchPattern(pattern, key string) (bool, error) {
-	return path.Match(pattern, key)
-}
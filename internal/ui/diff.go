@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// I cap the LCS table; beyond this the changed middle is shown as a block.
+const maxDiffCells = 1 << 20
+
+type diffOp int
+
+const (
+	diffSame diffOp = iota
+	diffDel
+	diffAdd
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// diffLines returns a line diff turning a into b.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []diffLine
+	for _, l := range a[:prefix] {
+		out = append(out, diffLine{op: diffSame, text: l})
+	}
+	out = append(out, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, diffLine{op: diffSame, text: l})
+	}
+	return out
+}
+
+func diffMiddle(a, b []string) []diffLine {
+	var out []diffLine
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, l := range a {
+			out = append(out, diffLine{op: diffDel, text: l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{op: diffAdd, text: l})
+		}
+		return out
+	}
+
+	// I fill lcs[i][j] with the LCS length of a[i:] and b[j:].
+	w := len(b) + 1
+	lcs := make([]int, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else {
+				lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{op: diffSame, text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			out = append(out, diffLine{op: diffDel, text: a[i]})
+			i++
+		default:
+			out = append(out, diffLine{op: diffAdd, text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{op: diffDel, text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{op: diffAdd, text: b[j]})
+	}
+	return out
+}
+
+// plainValue is formatValue without colors, so diffs compare content only.
+func (m Model) plainValue(v []byte) string {
+	if m.valFormat == fmtJSON && utf8.Valid(v) {
+		var any interface{}
+		if err := json.Unmarshal(v, &any); err == nil {
+			pretty, _ := json.MarshalIndent(any, "", "  ")
+			return string(pretty)
+		}
+		return string(v)
+	}
+	return m.formatValue("", v)
+}
+
+func renderDiff(lines []diffLine) string {
+	changed := false
+	var b strings.Builder
+	for i, l := range lines {
+		switch l.op {
+		case diffDel:
+			changed = true
+			b.WriteString(diffDelStyle.Render("- " + l.text))
+		case diffAdd:
+			changed = true
+			b.WriteString(diffAddStyle.Render("+ " + l.text))
+		default:
+			b.WriteString("  " + l.text)
+		}
+		if i < len(lines)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	if !changed {
+		return okStyle.Render("Identical to the current value.") + "\n\n" + b.String()
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"badge-reader/internal/store"
+	"badge-reader/internal/transfer"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// An export writes the keys in view (the filter's matches, or the list's
+// prefix and meta filter) or the whole DB to a file, as a job. The format
+// follows the file extension.
+type exportState struct {
+	prompt   bool
+	input    textinput.Model
+	encoding transfer.Encoding
+	flatten  bool
+	all      bool // the whole DB instead of the keys in view
+	// overwrite is the file that exists, while the prompt asks to replace it.
+	overwrite string
+}
+
+type exportResultMsg struct {
+	job  int
+	path string
+	n    int
+	err  error
+}
+
+func newExportInput() textinput.Model {
+	in := textinput.New()
+	in.Placeholder = "export.jsonl"
+	in.CharLimit = 1024
+	in.Prompt = "File: "
+	return in
+}
+
+func exportCmd(s Store, j job, path string, o transfer.ExportOptions) tea.Cmd {
+	return func() tea.Msg {
+		n, err := transfer.ExportFile(j.ctx, s, path, o, j.progress)
+		return exportResultMsg{job: j.id, path: path, n: n, err: err}
+	}
+}
+
+func (m Model) openExportPrompt() (Model, tea.Cmd) {
+	m.export.prompt = true
+	m.export.input.CursorEnd()
+	m.status = "Export to a .jsonl or .csv file. (Enter export · Tab scope · Ctrl+R encoding · Ctrl+F flatten · Esc cancel)"
+	return m, m.export.input.Focus()
+}
+
+func (m Model) updateExportPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.export.overwrite != "" {
+		switch msg.String() {
+		case "y", "Y", "enter":
+			return m.startExport(true)
+		case "n", "N", "esc":
+			m.export.overwrite = ""
+			m.status = "Not overwritten; pick another file name."
+		}
+		return m, nil
+	}
+	switch msg.String() {
+	case "esc":
+		m.export.prompt = false
+		m.export.input.Blur()
+		m.status = "Export canceled."
+		return m, nil
+	case "tab", "shift+tab":
+		m.export.all = !m.export.all
+		return m, nil
+	case "ctrl+r":
+		m.export.encoding = m.export.encoding.Next()
+		return m, nil
+	case "ctrl+f":
+		m.export.flatten = !m.export.flatten
+		return m, nil
+	case "enter":
+		return m.startExport(false)
+	}
+	var cmd tea.Cmd
+	m.export.input, cmd = m.export.input.Update(msg)
+	return m, cmd
+}
+
+// exportScope is what an export started now would write, and how the
+// prompt and the job panel name it.
+func (m Model) exportScope() (store.KeyQuery, *store.Matcher, string) {
+	switch {
+	case m.export.all:
+		return store.KeyQuery{}, nil, "all keys"
+	case m.filter.active:
+		mt := m.filter.matcher
+		return m.filter.query, &mt, "keys matching " + m.describeMatcher(mt)
+	}
+	q := m.keyQuery
+	var parts []string
+	if q.Prefix != "" {
+		parts = append(parts, fmt.Sprintf("prefix '%s'", m.showKey(q.Prefix)))
+	}
+	if q.HasMeta {
+		parts = append(parts, fmt.Sprintf("meta 0x%02x", q.UserMeta))
+	}
+	if len(parts) == 0 {
+		return q, nil, "all keys"
+	}
+	return q, nil, "keys with " + strings.Join(parts, " and ")
+}
+
+func (m Model) startExport(overwrite bool) (Model, tea.Cmd) {
+	path := strings.TrimSpace(m.export.input.Value())
+	if path == "" {
+		path = m.export.input.Placeholder
+	}
+	format := transfer.FormatForPath(path)
+	if m.export.flatten && format != transfer.FormatCSV {
+		m.status = errStyle.Render("Error: flatten only applies to a .csv file.")
+		return m, nil
+	}
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		m.export.overwrite = path
+		m.status = fmt.Sprintf("%s exists. Overwrite it? (y/n)", path)
+		return m, nil
+	}
+	q, mt, scope := m.exportScope()
+	m.export.prompt = false
+	m.export.overwrite = ""
+	m.export.input.Blur()
+	o := transfer.ExportOptions{Query: q, Matcher: mt, Format: format, Encoding: m.export.encoding, Flatten: m.export.flatten, Overwrite: overwrite}
+	m, j, tick := m.startJob(jobExport, fmt.Sprintf("Export %s to %s", scope, path))
+	m.status = fmt.Sprintf("Exporting %s to %s… (J shows jobs)", scope, path)
+	return m, tea.Batch(tick, exportCmd(m.store, j, path, o))
+}
+
+func (m Model) handleExportResult(msg exportResultMsg) (Model, tea.Cmd) {
+	m = m.endJob(msg.job, msg.err)
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.status = fmt.Sprintf("Export to %s canceled; the partial file was removed.", msg.path)
+	case errors.Is(msg.err, fs.ErrExist):
+		m.status = errStyle.Render(fmt.Sprintf("Error: %s was created meanwhile; export again to overwrite it.", msg.path))
+	case msg.err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: export to %s failed: %v", msg.path, msg.err))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("Exported %s to %s.", transfer.Plural(msg.n, "key"), msg.path))
+	}
+	return m, nil
+}
+
+func (m Model) exportPromptText() string {
+	if m.export.overwrite != "" {
+		return fmt.Sprintf("%s exists. Overwrite it? (y/n)", m.export.overwrite)
+	}
+	_, _, scope := m.exportScope()
+	format := transfer.FormatForPath(m.export.input.Value())
+	opts := fmt.Sprintf("%s, %s values", format, m.export.encoding)
+	if m.export.flatten {
+		opts += ", flattened"
+	}
+	return fmt.Sprintf("Export %s (%s): %s  (Enter export · Tab scope · Ctrl+R encoding · Ctrl+F flatten · Esc cancel)", scope, opts, m.export.input.View())
+}
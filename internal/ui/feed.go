@@ -0,0 +1,307 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	feedKeep    = 200   // changes kept for the panel
+	feedPending = 50000 // changes held between two UI updates
+	feedEvery   = 150 * time.Millisecond
+	feedInserts = 1000 // new keys put in the list per update
+)
+
+// The change feed watches the writes made on the open handle while the
+// rest of the UI stays usable; it does not take the keys.
+type feedState struct {
+	active   bool
+	prompt   bool
+	input    textinput.Model
+	prefixes []string
+	changes  []store.Change // oldest first
+	total    int
+	dropped  int
+	err      string
+	gen      int
+	sub      *feedSub // nil while stopped
+}
+
+// feedSub hands changes from Badger's subscriber goroutine to the UI. The
+// callback must not block, since Badger holds up writes while it runs, so
+// it only queues them and drops what the UI has not caught up with.
+type feedSub struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	pending []store.Change
+	dropped int
+	notify  chan struct{}
+}
+
+func (f *feedSub) add(changes []store.Change) {
+	f.mu.Lock()
+	if len(f.pending)+len(changes) > feedPending {
+		f.dropped += len(changes)
+	} else {
+		f.pending = append(f.pending, changes...)
+	}
+	f.mu.Unlock()
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (f *feedSub) take() ([]store.Change, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changes, dropped := f.pending, f.dropped
+	f.pending, f.dropped = nil, 0
+	return changes, dropped
+}
+
+type feedMsg struct {
+	gen     int
+	changes []store.Change
+	dropped int
+	err     error
+}
+
+type feedEndedMsg struct {
+	gen int
+	err error
+}
+
+func newFeedInput() textinput.Model {
+	in := textinput.New()
+	in.Placeholder = "all keys"
+	in.CharLimit = 1024
+	in.Prompt = "Prefixes: "
+	return in
+}
+
+func subscribeCmd(s Store, gen int, prefixes []string, sub *feedSub) tea.Cmd {
+	return func() tea.Msg {
+		err := s.Subscribe(sub.ctx, prefixes, sub.add)
+		return feedEndedMsg{gen: gen, err: err}
+	}
+}
+
+// waitFeedCmd waits for changes, gives a burst a moment to gather, and
+// works out which of them were deletes off the UI goroutine.
+func waitFeedCmd(s Store, gen int, sub *feedSub) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-sub.ctx.Done():
+			return nil
+		case <-sub.notify:
+		}
+		select {
+		case <-sub.ctx.Done():
+			return nil
+		case <-time.After(feedEvery):
+		}
+		changes, dropped := sub.take()
+		err := s.ResolveDeletes(changes)
+		return feedMsg{gen: gen, changes: changes, dropped: dropped, err: err}
+	}
+}
+
+func (m Model) toggleFeed() (Model, tea.Cmd) {
+	if m.feed.active {
+		m = m.stopFeed()
+		m.status = "Change feed stopped."
+		return m, nil
+	}
+	m.feed.prompt = true
+	m.feed.input.CursorEnd()
+	m.status = `Watch writes under these prefixes, separated by spaces (\x20 for a space); empty watches every key. (Enter start · Esc cancel)`
+	return m, m.feed.input.Focus()
+}
+
+func (m Model) stopFeed() Model {
+	if m.feed.sub != nil {
+		m.feed.sub.cancel()
+		m.feed.sub = nil
+	}
+	m.feed.active = false
+	m.feed.gen++
+	return m
+}
+
+func (m Model) updateFeedPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.feed.prompt = false
+		m.feed.input.Blur()
+		m.status = "Canceled."
+		return m, nil
+	case "enter":
+		var prefixes []string
+		for _, field := range strings.Fields(m.feed.input.Value()) {
+			p, err := parseKeyInput(field)
+			if err != nil {
+				m.status = errStyle.Render(fmt.Sprintf("Error: %v", err))
+				return m, nil
+			}
+			prefixes = append(prefixes, p)
+		}
+		m.feed.prompt = false
+		m.feed.input.Blur()
+		return m.startFeed(prefixes)
+	}
+	var cmd tea.Cmd
+	m.feed.input, cmd = m.feed.input.Update(msg)
+	return m, cmd
+}
+
+func (m Model) startFeed(prefixes []string) (Model, tea.Cmd) {
+	m = m.stopFeed()
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &feedSub{ctx: ctx, cancel: cancel, notify: make(chan struct{}, 1)}
+	m.feed.active = true
+	m.feed.prefixes = prefixes
+	m.feed.changes = nil
+	m.feed.total, m.feed.dropped = 0, 0
+	m.feed.err = ""
+	m.feed.sub = sub
+	m.status = "Watching writes. The shown value and the list follow them · C stops."
+	return m, tea.Batch(subscribeCmd(m.store, m.feed.gen, prefixes, sub), waitFeedCmd(m.store, m.feed.gen, sub))
+}
+
+func (m Model) handleFeed(msg feedMsg) (Model, tea.Cmd) {
+	if msg.gen != m.feed.gen || !m.feed.active {
+		return m, nil
+	}
+	next := waitFeedCmd(m.store, m.feed.gen, m.feed.sub)
+	if msg.err != nil {
+		m.feed.err = msg.err.Error()
+	}
+	m.feed.total += len(msg.changes)
+	m.feed.dropped += msg.dropped
+	m.feed.changes = append(m.feed.changes, msg.changes...)
+	if n := len(m.feed.changes); n > feedKeep {
+		m.feed.changes = append([]store.Change(nil), m.feed.changes[n-feedKeep:]...)
+	}
+	// A pinned snapshot would only show the old state again.
+	if m.pinnedTs != 0 {
+		return m, next
+	}
+	return m.followChanges(msg.changes, next)
+}
+
+// followChanges puts new keys in the list, takes deleted ones out and
+// reloads the shown value when it changed.
+func (m Model) followChanges(changes []store.Change, next tea.Cmd) (Model, tea.Cmd) {
+	deleted := map[string]bool{}
+	latest := map[string]bool{} // whether each key's last change left it live
+	for _, c := range changes {
+		latest[c.Key] = !c.Deleted
+	}
+	inserted := 0
+	for key, live := range latest {
+		if !live {
+			deleted[key] = true
+		} else if inserted < feedInserts {
+			m.insertKey(key)
+			inserted++
+		}
+	}
+	cmds := []tea.Cmd{next}
+	if len(deleted) > 0 {
+		cmds = append(cmds, m.removeKeys(deleted))
+	}
+	if _, touched := latest[m.selected]; touched && m.selected != "" {
+		if m.editing || m.editKey == m.selected {
+			m.status = errStyle.Render(fmt.Sprintf("Warning: '%s' was written while you edit it; saving overwrites that write.", m.showKey(m.selected)))
+		} else {
+			cmds = append(cmds, refreshValueCmd(m.store, m.selected))
+		}
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// removeKeys drops many keys from the list in one pass.
+func (m *Model) removeKeys(keys map[string]bool) tea.Cmd {
+	items := m.list.Items()
+	kept := make([]list.Item, 0, len(items))
+	for _, it := range items {
+		if ki, _ := it.(kvItem); !keys[ki.key] {
+			kept = append(kept, it)
+		}
+	}
+	if len(kept) == len(items) {
+		return nil
+	}
+	idx := m.list.Index()
+	cmd := m.list.SetItems(kept)
+	m.list.Select(clamp(idx, 0, max(0, len(kept)-1)))
+	return cmd
+}
+
+func (m Model) handleFeedEnded(msg feedEndedMsg) (Model, tea.Cmd) {
+	if msg.gen != m.feed.gen || !m.feed.active {
+		return m, nil
+	}
+	m = m.stopFeed()
+	if msg.err != nil {
+		m.status = errStyle.Render(fmt.Sprintf("Error: change feed stopped: %v", msg.err))
+	}
+	return m, nil
+}
+
+func (m Model) feedPromptText() string {
+	return "Change feed: " + m.feed.input.View() + "  (Enter start · Esc cancel)"
+}
+
+func (m Model) feedView(width int) string {
+	scope := "all keys"
+	if len(m.feed.prefixes) > 0 {
+		shown := make([]string, len(m.feed.prefixes))
+		for i, p := range m.feed.prefixes {
+			shown[i] = "'" + m.showKey(p) + "'"
+		}
+		scope = strings.Join(shown, " ")
+	}
+	title := fmt.Sprintf("Change feed: %s · %s changes", scope, humanize.Comma(int64(m.feed.total)))
+	if m.feed.dropped > 0 {
+		title += fmt.Sprintf(" (%s not shown, too fast)", humanize.Comma(int64(m.feed.dropped)))
+	}
+	if m.pinnedTs != 0 {
+		title += " · snapshot pinned, view not following"
+	}
+	title += " · C stops"
+	lines := []string{truncateString(title, width-4)}
+	if m.feed.err != "" {
+		lines = append(lines, errStyle.Render("Error: "+m.feed.err))
+	}
+	if len(m.feed.changes) == 0 {
+		lines = append(lines, "No writes yet.")
+	}
+	rows := max(5, m.height/3-2)
+	for i := len(m.feed.changes) - 1; i >= 0 && len(lines) <= rows; i-- {
+		c := m.feed.changes[i]
+		op, size := "set", humanize.IBytes(uint64(c.Size))
+		if c.Deleted {
+			op, size = "delete", "-"
+		}
+		line := fmt.Sprintf("%s  %-6s  %9s  v%-8d  %s", c.At.Format("15:04:05.000"), op, size, c.Version, m.showKey(c.Key))
+		line = truncateString(line, width-4)
+		if c.Key == m.selected {
+			line = selectedRowStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return paneStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// filterScanChunk is how many keys one filter step reads, so a rare term
+// still shows progress and can be abandoned on a huge keyspace.
+const filterScanChunk = 20000
+
+// While a filter is applied the list holds only keys the store matched,
+// paged in as the cursor gets near the end; the unfiltered keyspace is
+// never loaded for it.
+type filterState struct {
+	active  bool
+	gen     int
+	query   store.KeyQuery
+	matcher store.Matcher
+	cursor  string
+	done    bool
+	loading bool
+	restore string // I go back to this key when the filter is cleared.
+}
+
+type filterKeysMsg struct {
+	gen  int
+	keys []string
+	next string
+	done bool
+	err  error
+}
+
+func filterKeysCmd(s Store, gen int, q store.KeyQuery, mt store.Matcher, cursor string, limit int) tea.Cmd {
+	return func() tea.Msg {
+		// One step reads at most filterScanChunk keys, so it needs no job.
+		keys, next, done, err := s.FilterKeys(context.Background(), q, mt, cursor, filterScanChunk, limit)
+		return filterKeysMsg{gen: gen, keys: keys, next: next, done: done, err: err}
+	}
+}
+
+// startStoreFilter swaps the loaded keys for the first page of matches and
+// starts counting them all.
+func (m Model) startStoreFilter(mt store.Matcher) (Model, tea.Cmd) {
+	restore := m.selected
+	if m.filter.active {
+		restore = m.filter.restore
+	}
+	m.filter = filterState{
+		active:  true,
+		gen:     m.filter.gen + 1,
+		query:   m.keyQuery,
+		matcher: mt,
+		loading: true,
+		restore: restore,
+	}
+	cmd := m.list.SetItems(nil)
+	m.list.ResetSelected()
+	m.lastKey = ""
+	m.firstKey = ""
+	m.hasMoreKeys = false
+	m.hasLessKeys = false
+	m.loadingKeys = false
+	m.filterCountLoading = true
+	m.filterCountValid = false
+	m.filterCountErr = ""
+	m, j, tick := m.startJob(jobCount, "Count "+m.describeMatcher(mt))
+	return m, tea.Batch(cmd, tick,
+		filterKeysCmd(m.store, m.filter.gen, m.keyQuery, mt, "", m.pageSize),
+		countFilterCmd(m.store, j, m.filter.gen, m.keyQuery, mt))
+}
+
+// clearStoreFilter goes back to the plain listing around the key that was
+// selected before the filter.
+func (m Model) clearStoreFilter() (Model, tea.Cmd) {
+	restore := m.filter.restore
+	m, cmd := m.resetKeys()
+	if restore == "" {
+		return m, tea.Batch(cmd, loadKeysCmd(m.store, m.keyQuery, "", m.pageSize))
+	}
+	m.hasMoreKeys = false
+	return m, tea.Batch(cmd, seekKeysCmd(m.store, m.keyQuery, restore, m.pageSize, true))
+}
+
+func (m Model) handleFilterKeys(msg filterKeysMsg) (Model, tea.Cmd) {
+	if !m.filter.active || msg.gen != m.filter.gen {
+		return m, nil
+	}
+	m.filter.loading = false
+	if msg.err != nil {
+		m.filter.done = true
+		m.status = errStyle.Render(fmt.Sprintf("Error: filter failed: %v", msg.err))
+		return m, nil
+	}
+	m.filter.cursor = msg.next
+	m.filter.done = msg.done
+	var cmd tea.Cmd
+	if len(msg.keys) > 0 {
+		items := m.list.Items()
+		for _, k := range msg.keys {
+			items = append(items, kvItem{key: k})
+		}
+		cmd = m.list.SetItems(items)
+	}
+	m, moreCmd := m.maybeLoadMoreMatches()
+	return m, tea.Batch(cmd, moreCmd)
+}
+
+// maybeLoadMoreMatches keeps scanning until the cursor has a page of
+// matches below it. Every loaded item is a match, so I count items rather
+// than the list's visible ones, which catch up asynchronously.
+func (m Model) maybeLoadMoreMatches() (Model, tea.Cmd) {
+	if m.filter.done || m.filter.loading {
+		return m, nil
+	}
+	if m.list.Index() < len(m.list.Items())-1-5 {
+		return m, nil
+	}
+	m.filter.loading = true
+	return m, filterKeysCmd(m.store, m.filter.gen, m.filter.query, m.filter.matcher, m.filter.cursor, m.pageSize)
+}
+
+// filterAccepts tells whether a key that appeared belongs in the list.
+func (m Model) filterAccepts(key string) bool {
+	if !m.filter.active {
+		return true
+	}
+	if !m.filter.done && (m.filter.cursor == "" || m.keyBefore(m.filter.cursor, key)) {
+		// The scan has not got there yet and will find it itself.
+		return false
+	}
+	return m.filter.matcher.Match(key)
+}
+
+// maybeStartFilterWork follows the list's filter state: an applied term is
+// matched store-side, and clearing it brings the plain listing back.
+func (m Model) maybeStartFilterWork() (Model, tea.Cmd) {
+	state := m.list.FilterState()
+	if state == list.Unfiltered {
+		m.filterCountLoading = false
+		m.filterCountErr = ""
+		m.filterCount = 0
+		m.filterCountValid = false
+		if m.filter.active {
+			return m.clearStoreFilter()
+		}
+		return m, nil
+	}
+	if state != list.FilterApplied {
+		// I filter only the loaded keys while the term is being typed.
+		return m, nil
+	}
+	mt, err := filterMatcher(m.matchMode, m.list.FilterValue())
+	if err != nil {
+		m.status = errStyle.Render(fmt.Sprintf("Error: %v", err))
+		m.filterCountErr = err.Error()
+		m.filterCountValid = false
+		return m, nil
+	}
+	if m.filter.active && m.filter.matcher.Mode == mt.Mode && m.filter.matcher.Term == mt.Term && m.filter.query == m.keyQuery {
+		return m, nil
+	}
+	return m.startStoreFilter(mt)
+}
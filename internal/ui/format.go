@@ -28,6 +28,19 @@ func (m Model) formatName() string {
 	}
 }
 
+func formatForKey(k string) valueFormat {
+	switch k {
+	case "h":
+		return fmtHex
+	case "b":
+		return fmtBase64
+	case "j":
+		return fmtJSON
+	default:
+		return fmtText
+	}
+}
+
 func (m Model) formatValue(_ string, v []byte) string {
 	switch m.valFormat {
 	case fmtText:
@@ -74,7 +87,10 @@ func (m *Model) startEditWithContent(key string, raw []byte) {
 	m.editKey = key
 	m.editing = true
 	m.lastLoadValue = raw
-	m.editorHelp = "(Ctrl+S save · Esc cancel)"
+	m.editTTL = ttlChange{}
+	m.editMeta = m.editOrigMeta
+	m.editMetaSet = false
+	m.editorHelp = "(Ctrl+S save · Ctrl+T TTL · Ctrl+O meta · Esc cancel)"
 
 	// I set formatted content in the editor.
 	switch m.valFormat {
@@ -107,7 +123,7 @@ func (m *Model) startEditWithContent(key string, raw []byte) {
 		m.editor.SetValue(string(pretty))
 	}
 	m.editor.CursorEnd()
-	m.status = "Editing. (Ctrl+S save · Esc cancel)"
+	m.status = "Editing. (Ctrl+S save · Ctrl+T TTL · Ctrl+O meta · Esc cancel)"
 }
 
 func (m Model) bytesFromEditor() ([]byte, error) {
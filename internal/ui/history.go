@@ -0,0 +1,253 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+type historyMode int
+
+const (
+	historyList historyMode = iota
+	historyValue
+	historyDiff
+)
+
+// I keep the version browser state for the selected key here.
+type historyState struct {
+	active         bool
+	key            string
+	versions       []store.KeyVersion
+	cursor         int
+	loading        bool
+	err            string
+	mode           historyMode
+	confirmRestore bool
+}
+
+type historyMsg struct {
+	key      string
+	versions []store.KeyVersion
+	err      error
+}
+
+type historyValueMsg struct {
+	key     string
+	version uint64
+	value   []byte
+	current []byte
+	diff    bool
+	err     error
+}
+
+func loadHistoryCmd(s Store, key string) tea.Cmd {
+	return func() tea.Msg {
+		versions, err := s.History(key)
+		return historyMsg{key: key, versions: versions, err: err}
+	}
+}
+
+func loadVersionCmd(s Store, key string, version uint64, diff bool) tea.Cmd {
+	return func() tea.Msg {
+		e, err := s.GetVersion(key, version)
+		value := e.Value
+		if err != nil || !diff {
+			return historyValueMsg{key: key, version: version, value: value, err: err}
+		}
+		current, err := s.Get(key)
+		if errors.Is(err, store.ErrKeyNotFound) {
+			current, err = nil, nil
+		}
+		return historyValueMsg{key: key, version: version, value: value, current: current, diff: true, err: err}
+	}
+}
+
+// I restore the version's value and UserMeta but keep the current TTL; the
+// old expiry has usually passed already.
+func restoreVersionCmd(s Store, key string, version uint64) tea.Cmd {
+	return func() tea.Msg {
+		e, err := s.GetVersion(key, version)
+		if err != nil {
+			return saveResultMsg{key: key, version: version, err: err}
+		}
+		err = s.SetWith(key, e.Value, store.SetOptions{KeepTTL: true, UserMeta: e.UserMeta})
+		return saveResultMsg{key: key, version: version, err: err}
+	}
+}
+
+func (m Model) openHistory(key string) (Model, tea.Cmd) {
+	if key == "" {
+		return m, nil
+	}
+	m.history = historyState{active: true, key: key, loading: true}
+	m.focusRight = true
+	m.status = "History: ↑/↓ select · Enter view · c compare with current · r restore · Esc back"
+	return m, loadHistoryCmd(m.store, key)
+}
+
+func (m Model) closeHistory() (Model, tea.Cmd) {
+	key := m.history.key
+	m.history = historyState{}
+	m.status = "History closed."
+	if key == "" || key != m.selected {
+		return m, nil
+	}
+	return m, loadValueCmd(m.store, key)
+}
+
+func (m Model) selectedVersion() (store.KeyVersion, bool) {
+	h := m.history
+	if h.cursor < 0 || h.cursor >= len(h.versions) {
+		return store.KeyVersion{}, false
+	}
+	return h.versions[h.cursor], true
+}
+
+func (m Model) updateHistoryKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.history.confirmRestore {
+		m.history.confirmRestore = false
+		v, ok := m.selectedVersion()
+		switch msg.String() {
+		case "y", "Y", "enter":
+			if !ok {
+				return m, nil
+			}
+			m.status = fmt.Sprintf("Restoring version %d...", v.Version)
+			return m, restoreVersionCmd(m.store, m.history.key, v.Version)
+		default:
+			m.status = "Restore canceled."
+			return m, nil
+		}
+	}
+
+	switch msg.String() {
+	case "esc", "shift+left":
+		if m.history.mode != historyList {
+			m.history.mode = historyList
+			return m, nil
+		}
+		return m.closeHistory()
+	case "up":
+		if m.history.mode == historyList {
+			m.history.cursor = max(0, m.history.cursor-1)
+			return m, nil
+		}
+	case "down":
+		if m.history.mode == historyList {
+			m.history.cursor = min(len(m.history.versions)-1, m.history.cursor+1)
+			return m, nil
+		}
+	case "enter":
+		if v, ok := m.selectedVersion(); ok {
+			return m, loadVersionCmd(m.store, m.history.key, v.Version, false)
+		}
+		return m, nil
+	case "c":
+		if v, ok := m.selectedVersion(); ok {
+			return m, loadVersionCmd(m.store, m.history.key, v.Version, true)
+		}
+		return m, nil
+	case "r":
+		if m.denyReadOnly("restore") {
+			return m, nil
+		}
+		v, ok := m.selectedVersion()
+		if !ok {
+			return m, nil
+		}
+		if v.Deleted || v.Expired {
+			m.status = errStyle.Render("Error: a deleted or expired version has no value to restore.")
+			return m, nil
+		}
+		m.history.confirmRestore = true
+		m.status = fmt.Sprintf("Restore '%s' to version %d? (y/n)", m.showKey(m.history.key), v.Version)
+		return m, nil
+	case "t", "h", "b", "j":
+		m.valFormat = formatForKey(msg.String())
+		if v, ok := m.selectedVersion(); ok && m.history.mode != historyList {
+			return m, loadVersionCmd(m.store, m.history.key, v.Version, m.history.mode == historyDiff)
+		}
+		return m, nil
+	}
+
+	if m.history.mode != historyList {
+		var vcmd tea.Cmd
+		m.viewport, vcmd = m.viewport.Update(msg)
+		return m, vcmd
+	}
+	return m, nil
+}
+
+func (m Model) historyTitle() string {
+	h := m.history
+	title := fmt.Sprintf("History: %s", m.showKey(h.key))
+	v, ok := m.selectedVersion()
+	switch {
+	case h.mode == historyValue && ok:
+		title += fmt.Sprintf("  @%d", v.Version)
+	case h.mode == historyDiff && ok:
+		title += fmt.Sprintf("  current vs @%d", v.Version)
+	case !h.loading && h.err == "":
+		title += fmt.Sprintf("  (%d versions)", len(h.versions))
+	}
+	return title
+}
+
+func (m Model) historyView(lay layout) string {
+	h := m.history
+	if h.mode != historyList {
+		return m.viewport.View()
+	}
+	var lines []string
+	switch {
+	case h.loading:
+		lines = append(lines, "Loading…")
+	case h.err != "":
+		lines = append(lines, errStyle.Render("Error: "+h.err))
+	case len(h.versions) == 0:
+		lines = append(lines, "No versions found.")
+	default:
+		height := max(1, lay.rightContentHeight)
+		start := 0
+		if h.cursor >= height {
+			start = h.cursor - height + 1
+		}
+		end := min(len(h.versions), start+height)
+		for i := start; i < end; i++ {
+			line := truncateString(m.versionLine(h.versions[i], i == 0), lay.rightContentWidth-2)
+			if i == h.cursor {
+				lines = append(lines, "│ "+selectedRowStyle.Render(line))
+			} else {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+	// I pad to the pane height so the border lines up with the list pane.
+	for len(lines) < lay.rightContentHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) versionLine(v store.KeyVersion, latest bool) string {
+	parts := []string{fmt.Sprintf("ts %-10d", v.Version), fmt.Sprintf("%9s", humanize.IBytes(uint64(v.Size))), "meta " + formatMeta(v.UserMeta)}
+	switch {
+	case v.Deleted:
+		parts = append(parts, "deleted")
+	case v.Expired:
+		parts = append(parts, "expired")
+	case latest:
+		parts = append(parts, "current")
+	}
+	if v.ExpiresAt != 0 {
+		parts = append(parts, "expires "+time.Unix(int64(v.ExpiresAt), 0).Format(time.DateTime))
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"badge-reader/internal/store"
+	"badge-reader/internal/transfer"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// An import always starts with a dry run: the file is read and checked
+// against the DB, and the counts are shown before y/n is taken.
+type importState struct {
+	prompt        bool
+	input         textinput.Model
+	templateInput textinput.Model
+	focusTemplate bool
+	encoding      transfer.Encoding
+	conflict      store.Conflict
+
+	confirm  bool // the dry run is done and its counts are on screen
+	checkJob int
+	opts     transfer.ImportOptions
+	path     string
+}
+
+type importResultMsg struct {
+	job     int
+	dryRun  bool
+	path    string
+	summary store.ImportSummary
+}
+
+func newImportInputs() (textinput.Model, textinput.Model) {
+	in := textinput.New()
+	in.Placeholder = "export.jsonl"
+	in.CharLimit = 1024
+	in.Prompt = "File: "
+
+	tmpl := textinput.New()
+	tmpl.Placeholder = "key column"
+	tmpl.CharLimit = 1024
+	tmpl.Prompt = "Key template: "
+	return in, tmpl
+}
+
+func importCmd(s Store, j job, path string, o transfer.ImportOptions) tea.Cmd {
+	return func() tea.Msg {
+		sum := transfer.ImportFile(j.ctx, s, path, o, j.progress)
+		return importResultMsg{job: j.id, dryRun: o.DryRun, path: path, summary: sum}
+	}
+}
+
+func (m Model) openImportPrompt() (Model, tea.Cmd) {
+	if m.denyReadOnly("import") {
+		return m, nil
+	}
+	m.imp.prompt = true
+	m.imp.focusTemplate = false
+	m.imp.input.CursorEnd()
+	m.imp.templateInput.Blur()
+	m.status = "Import a .jsonl or .csv file; a dry run shows the counts first. (Enter check · Tab field · Ctrl+R on conflict · Ctrl+E encoding · Esc cancel)"
+	return m, m.imp.input.Focus()
+}
+
+func (m Model) closeImportPrompt() Model {
+	m.imp.prompt = false
+	m.imp.input.Blur()
+	m.imp.templateInput.Blur()
+	return m
+}
+
+func (m Model) updateImportPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m = m.closeImportPrompt()
+		m.status = "Import canceled."
+		return m, nil
+	case "tab", "shift+tab":
+		m.imp.focusTemplate = !m.imp.focusTemplate
+		if m.imp.focusTemplate {
+			m.imp.input.Blur()
+			return m, m.imp.templateInput.Focus()
+		}
+		m.imp.templateInput.Blur()
+		return m, m.imp.input.Focus()
+	case "ctrl+r":
+		m.imp.conflict = m.imp.conflict.Next()
+		return m, nil
+	case "ctrl+e":
+		m.imp.encoding = m.imp.encoding.Next()
+		return m, nil
+	case "enter":
+		return m.startImportCheck()
+	}
+	var cmd tea.Cmd
+	if m.imp.focusTemplate {
+		m.imp.templateInput, cmd = m.imp.templateInput.Update(msg)
+	} else {
+		m.imp.input, cmd = m.imp.input.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m Model) startImportCheck() (Model, tea.Cmd) {
+	if _, busy := m.runningJob(jobImport); busy {
+		m.status = errStyle.Render("Error: an import is already running (J shows jobs).")
+		return m, nil
+	}
+	path := strings.TrimSpace(m.imp.input.Value())
+	if path == "" {
+		path = m.imp.input.Placeholder
+	}
+	m = m.closeImportPrompt()
+	m.imp.path = path
+	m.imp.opts = transfer.ImportOptions{
+		Format:      transfer.FormatForPath(path),
+		KeyTemplate: strings.TrimSpace(m.imp.templateInput.Value()),
+		Encoding:    m.imp.encoding,
+		Conflict:    m.imp.conflict,
+		DryRun:      true,
+	}
+	m, j, tick := m.startJob(jobImport, "Check import of "+path)
+	m.imp.checkJob = j.id
+	m.status = fmt.Sprintf("Checking %s… (J shows jobs)", path)
+	return m, tea.Batch(tick, importCmd(m.store, j, path, m.imp.opts))
+}
+
+func (m Model) updateImportConfirm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "enter":
+		m.imp.confirm = false
+		o := m.imp.opts
+		o.DryRun = false
+		m, j, tick := m.startJob(jobImport, "Import "+m.imp.path)
+		m.status = fmt.Sprintf("Importing %s… (J shows jobs)", m.imp.path)
+		return m, tea.Batch(tick, importCmd(m.store, j, m.imp.path, o))
+	case "n", "N", "esc":
+		m.imp.confirm = false
+		m.status = "Import canceled."
+	}
+	return m, nil
+}
+
+func (m Model) handleImportResult(msg importResultMsg) (Model, tea.Cmd) {
+	sum := msg.summary
+	m = m.endJob(msg.job, sum.Err)
+	counts := transfer.DescribeImport(sum)
+	if msg.dryRun {
+		if msg.job != m.imp.checkJob {
+			return m, nil
+		}
+		switch {
+		case errors.Is(sum.Err, context.Canceled):
+			m.status = "Import check canceled."
+		case sum.Err != nil:
+			m.status = errStyle.Render(fmt.Sprintf("Error: %s cannot be imported: %v", msg.path, sum.Err))
+		case sum.Created+sum.Overwritten == 0:
+			m.status = fmt.Sprintf("Nothing to import from %s (%s).", msg.path, counts)
+		default:
+			m.imp.confirm = true
+			m.status = fmt.Sprintf("Import %s: %s, on conflict %s. Go ahead? (y/n)", msg.path, counts, m.imp.opts.Conflict)
+		}
+		return m, nil
+	}
+
+	switch {
+	case errors.Is(sum.Err, context.Canceled):
+		m.status = fmt.Sprintf("Import of %s canceled after %s; u undoes it.", msg.path, counts)
+	case notLogged(sum.Err) && sum.Failed == 0:
+		m.status = errStyle.Render(fmt.Sprintf("Warning: imported %s: %s, but %v", msg.path, counts, sum.Err))
+	case sum.Err != nil && sum.Created+sum.Overwritten > 0:
+		m.status = errStyle.Render(fmt.Sprintf("Error: import of %s stopped (%s; those were written, u undoes them): %v", msg.path, counts, sum.Err))
+	case sum.Err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: import of %s stopped (%s): %v", msg.path, counts, sum.Err))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("Imported %s: %s.", msg.path, counts))
+	}
+	if sum.Created+sum.Overwritten == 0 {
+		return m, nil
+	}
+	status := m.status
+	m, cmd := m.setKeyQuery(m.keyQuery)
+	m.status = status
+	if m.selected != "" {
+		cmd = tea.Batch(cmd, loadValueCmd(m.store, m.selected))
+	}
+	return m, cmd
+}
+
+func (m Model) importPromptText() string {
+	format := transfer.FormatForPath(m.imp.input.Value())
+	return fmt.Sprintf("Import (%s, %s values, on conflict %s): %s  %s  (Enter check · Tab field · Ctrl+R on conflict · Ctrl+E encoding · Esc cancel)",
+		format, m.imp.encoding, m.imp.conflict, m.imp.input.View(), m.imp.templateInput.View())
+}
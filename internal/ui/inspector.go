@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+// inspectorLines is the height the panel takes from the value viewport.
+const inspectorLines = 5
+
+type inspectMsg struct {
+	key  string
+	info store.KeyInfo
+	err  error
+}
+
+func inspectCmd(s Store, key string) tea.Cmd {
+	return func() tea.Msg {
+		info, err := s.Inspect(key)
+		return inspectMsg{key: key, info: info, err: err}
+	}
+}
+
+func (m Model) toggleInspector() (Model, tea.Cmd) {
+	m.showInspector = !m.showInspector
+	m.resizeViewport()
+	if !m.showInspector || m.selected == "" {
+		return m, nil
+	}
+	return m, inspectCmd(m.store, m.selected)
+}
+
+// resizeViewport leaves room for the inspector above the value.
+func (m *Model) resizeViewport() {
+	h := computeLayout(m.width, m.height).rightContentHeight
+	if m.showInspector {
+		h -= inspectorLines
+	}
+	m.viewport.Height = max(1, h)
+}
+
+func (m Model) inspectorView(width int) string {
+	var lines []string
+	switch {
+	case m.selected == "":
+		lines = []string{"No key selected."}
+	case m.inspectErr != "":
+		lines = []string{errStyle.Render("Error: " + m.inspectErr)}
+	case m.inspectKey != m.selected:
+		lines = []string{"Loading…"}
+	default:
+		in := m.inspectInfo
+		storage := "inline (LSM tree)"
+		if in.InValueLog {
+			storage = "value log"
+		}
+		expiry := "never"
+		if in.ExpiresAt != 0 {
+			expiry = describeExpiry(in.ExpiresAt, time.Now())
+		}
+		lines = []string{
+			fmt.Sprintf("Key %s · Value %s (%d B) · Estimated %s",
+				humanize.IBytes(uint64(in.KeySize)), humanize.IBytes(uint64(in.ValueSize)), in.ValueSize, humanize.IBytes(uint64(in.EstimatedSize))),
+			fmt.Sprintf("Version %d · Meta %s · Stored %s", in.Version, formatMeta(in.UserMeta), storage),
+			"Expiry " + expiry,
+			"SHA-256 " + hex.EncodeToString(in.SHA256[:]),
+			fmt.Sprintf("CRC32 %08x (IEEE)", in.CRC32),
+		}
+	}
+	for len(lines) < inspectorLines {
+		lines = append(lines, "")
+	}
+	for i, l := range lines {
+		lines[i] = inspectorStyle.Render(padToWidth(truncateString(l, width), width))
+	}
+	return strings.Join(lines, "\n")
+}
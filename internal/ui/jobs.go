@@ -0,0 +1,330 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// jobTick is how often the panel and the header redraw while jobs run.
+	jobTick = 250 * time.Millisecond
+	// jobHistory is how many finished jobs the panel keeps.
+	jobHistory = 5
+)
+
+type jobKind int
+
+const (
+	jobCount jobKind = iota
+	jobGroups
+	jobSearch
+	jobDelete
+	jobPreview
+	jobExport
+	jobImport
+	jobBackup
+	jobTree
+)
+
+// A new scan replaces a running one of the same kind; its result would be
+// stale anyway. Bulk operations must not overlap, so they are refused.
+// Exports and backups write to their own files and run side by side, and
+// so do tree loads, each for its own node.
+func (k jobKind) replaces() bool {
+	return k != jobDelete && k != jobExport && k != jobImport && k != jobBackup && k != jobTree
+}
+
+// job is a long scan or bulk operation running off the UI goroutine.
+type job struct {
+	id       int
+	kind     jobKind
+	label    string
+	started  time.Time
+	ended    time.Time
+	outcome  string // I leave it empty while the job runs.
+	progress *store.Progress
+	total    int64 // known up front for some jobs, so they get a progress bar
+	// I keep ctx because some jobs, like the value search, run as a chain
+	// of commands that all need it.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (j job) running() bool { return j.outcome == "" }
+
+type jobsState struct {
+	list    []job
+	nextID  int
+	show    bool
+	cursor  int
+	ticking bool
+}
+
+type jobTickMsg struct{}
+
+func jobTickCmd() tea.Cmd {
+	return tea.Tick(jobTick, func(time.Time) tea.Msg { return jobTickMsg{} })
+}
+
+// startJob registers a job and returns it with the command that keeps the
+// job panel ticking, if it was not already.
+func (m Model) startJob(kind jobKind, label string) (Model, job, tea.Cmd) {
+	if kind.replaces() {
+		if old, ok := m.runningJob(kind); ok {
+			m = m.cancelJob(old.id, "replaced")
+		}
+	}
+	m.jobs.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := job{
+		id:       m.jobs.nextID,
+		kind:     kind,
+		label:    label,
+		started:  time.Now(),
+		progress: &store.Progress{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	m.jobs.list = append(append([]job(nil), m.jobs.list...), j)
+	var cmd tea.Cmd
+	if !m.jobs.ticking {
+		m.jobs.ticking = true
+		cmd = jobTickCmd()
+	}
+	return m, j, cmd
+}
+
+func (m Model) setJobTotal(id, total int) Model {
+	list := append([]job(nil), m.jobs.list...)
+	for i := range list {
+		if list[i].id == id {
+			list[i].total = int64(total)
+		}
+	}
+	m.jobs.list = list
+	return m
+}
+
+func (m Model) runningJob(kind jobKind) (job, bool) {
+	for _, j := range m.jobs.list {
+		if j.kind == kind && j.running() {
+			return j, true
+		}
+	}
+	return job{}, false
+}
+
+func (m Model) jobByID(id int) (job, bool) {
+	for _, j := range m.jobs.list {
+		if j.id == id {
+			return j, true
+		}
+	}
+	return job{}, false
+}
+
+// endJob records how a job finished. Results of a job that was already
+// canceled or replaced keep that outcome.
+func (m Model) endJob(id int, err error) Model {
+	outcome := "done"
+	switch {
+	case errors.Is(err, context.Canceled):
+		outcome = "canceled"
+	case notLogged(err):
+		outcome = "done; " + err.Error()
+	case err != nil:
+		outcome = "failed: " + err.Error()
+	}
+	return m.settleJob(id, outcome)
+}
+
+// cancelJob stops a running job right away; its result, if one still
+// comes, is ignored by the caller's generation checks.
+func (m Model) cancelJob(id int, outcome string) Model {
+	if j, ok := m.jobByID(id); ok && j.running() {
+		j.cancel()
+	}
+	return m.settleJob(id, outcome)
+}
+
+func (m Model) cancelJobs(kind jobKind) Model {
+	for _, j := range m.jobs.list {
+		if j.kind == kind && j.running() {
+			m = m.cancelJob(j.id, "canceled")
+		}
+	}
+	return m
+}
+
+// CancelJobs stops everything still running, for when the program exits.
+func (m Model) CancelJobs() {
+	for _, j := range m.jobs.list {
+		if j.running() {
+			j.cancel()
+		}
+	}
+}
+
+func (m Model) settleJob(id int, outcome string) Model {
+	list := make([]job, 0, len(m.jobs.list))
+	finished := 0
+	// I walk newest first so the oldest finished jobs are the ones dropped.
+	for i := len(m.jobs.list) - 1; i >= 0; i-- {
+		j := m.jobs.list[i]
+		if j.id == id && j.running() {
+			j.cancel() // I release the context either way.
+			j.outcome = outcome
+			j.ended = time.Now()
+		}
+		if !j.running() {
+			if finished++; finished > jobHistory {
+				continue
+			}
+		}
+		list = append(list, j)
+	}
+	for i, k := 0, len(list)-1; i < k; i, k = i+1, k-1 {
+		list[i], list[k] = list[k], list[i]
+	}
+	m.jobs.list = list
+	m.jobs.cursor = clamp(m.jobs.cursor, 0, max(0, len(list)-1))
+	return m
+}
+
+func (m Model) runningJobs() int {
+	n := 0
+	for _, j := range m.jobs.list {
+		if j.running() {
+			n++
+		}
+	}
+	return n
+}
+
+func (m Model) handleJobTick() (Model, tea.Cmd) {
+	if m.runningJobs() == 0 {
+		m.jobs.ticking = false
+		return m, nil
+	}
+	return m, jobTickCmd()
+}
+
+func (m Model) toggleJobs() (Model, tea.Cmd) {
+	m.jobs.show = !m.jobs.show
+	if m.jobs.show {
+		m.jobs.cursor = 0
+		m.status = "Jobs. ↑/↓ select · c cancel · J/Esc close"
+	} else {
+		m.status = "List focused."
+	}
+	return m, nil
+}
+
+func (m Model) updateJobsKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "J":
+		return m.toggleJobs()
+	case "up":
+		m.jobs.cursor = max(0, m.jobs.cursor-1)
+	case "down":
+		m.jobs.cursor = clamp(m.jobs.cursor+1, 0, max(0, len(m.jobs.list)-1))
+	case "c":
+		if m.jobs.cursor >= len(m.jobs.list) {
+			return m, nil
+		}
+		j := m.jobs.list[m.jobs.cursor]
+		if !j.running() {
+			m.status = fmt.Sprintf("'%s' is not running.", j.label)
+			return m, nil
+		}
+		return m.stopJob(j)
+	}
+	return m, nil
+}
+
+// stopJob cancels a job and puts the view that was waiting on it back in
+// a settled state.
+func (m Model) stopJob(j job) (Model, tea.Cmd) {
+	m = m.cancelJob(j.id, "canceled")
+	switch j.kind {
+	case jobCount:
+		m.filterCountLoading = false
+	case jobGroups:
+		m.groupCountsLoading = false
+		m.groupCountsErr = "canceled"
+	case jobSearch:
+		m.search.scanning = false
+	case jobDelete:
+		// The result still comes back with the keys deleted so far.
+	case jobExport:
+		// The result comes back once the partial file is removed.
+	case jobImport:
+		// The result still comes back with the keys imported so far.
+	case jobBackup:
+		// The result comes back once the partial file is removed.
+	case jobTree:
+		for parent, c := range m.tree.children {
+			if c.loading && c.job == j.id {
+				m = m.settleTreeLoad(parent, c)
+			}
+		}
+	case jobPreview:
+		if m.preview.job == j.id {
+			m.preview.loading = false
+			m.preview.err = "canceled"
+		}
+	}
+	m.status = fmt.Sprintf("Canceled '%s'.", j.label)
+	return m, nil
+}
+
+// progressBar draws done out of total as a bar of width cells and a percentage.
+func progressBar(done, total int64, width int) string {
+	frac := 1.0
+	if done < total {
+		frac = float64(done) / float64(total)
+	}
+	filled := int(frac * float64(width))
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("█", filled), strings.Repeat("░", width-filled), frac*100)
+}
+
+func (m Model) jobsView(width int) string {
+	lines := []string{"Jobs (↑/↓ select · c cancel · J/Esc close)"}
+	if len(m.jobs.list) == 0 {
+		lines = append(lines, "No jobs yet.")
+	}
+	now := time.Now()
+	for i, j := range m.jobs.list {
+		end := now
+		state := "running"
+		if !j.running() {
+			end = j.ended
+			state = j.outcome
+		} else if j.total > 0 {
+			state = progressBar(j.progress.Scanned(), j.total, 20)
+		}
+		line := fmt.Sprintf("%-36s %12s keys  %8s  %s",
+			truncateString(j.label, 36),
+			humanize.Comma(j.progress.Scanned()),
+			end.Sub(j.started).Round(100*time.Millisecond),
+			state)
+		line = truncateString(line, width-4)
+		if i == m.jobs.cursor {
+			line = selectedRowStyle.Render(line)
+		} else if !j.running() {
+			line = inspectorStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return paneStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,280 @@
+package ui
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+type keyDisplay int
+
+const (
+	keyEscaped     keyDisplay = iota // bin\x00\x00\x00\x01key
+	keyHexSegments                   // bin\x{00000001}key
+	keyHex                           // 0x62696e000000016b6579
+)
+
+func (d keyDisplay) name() string {
+	switch d {
+	case keyHexSegments:
+		return "hex-seg"
+	case keyHex:
+		return "hex"
+	default:
+		return "escaped"
+	}
+}
+
+func (d keyDisplay) next() keyDisplay {
+	return (d + 1) % 3
+}
+
+// displayKey renders a raw key so it never breaks the terminal layout. Every
+// form it produces is accepted back by parseKeyInput.
+func displayKey(key string, d keyDisplay) string {
+	return renderKey(key, d, nil, nil)
+}
+
+// renderKey is displayKey with paint applied to every piece; on is set for
+// pieces that cover a byte offset listed in marked.
+func renderKey(key string, d keyDisplay, marked []int, paint func(s string, on bool) string) string {
+	var b strings.Builder
+	set := make(map[int]bool, len(marked))
+	for _, i := range marked {
+		set[i] = true
+	}
+	// I paint runs of pieces, not single characters, to keep rendering cheap.
+	var run strings.Builder
+	runOn := false
+	endRun := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if paint != nil {
+			b.WriteString(paint(run.String(), runOn))
+		} else {
+			b.WriteString(run.String())
+		}
+		run.Reset()
+	}
+	write := func(s string, on bool) {
+		if on != runOn {
+			endRun()
+			runOn = on
+		}
+		run.WriteString(s)
+	}
+	// A bare 0x would read back as text, so the empty key stays empty.
+	if d == keyHex && key != "" {
+		if paint == nil {
+			return "0x" + hex.EncodeToString([]byte(key))
+		}
+		write("0x", false)
+		for i := 0; i < len(key); i++ {
+			write(fmt.Sprintf("%02x", key[i]), set[i])
+		}
+		endRun()
+		return b.String()
+	}
+	var pending []byte
+	pendingAt := 0
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if d == keyHexSegments {
+			on := false
+			for i := range pending {
+				on = on || set[pendingAt+i]
+			}
+			write(fmt.Sprintf(`\x{%s}`, hex.EncodeToString(pending)), on)
+		} else {
+			for i, c := range pending {
+				write(fmt.Sprintf(`\x%02x`, c), set[pendingAt+i])
+			}
+		}
+		pending = pending[:0]
+	}
+	base := 0
+	if strings.HasPrefix(key, "0x") || strings.HasPrefix(key, "0X") {
+		// I escape a leading 0x so the result is not read back as a hex key.
+		write(`\x30`, set[0])
+		key, base = key[1:], 1
+	}
+	for i := 0; i < len(key); {
+		r, size := utf8.DecodeRuneInString(key[i:])
+		if (r == utf8.RuneError && size <= 1) || !unicode.IsPrint(r) {
+			if len(pending) == 0 {
+				pendingAt = base + i
+			}
+			pending = append(pending, key[i:i+size]...)
+			i += size
+			continue
+		}
+		flush()
+		if r == '\\' {
+			write(`\\`, set[base+i])
+		} else {
+			write(string(r), set[base+i])
+		}
+		i += size
+	}
+	flush()
+	endRun()
+	return b.String()
+}
+
+// parseKeyInput turns what the user typed into raw key bytes:
+//
+//	0x62696e00          whole key in hex
+//	bin\x00\x01key      \xNN byte escapes
+//	bin\x{0001}key      \x{...} hex segments
+//	\\ \n \r \t \0      the usual single-character escapes
+func parseKeyInput(s string) (string, error) {
+	if raw, ok := parseHexKey(s); ok {
+		return raw, nil
+	}
+	return unescapeKey(s, false)
+}
+
+// parsePatternInput decodes byte escapes but leaves glob escapes such as \*
+// for path.Match; decoded bytes that are glob metacharacters stay literal.
+func parsePatternInput(s string) (string, error) {
+	if raw, ok := parseHexKey(s); ok {
+		return escapeGlob(raw), nil
+	}
+	return unescapeKey(s, true)
+}
+
+func parseHexKey(s string) (string, bool) {
+	if len(s) < 3 || (s[:2] != "0x" && s[:2] != "0X") {
+		return "", false
+	}
+	raw, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+func unescapeKey(s string, glob bool) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("trailing backslash in %q", s)
+		}
+		i++
+		switch s[i] {
+		case 'x':
+			var digits string
+			if i+1 < len(s) && s[i+1] == '{' {
+				end := strings.IndexByte(s[i+2:], '}')
+				if end < 0 {
+					return "", fmt.Errorf("unterminated \\x{ in %q", s)
+				}
+				digits = s[i+2 : i+2+end]
+				i += 2 + end
+			} else {
+				if i+2 >= len(s) {
+					return "", fmt.Errorf("short \\x escape in %q", s)
+				}
+				digits = s[i+1 : i+3]
+				i += 2
+			}
+			raw, err := hex.DecodeString(digits)
+			if err != nil {
+				return "", fmt.Errorf("invalid hex escape %q: %w", digits, err)
+			}
+			if glob {
+				b.WriteString(escapeGlob(string(raw)))
+			} else {
+				b.Write(raw)
+			}
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '0':
+			b.WriteByte(0)
+		case '\\':
+			if glob {
+				b.WriteString(`\\`)
+			} else {
+				b.WriteByte('\\')
+			}
+		default:
+			if !glob {
+				return "", fmt.Errorf("unknown escape \\%c in %q", s[i], s)
+			}
+			// I hand other escapes (\*, \?, \[) through to path.Match.
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// keyFilter lets the list filter accept the same syntax as the other key
+// inputs while still matching against the raw key bytes, in the given mode.
+func keyFilter(mode store.MatchMode) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		mt, err := filterMatcher(mode, term)
+		if err != nil {
+			// I show nothing while a regex or glob is still half typed.
+			return nil
+		}
+		ranked := mt.RankKeys(targets)
+		out := make([]list.Rank, len(ranked))
+		for i, r := range ranked {
+			out[i] = list.Rank{Index: r.Index, MatchedIndexes: r.Matched}
+		}
+		return out
+	}
+}
+
+// filterMatcher decodes term the way its mode expects: regexes keep their
+// own \x escapes and globs keep \* and friends.
+func filterMatcher(mode store.MatchMode, term string) (store.Matcher, error) {
+	switch mode {
+	case store.MatchRegex:
+	case store.MatchGlob:
+		raw, err := parsePatternInput(term)
+		if err != nil {
+			return store.Matcher{}, err
+		}
+		term = raw
+	default:
+		if raw, err := parseKeyInput(term); err == nil {
+			term = raw
+		}
+	}
+	return store.NewMatcher(mode, term)
+}
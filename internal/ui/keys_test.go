@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKeyInput(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"user:42", "user:42"},
+		{"héllo/ключ", "héllo/ключ"},
+		{`bin\x00\x00\x00\x01key`, "bin\x00\x00\x00\x01key"},
+		{`bin\x{00000001}key`, "bin\x00\x00\x00\x01key"},
+		{`a\x{}b`, "ab"},
+		{`\\ \n \r \t \0`, "\\ \n \r \t \x00"},
+		{`\xFF\xfe`, "\xff\xfe"},
+		{"0x62696e00", "bin\x00"},
+		{"0X62696E00", "bin\x00"},
+		// Not valid hex, or too short to be a hex key: taken as text.
+		{"0xyz", "0xyz"},
+		{"0x", "0x"},
+		{`\x30x41`, "0x41"},
+	}
+	for _, tt := range tests {
+		got, err := parseKeyInput(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeyInputRejects(t *testing.T) {
+	for _, in := range []string{
+		`trailing\`,
+		`\q`,
+		`\x4`,
+		`\xzz`,
+		`\x{123`,
+		`\x{12`,
+		`\x{abc}`,
+	} {
+		if got, err := parseKeyInput(in); err == nil {
+			t.Errorf("%q: got %q, want an error", in, got)
+		}
+	}
+}
+
+// Every way a key is shown reads back as the same bytes.
+func TestDisplayKeyRoundTrip(t *testing.T) {
+	keys := []string{
+		"",
+		"user:42",
+		"héllo/ключ",
+		"bin\x00\x00\x00\x01key",
+		`back\slash\x00`,
+		"tab\there\nnewline",
+		"0x41",
+		"0Xdeadbeef",
+		"\xff\xfe\xfd",
+		"ok\xe2\x82", // a cut UTF-8 sequence
+		"​ zero width",
+		strings.Repeat("\x00", 5),
+	}
+	for _, k := range keys {
+		for _, d := range []keyDisplay{keyEscaped, keyHexSegments, keyHex} {
+			shown := displayKey(k, d)
+			got, err := parseKeyInput(shown)
+			if err != nil {
+				t.Errorf("%q shown %s as %q: %v", k, d.name(), shown, err)
+				continue
+			}
+			if got != k {
+				t.Errorf("%q shown %s as %q reads back as %q", k, d.name(), shown, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Pattern delete starts out as a glob, as it always was.
+const defaultPatternMode = store.MatchGlob
+
+// cycleMatchMode switches how the list filter and the match counter read
+// the filter term, and filters the loaded keys again.
+func (m Model) cycleMatchMode() (Model, tea.Cmd) {
+	m.matchMode = m.matchMode.Next()
+	m.list.Filter = keyFilter(m.matchMode)
+	m.status = fmt.Sprintf("Match mode: %s", m.matchMode)
+	var cmd tea.Cmd
+	if m.list.FilterState() != list.Unfiltered {
+		cmd = m.list.SetItems(m.list.Items())
+	}
+	m, filterCmd := m.maybeStartFilterWork()
+	return m, tea.Batch(cmd, filterCmd)
+}
+
+// nextPatternMode skips fuzzy: it matches far too much for a delete.
+func nextPatternMode(mode store.MatchMode) store.MatchMode {
+	mode = mode.Next()
+	if mode == store.MatchFuzzy {
+		mode = mode.Next()
+	}
+	return mode
+}
+
+func (m Model) describeMatcher(mt store.Matcher) string {
+	return fmt.Sprintf("%s '%s'", mt.Mode, m.showKey(mt.Term))
+}
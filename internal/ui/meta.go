@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// parseMeta accepts a UserMeta byte as decimal, 0x hex, 0o octal or 0b binary.
+func parseMeta(s string) (byte, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid meta %q (want 0-255, e.g. 7 or 0x07)", s)
+	}
+	return byte(n), nil
+}
+
+func formatMeta(b byte) string {
+	return fmt.Sprintf("0x%02x", b)
+}
+
+// I open the same prompt for the list filter and for the edit flow.
+func (m Model) openMetaPrompt(forEdit bool) (Model, tea.Cmd) {
+	m.metaPrompt = true
+	m.metaPromptEdit = forEdit
+	value := ""
+	switch {
+	case forEdit && m.editMetaSet:
+		value = formatMeta(m.editMeta)
+	case forEdit:
+		value = formatMeta(m.editOrigMeta)
+	case m.keyQuery.HasMeta:
+		value = formatMeta(m.keyQuery.UserMeta)
+	}
+	m.metaInput.SetValue(value)
+	m.metaInput.CursorEnd()
+	if forEdit {
+		m.status = "UserMeta for this save: 0-255 or 0x.. · empty keeps it (Enter apply · Esc cancel)"
+	} else {
+		m.status = "Show keys whose UserMeta is: 0-255 or 0x.. · empty shows all (Enter apply · Esc cancel)"
+	}
+	return m, m.metaInput.Focus()
+}
+
+func (m Model) updateMetaPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.metaPrompt = false
+		m.metaInput.Blur()
+		m.status = "Meta unchanged."
+		if m.metaPromptEdit {
+			return m, m.editor.Focus()
+		}
+		return m, nil
+	case "enter":
+		text := strings.TrimSpace(m.metaInput.Value())
+		var meta byte
+		if text != "" {
+			var err error
+			if meta, err = parseMeta(text); err != nil {
+				m.status = errStyle.Render(fmt.Sprintf("Error: %v", err))
+				return m, nil
+			}
+		}
+		m.metaPrompt = false
+		m.metaInput.Blur()
+		if m.metaPromptEdit {
+			m.editMetaSet = text != "" && meta != m.editOrigMeta
+			m.editMeta = meta
+			m.status = m.editMetaLabel() + " (applied on Ctrl+S)"
+			return m, m.editor.Focus()
+		}
+		q := m.keyQuery
+		q.HasMeta = text != ""
+		q.UserMeta = meta
+		if q.HasMeta {
+			m.status = fmt.Sprintf("Showing keys with UserMeta %s.", formatMeta(meta))
+		} else {
+			m.status = "Meta filter cleared."
+		}
+		return m.setKeyQuery(q)
+	}
+	var cmd tea.Cmd
+	m.metaInput, cmd = m.metaInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) editMetaLabel() string {
+	if m.editMetaSet {
+		return fmt.Sprintf("Meta %s → %s", formatMeta(m.editOrigMeta), formatMeta(m.editMeta))
+	}
+	return "Meta " + formatMeta(m.editOrigMeta)
+}
+
+// saveOptions turns the pending TTL and meta choices into a store write.
+func (m Model) saveOptions() store.SetOptions {
+	o := store.SetOptions{KeepTTL: true, KeepMeta: !m.editMetaSet, UserMeta: m.editMeta}
+	switch m.editTTL.mode {
+	case ttlSet:
+		o.KeepTTL = false
+		o.ExpiresAt = m.editTTL.expiresAt
+	case ttlClear:
+		o.KeepTTL = false
+	}
+	return o
+}
+
+// setKeyQuery drops the loaded keys and pages again from the start.
+func (m Model) setKeyQuery(q store.KeyQuery) (Model, tea.Cmd) {
+	m.keyQuery = q
+	m, cmd := m.resetKeys()
+	return m, tea.Batch(cmd, loadKeysCmd(m.store, q, "", m.pageSize))
+}
+
+func (m Model) resetKeys() (Model, tea.Cmd) {
+	m.filter = filterState{gen: m.filter.gen + 1}
+	m = m.cancelJobs(jobCount)
+	m.list.ResetFilter()
+	cmd := m.list.SetItems(nil)
+	m.list.ResetSelected()
+	m.lastKey = ""
+	m.firstKey = ""
+	m.hasMoreKeys = true
+	m.hasLessKeys = false
+	m.loadingKeys = true
+	return m, cmd
+}
@@ -1,7 +1,10 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -13,16 +16,17 @@ import (
 
 const defaultPageSize = 500
 
-func NewModel(store Store, dbPath string) Model {
+func NewModel(store Store, dbPath string, opts Options) Model {
 	items := make([]list.Item, 0, defaultPageSize)
 
-	l := list.New(items, thinCursorDelegate{}, 0, 0)
+	l := list.New(items, thinCursorDelegate{display: keyEscaped}, 0, 0)
 	l.Title = "Badger Keys"
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
 	l.SetShowHelp(false)
 	l.SetShowPagination(false)
 	l.SetFilteringEnabled(true)
+	l.Filter = keyFilter(opts.MatchMode)
 
 	ta := textarea.New()
 	ta.Placeholder = "Edit mode..."
@@ -35,22 +39,73 @@ func NewModel(store Store, dbPath string) Model {
 	pi.CharLimit = 256
 	pi.Prompt = "Pattern: "
 
+	ti := textinput.New()
+	ti.Placeholder = "2h · +30m · clear"
+	ti.CharLimit = 64
+	ti.Prompt = "TTL: "
+
+	mi := textinput.New()
+	mi.Placeholder = "0x01"
+	mi.CharLimit = 8
+	mi.Prompt = "Meta: "
+
+	si := textinput.New()
+	si.Placeholder = `user:zzz · 0x6b6579`
+	si.CharLimit = 1024
+	si.Prompt = "Go to: "
+
+	fi := textinput.New()
+	fi.Placeholder = "alice@example.com"
+	fi.CharLimit = 1024
+	fi.Prompt = "Find: "
+
+	fp := textinput.New()
+	fp.Placeholder = "all keys"
+	fp.CharLimit = 1024
+	fp.Prompt = "in prefix: "
+
+	ni := textinput.New()
+	ni.Placeholder = `user:42 · bin\x00\x01 · 0x6b6579`
+	ni.CharLimit = 1024
+	ni.Prompt = "Key: "
+
+	ii, it := newImportInputs()
+
+	if opts.Delimiters == "" {
+		opts.Delimiters = DefaultDelimiters
+	}
+
 	return Model{
-		store:        store,
-		list:         l,
-		status:       "↑/↓: list · Enter: load & focus value · Esc/Shift+←: back · t/h/b/j: format · /: filter · e: edit · d/Delete: delete · p: delete pattern · g: groups · F1: about · q: exit",
-		valFormat:    fmtJSON,
-		editor:       ta,
-		dbPath:       dbPath,
-		patternInput: pi,
-		pageSize:     defaultPageSize,
-		hasMoreKeys:  true,
-		loadingKeys:  true,
+		store:             store,
+		list:              l,
+		status:            "↑/↓: list · Enter: load & focus value · Esc/Shift+←: back · t/h/b/j: format · /: filter · e: edit · v: history · i: inspect · m: meta filter · s: go to key · f: search values · R: reverse · n: new key · d/Delete: delete · p: delete pattern · Tab: tree · Ctrl+R: match mode · x: key display · g: groups · J: jobs · u: undo · T: trash · E: export · I: import · B: backups · P: pin snapshot · C: change feed · F1: about · q: exit",
+		valFormat:         fmtJSON,
+		editor:            ta,
+		dbPath:            dbPath,
+		readOnly:          store.ReadOnly(),
+		patternInput:      pi,
+		newKeyInput:       ni,
+		seekInput:         si,
+		searchInput:       fi,
+		searchPrefixInput: fp,
+		ttlInput:          ti,
+		metaInput:         mi,
+		export:            exportState{input: newExportInput()},
+		imp:               importState{input: ii, templateInput: it},
+		backups:           backupsState{input: newBackupInput()},
+		feed:              feedState{input: newFeedInput()},
+		backupCatalog:     opts.BackupCatalog,
+		pageSize:          defaultPageSize,
+		hasMoreKeys:       true,
+		loadingKeys:       true,
+		tree:              treeState{delims: opts.Delimiters},
+		matchMode:         opts.MatchMode,
+		patternMode:       defaultPatternMode,
 	}
 }
 
 func (m Model) Init() tea.Cmd {
-	return loadKeysCmd(m.store, "", m.pageSize)
+	return loadKeysCmd(m.store, m.keyQuery, "", m.pageSize)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -85,22 +140,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		// I handle pattern delete confirmation.
+		// I handle the pattern delete preview and its confirmation.
 		if m.confirmPatternDelete {
-			switch msg.String() {
-			case "y", "Y", "enter":
-				pattern := m.pendingPattern
-				m.confirmPatternDelete = false
-				m.pendingPattern = ""
-				m.status = "Deleting by pattern..."
-				return m, deletePatternCmd(m.store, pattern)
-			case "n", "N", "esc":
-				m.confirmPatternDelete = false
-				m.pendingPattern = ""
-				m.status = "Pattern delete canceled."
-				return m, nil
-			}
-			return m, nil
+			return m.updateDeletePreview(msg)
+		}
+
+		// I handle the TTL prompt opened from edit mode.
+		if m.ttlPrompt {
+			return m.updateTTLPrompt(msg)
+		}
+
+		// I handle the UserMeta prompt (list filter or edit).
+		if m.metaPrompt {
+			return m.updateMetaPrompt(msg)
 		}
 
 		// I handle edit mode.
@@ -121,7 +173,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 				m.status = "Saving..."
-				return m, saveValueCmd(m.store, m.editKey, bytes)
+				return m, saveValueCmd(m.store, m.editKey, bytes, m.saveOptions())
+			case "ctrl+t":
+				m.editor.Blur()
+				return m.openTTLPrompt()
+			case "ctrl+o":
+				m.editor.Blur()
+				return m.openMetaPrompt(true)
 			}
 			// I pass through other editor keys.
 			var ecmd tea.Cmd
@@ -145,15 +203,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.status = "Pattern delete canceled."
 					return m, nil
 				}
-				if _, err := matchPattern(pattern, ""); err != nil {
+				mt, err := filterMatcher(m.patternMode, pattern)
+				if err != nil {
 					m.status = errStyle.Render(fmt.Sprintf("Error: invalid pattern: %v", err))
 					return m, nil
 				}
 				m.patternDelete = false
 				m.patternInput.Blur()
-				m.confirmPatternDelete = true
-				m.pendingPattern = pattern
-				m.status = fmt.Sprintf("Delete pattern '%s'? (y/n)", pattern)
+				return m.startDeletePreview(mt)
+			case "ctrl+r":
+				m.patternMode = nextPatternMode(m.patternMode)
 				return m, nil
 			}
 			var pcmd tea.Cmd
@@ -161,8 +220,86 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, pcmd
 		}
 
+		if m.seekPrompt {
+			return m.updateSeekPrompt(msg)
+		}
+
+		if m.export.prompt {
+			return m.updateExportPrompt(msg)
+		}
+
+		if m.imp.prompt {
+			return m.updateImportPrompt(msg)
+		}
+
+		if m.imp.confirm {
+			return m.updateImportConfirm(msg)
+		}
+
+		if m.feed.prompt {
+			return m.updateFeedPrompt(msg)
+		}
+
+		if m.search.prompt {
+			return m.updateSearchPrompt(msg)
+		}
+
+		// I handle new key input.
+		if m.newKey {
+			switch msg.String() {
+			case "esc":
+				m.newKey = false
+				m.newKeyInput.Blur()
+				m.status = "New key canceled."
+				return m, nil
+			case "enter":
+				return m.startNewKey()
+			}
+			var ncmd tea.Cmd
+			m.newKeyInput, ncmd = m.newKeyInput.Update(msg)
+			return m, ncmd
+		}
+
+		// I route keys to the statistics dashboard while it is open.
+		if m.stats.active {
+			return m.updateStatsKeys(msg)
+		}
+
+		// I route keys to the backups panel while it is open.
+		if m.backups.active {
+			return m.updateBackupsKeys(msg)
+		}
+
+		// I route keys to the trash while it is open.
+		if m.trash.active {
+			return m.updateTrashKeys(msg)
+		}
+
+		// I route keys to the job panel while it is open.
+		if m.jobs.show {
+			return m.updateJobsKeys(msg)
+		}
+
+		// I route keys to the version browser while it is open.
+		if m.history.active {
+			return m.updateHistoryKeys(msg)
+		}
+
+		// I route keys to the value search results while they replace the list.
+		if m.search.active && !m.focusRight {
+			return m.updateSearchKeys(msg)
+		}
+
+		// I route keys to the prefix tree while it replaces the list.
+		if m.tree.active && !m.focusRight {
+			return m.updateTreeKeys(msg)
+		}
+
 		// I disable global shortcuts while filtering.
 		if m.list.SettingFilter() {
+			if msg.String() == "ctrl+r" {
+				return m.cycleMatchMode()
+			}
 			var cmd tea.Cmd
 			m.list, cmd = m.list.Update(msg)
 			maybeFilter, filterCmd := m.maybeStartFilterWork()
@@ -175,8 +312,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "esc", "shift+left":
 				m.focusRight = false
 				m.status = "List focused."
+				if m.tree.active {
+					m.status = "Tree focused."
+				}
+				if m.search.active {
+					m.status = "Search results focused."
+				}
 				return m, nil
 			case "p":
+				if m.denyReadOnly("pattern delete") {
+					return m, nil
+				}
 				m.patternDelete = true
 				m.patternInput.SetValue("")
 				m.patternInput.Focus()
@@ -195,13 +341,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.valFormat = fmtJSON
 				return m.reloadSelected()
 			case "e":
+				if m.denyReadOnly("edit") {
+					return m, nil
+				}
 				if m.selected != "" {
 					m.editKey = m.selected
 					m.status = "Loading..."
 					return m, loadValueCmd(m.store, m.selected)
 				}
+			case "n":
+				return m.openNewKeyInput()
+			case "v":
+				return m.openHistory(m.selected)
+			case "i":
+				return m.toggleInspector()
+			case "m":
+				return m.openMetaPrompt(false)
+			case "x":
+				return m.cycleKeyDisplay(), nil
 			case "g", "G", "ctrl+g":
 				return m.toggleGroupCounts()
+			case "ctrl+r":
+				return m.cycleMatchMode()
+			case "s":
+				return m.openSeekPrompt()
+			case "f":
+				return m.openSearchPrompt()
+			case "R":
+				return m.toggleReverse()
+			case "J":
+				return m.toggleJobs()
+			case "T":
+				return m.toggleTrash()
+			case "E":
+				return m.openExportPrompt()
+			case "I":
+				return m.openImportPrompt()
+			case "B":
+				return m.toggleBackups()
+			case "P":
+				return m.togglePin()
+			case "f5":
+				return m.repin()
+			case "C":
+				return m.toggleFeed()
+			case "S":
+				return m.toggleStats()
+			case "u":
+				return m.undo()
 			}
 
 			var vcmd tea.Cmd
@@ -240,15 +427,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "d", "delete":
+			if m.denyReadOnly("delete") {
+				return m, nil
+			}
 			i, ok := m.list.SelectedItem().(kvItem)
 			if ok {
 				m.confirmDelete = true
 				m.pendingDelete = i.key
-				m.status = fmt.Sprintf("Delete '%s'? (y/n)", i.key)
+				m.status = fmt.Sprintf("Delete '%s'? (y/n)", m.showKey(i.key))
 			}
 			return m, nil
 
 		case "p":
+			if m.denyReadOnly("pattern delete") {
+				return m, nil
+			}
 			m.patternDelete = true
 			m.patternInput.SetValue("")
 			m.patternInput.Focus()
@@ -257,6 +450,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "e":
 			// I enter edit mode.
+			if m.denyReadOnly("edit") {
+				return m, nil
+			}
 			i, ok := m.list.SelectedItem().(kvItem)
 			if ok {
 				m.selected = i.key
@@ -266,8 +462,53 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// I load the value via loadValueCmd.
 				return m, loadValueCmd(m.store, i.key)
 			}
+		case "n":
+			return m.openNewKeyInput()
+		case "v":
+			if i, ok := m.list.SelectedItem().(kvItem); ok {
+				m.selected = i.key
+				return m.openHistory(i.key)
+			}
+		case "m":
+			return m.openMetaPrompt(false)
+		case "i":
+			return m.toggleInspector()
+		case "x":
+			return m.cycleKeyDisplay(), nil
 		case "g", "G", "ctrl+g":
 			return m.toggleGroupCounts()
+		case "ctrl+r":
+			return m.cycleMatchMode()
+		case "s":
+			return m.openSeekPrompt()
+		case "f":
+			return m.openSearchPrompt()
+		case "R":
+			return m.toggleReverse()
+		case "J":
+			return m.toggleJobs()
+		case "T":
+			return m.toggleTrash()
+		case "E":
+			return m.openExportPrompt()
+		case "I":
+			return m.openImportPrompt()
+		case "B":
+			return m.toggleBackups()
+		case "P":
+			return m.togglePin()
+		case "f5":
+			return m.repin()
+		case "C":
+			return m.toggleFeed()
+		case "S":
+			return m.toggleStats()
+		case "u":
+			return m.undo()
+		case "tab":
+			return m.openTree()
+		case "backspace":
+			return m.widenPrefix()
 		}
 
 	case tea.WindowSizeMsg:
@@ -283,18 +524,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			Width:  lay.rightContentWidth,
 			Height: lay.rightContentHeight,
 		}
+		m.resizeViewport()
 		m.updateEditorLayout(lay)
 		_, moreCmd := m.maybeLoadMore()
 		maybeFilter, filterCmd := m.maybeStartFilterWork()
 		return maybeFilter, tea.Batch(moreCmd, filterCmd)
 
 	case loadKeysMsg:
+		if msg.query != m.keyQuery || m.filter.active || msg.startAfter != m.pageEdge(msg.before) {
+			// I drop pages requested before the query changed or the list was reset.
+			return m, nil
+		}
 		m.loadingKeys = false
 		if msg.err != nil {
 			m.status = errStyle.Render(fmt.Sprintf("Error: failed to load keys: %v", msg.err))
 			m.hasMoreKeys = false
 			return m, nil
 		}
+		if msg.before {
+			return m.prependKeys(msg)
+		}
 		if len(msg.keys) == 0 {
 			m.hasMoreKeys = msg.hasMore
 			return m, nil
@@ -310,11 +559,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		maybeFilter, filterCmd := m.maybeStartFilterWork()
 		return maybeFilter, tea.Batch(cmd, moreCmd, filterCmd)
 
+	case filterKeysMsg:
+		return m.handleFilterKeys(msg)
+
 	case filterCountMsg:
-		if msg.term != strings.TrimSpace(m.list.FilterValue()) {
+		m = m.endJob(msg.job, msg.err)
+		if !m.filter.active || msg.gen != m.filter.gen {
 			return m, nil
 		}
 		m.filterCountLoading = false
+		if errors.Is(msg.err, context.Canceled) {
+			m.filterCountValid = false
+			return m, nil
+		}
 		if msg.err != nil {
 			m.filterCountErr = msg.err.Error()
 			m.filterCountValid = false
@@ -326,6 +583,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case groupCountsMsg:
+		m = m.endJob(msg.job, msg.err)
+		if msg.job != m.groupCountsJob || !m.groupCountsLoading {
+			// A canceled or replaced scan.
+			return m, nil
+		}
 		m.groupCountsLoading = false
 		if msg.err != nil {
 			m.groupCountsErr = msg.err.Error()
@@ -333,6 +595,40 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.groupCountsErr = ""
 		m.groupCounts = msg.counts
+		m.groupCountsTook = msg.took
+		return m, nil
+
+	case historyMsg:
+		if !m.history.active || msg.key != m.history.key {
+			return m, nil
+		}
+		m.history.loading = false
+		if msg.err != nil {
+			m.history.err = msg.err.Error()
+			return m, nil
+		}
+		m.history.err = ""
+		m.history.versions = msg.versions
+		m.history.cursor = clamp(m.history.cursor, 0, max(0, len(msg.versions)-1))
+		return m, nil
+
+	case historyValueMsg:
+		if !m.history.active || msg.key != m.history.key {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Error: failed to load version: %v", msg.err))
+			return m, nil
+		}
+		if msg.diff {
+			m.history.mode = historyDiff
+			diff := diffLines(strings.Split(m.plainValue(msg.current), "\n"), strings.Split(m.plainValue(msg.value), "\n"))
+			m.viewport.SetContent(renderDiff(diff))
+		} else {
+			m.history.mode = historyValue
+			m.viewport.SetContent(m.formatValue(msg.key, msg.value))
+		}
+		m.viewport.GotoTop()
 		return m, nil
 
 	case loadValueMsg:
@@ -355,23 +651,59 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// I start edit mode only when load was triggered by 'e' (editKey set).
 		if m.editKey == msg.key && !m.editing {
 			// I start edit mode.
+			m.editExpiresAt = msg.expiresAt
+			m.editOrigMeta = msg.userMeta
 			m.startEditWithContent(msg.key, msg.value)
 			m.updateEditorLayout(computeLayout(m.width, m.height))
 
 			// I return focus to the editor to activate editing.
 			cmd = m.editor.Focus()
 
-			return m, cmd // I return the focus command.
+			tick, tickCmd := m.maybeStartTTLTick()
+			return tick, tea.Batch(cmd, tickCmd) // I return the focus command.
 		}
 
 		// I handle normal loads (Enter or format change).
+		m.selectedExpiresAt = msg.expiresAt
+		m.selectedMeta = msg.userMeta
 		m.viewport.SetContent(m.formatValue(msg.key, msg.value))
-		m.viewport.GotoTop()
+		if !msg.refresh {
+			m.viewport.GotoTop()
+		}
+		tick, tickCmd := m.maybeStartTTLTick()
+		if tick.showInspector {
+			return tick, tea.Batch(tickCmd, inspectCmd(tick.store, msg.key))
+		}
+		return tick, tickCmd
+
+	case searchChunkMsg:
+		return m.handleSearchChunk(msg)
+
+	case seekKeysMsg:
+		return m.handleSeekKeys(msg)
+
+	case treeChildrenMsg:
+		return m.handleTreeChildren(msg), nil
+
+	case inspectMsg:
+		if msg.key != m.selected {
+			return m, nil
+		}
+		m.inspectKey = msg.key
+		m.inspectInfo = msg.info
+		m.inspectErr = ""
+		if msg.err != nil {
+			m.inspectErr = msg.err.Error()
+		}
 		return m, nil
 
+	case ttlTickMsg:
+		m.ttlTicking = false
+		return m.maybeStartTTLTick()
+
 	case deleteResultMsg:
-		if msg.err != nil {
-			m.status = fmt.Sprintf("Error: delete failed: %v", msg.err)
+		if msg.err != nil && !notLogged(msg.err) {
+			m.status = errStyle.Render(fmt.Sprintf("Error: delete failed: %v", msg.err))
 			return m, nil
 		}
 		// I remove the selected item from the list.
@@ -381,54 +713,81 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		// I clear the right panel and selection.
 		m.selected = ""
+		m.selectedExpiresAt = 0
 		m.viewport.SetContent("")
-		m.status = okStyle.Render(fmt.Sprintf("'%s' deleted.", msg.key))
+		m.status = okStyle.Render(fmt.Sprintf("'%s' deleted.", m.showKey(msg.key)))
+		if msg.err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Warning: '%s' deleted, but %v", m.showKey(msg.key), msg.err))
+		}
 		return m, nil
 
+	case trashMsg:
+		return m.handleTrash(msg)
+
+	case restoreMsg:
+		return m.handleRestore(msg)
+
+	case jobTickMsg:
+		return m.handleJobTick()
+
+	case deletePlanMsg:
+		return m.handleDeletePlan(msg)
+
 	case deletePatternResultMsg:
-		if msg.err != nil {
-			m.status = errStyle.Render(fmt.Sprintf("Error: pattern delete failed: %v", msg.err))
-			return m, nil
-		}
-		if len(msg.keys) == 0 {
-			m.status = errStyle.Render(fmt.Sprintf("Warning: no matches for pattern: %s", msg.pattern))
-			return m, nil
-		}
+		return m.handleDeletePatternResult(msg)
 
-		remove := make(map[string]struct{}, len(msg.keys))
-		for _, k := range msg.keys {
-			remove[k] = struct{}{}
-		}
+	case exportResultMsg:
+		return m.handleExportResult(msg)
 
-		items := m.list.Items()
-		newItems := make([]list.Item, 0, len(items))
-		for _, it := range items {
-			ki, ok := it.(kvItem)
-			if ok {
-				if _, exists := remove[ki.key]; exists {
-					continue
-				}
-			}
-			newItems = append(newItems, it)
-		}
-		cmd := m.list.SetItems(newItems)
-		if _, deleted := remove[m.selected]; deleted {
-			m.selected = ""
-			m.viewport.SetContent("")
-		}
-		m.status = okStyle.Render(fmt.Sprintf("Deleted %d records (pattern: %s).", len(msg.keys), msg.pattern))
-		return m, cmd
+	case importResultMsg:
+		return m.handleImportResult(msg)
+
+	case backupsMsg:
+		return m.handleBackups(msg)
+
+	case backupDoneMsg:
+		return m.handleBackupDone(msg)
+
+	case verifyBackupMsg:
+		return m.handleVerifyBackup(msg)
+
+	case restoreBackupMsg:
+		return m.handleRestoreBackup(msg)
+
+	case feedMsg:
+		return m.handleFeed(msg)
+
+	case statsMsg:
+		return m.handleStats(msg)
+
+	case feedEndedMsg:
+		return m.handleFeedEnded(msg)
 
 	case saveResultMsg:
-		if msg.err != nil {
+		if msg.err != nil && !notLogged(msg.err) {
 			m.status = errStyle.Render(fmt.Sprintf("Error: save failed: %v", msg.err))
 			return m, nil
 		}
 		m.editing = false
 		m.editKey = "" // I clear editKey after a successful save.
 		m.focusRight = true
-		m.status = okStyle.Render(fmt.Sprintf("'%s' updated.", msg.key))
+		m.status = okStyle.Render(fmt.Sprintf("'%s' updated.", m.showKey(msg.key)))
+		if msg.version != 0 {
+			m.status = okStyle.Render(fmt.Sprintf("'%s' restored from version %d.", m.showKey(msg.key), msg.version))
+		}
+		if msg.err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Warning: '%s' saved, but %v", m.showKey(msg.key), msg.err))
+		}
+		if m.pinnedTs != 0 {
+			m.status += " The pinned snapshot shows the old value until F5."
+		}
 		m.updateEditorLayout(computeLayout(m.width, m.height))
+		m.insertKey(msg.key)
+		if m.history.active && m.history.key == msg.key {
+			m.history.mode = historyList
+			m.history.cursor = 0
+			return m, loadHistoryCmd(m.store, msg.key)
+		}
 		// I reload the right panel.
 		return m, loadValueCmd(m.store, msg.key)
 	}
@@ -442,6 +801,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 	m.list, cmd = m.list.Update(msg)
+	if _, ok := msg.(list.FilterMatchesMsg); ok {
+		// The list does not repaginate when matches come in, which would
+		// leave pages of a growing filtered list out of reach.
+		m.list.SetSize(m.list.Width(), m.list.Height())
+	}
 	maybe, moreCmd := m.maybeLoadMore()
 	maybeFilter, filterCmd := maybe.maybeStartFilterWork()
 	if !maybeFilter.focusRight && !maybeFilter.editing && !maybeFilter.list.SettingFilter() {
@@ -457,7 +821,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) maybeLoadMore() (Model, tea.Cmd) {
-	if !m.hasMoreKeys || m.loadingKeys {
+	if m.filter.active {
+		return m.maybeLoadMoreMatches()
+	}
+	if !(m.hasMoreKeys || m.hasLessKeys) || m.loadingKeys {
 		return m, nil
 	}
 	if m.list.IsFiltered() || m.list.SettingFilter() || m.list.FilterState() != list.Unfiltered {
@@ -468,66 +835,162 @@ func (m Model) maybeLoadMore() (Model, tea.Cmd) {
 		return m, nil
 	}
 	threshold := 5
-	if m.list.Index() >= len(items)-1-threshold {
+	if m.hasLessKeys && m.list.Index() <= threshold {
+		m.loadingKeys = true
+		return m, loadKeysBeforeCmd(m.store, m.keyQuery, m.firstKey, m.pageSize)
+	}
+	if m.hasMoreKeys && m.list.Index() >= len(items)-1-threshold {
 		m.loadingKeys = true
-		return m, loadKeysCmd(m.store, m.lastKey, m.pageSize)
+		return m, loadKeysCmd(m.store, m.keyQuery, m.lastKey, m.pageSize)
 	}
 	return m, nil
 }
 
-func (m Model) maybeStartFilterWork() (Model, tea.Cmd) {
-	var cmds []tea.Cmd
-	state := m.list.FilterState()
-	if state == list.Unfiltered {
-		m.filterCountLoading = false
-		m.filterCountErr = ""
-		m.filterTerm = ""
-		m.filterCount = 0
-		m.filterCountValid = false
-		m.loadingAllForFilter = false
+func (m Model) toggleGroupCounts() (Model, tea.Cmd) {
+	m.showGroupCounts = !m.showGroupCounts
+	if !m.showGroupCounts {
+		// Nobody is looking at the counts any more.
+		if m.groupCountsLoading {
+			m = m.cancelJobs(jobGroups)
+			m.groupCountsLoading = false
+			m.groupCountsErr = "canceled"
+		}
 		return m, nil
 	}
-	term := strings.TrimSpace(m.list.FilterValue())
-	if term == "" {
-		m.filterCountLoading = false
-		m.filterCountErr = ""
-		m.filterTerm = ""
-		m.filterCount = 0
-		m.filterCountValid = false
-		m.loadingAllForFilter = false
+	if m.groupCountsLoading {
 		return m, nil
 	}
-	if state == list.FilterApplied {
-		if term == m.filterTerm && m.filterCountValid && !m.filterCountLoading {
-			// I keep the current count.
-		} else if !m.filterCountLoading || term != m.filterTerm {
-			m.filterTerm = term
-			m.filterCountLoading = true
-			m.filterCountErr = ""
-			m.filterCountValid = false
-			cmds = append(cmds, countFilterCmd(m.store, term))
-		}
-	}
-	m.loadingAllForFilter = true
-	if m.hasMoreKeys && !m.loadingKeys {
-		m.loadingKeys = true
-		cmds = append(cmds, loadKeysCmd(m.store, m.lastKey, m.pageSize))
+	m.groupCountsLoading = true
+	m.groupCountsErr = ""
+	m, j, tick := m.startJob(jobGroups, "Group counts")
+	m.groupCountsJob = j.id
+	return m, tea.Batch(tick, loadGroupCountsCmd(m.store, j))
+}
+
+// I refuse mutations up front when the DB is opened read-only.
+func (m *Model) denyReadOnly(action string) bool {
+	if !m.readOnly {
+		return false
 	}
-	if len(cmds) == 0 {
+	m.status = errStyle.Render(fmt.Sprintf("Read-only: %s is disabled (restart with --write).", action))
+	return true
+}
+
+func (m Model) showKey(key string) string {
+	return displayKey(key, m.keyDisplay)
+}
+
+func (m Model) cycleKeyDisplay() Model {
+	m.keyDisplay = m.keyDisplay.next()
+	m.list.SetDelegate(thinCursorDelegate{display: m.keyDisplay})
+	m.status = fmt.Sprintf("Key display: %s", m.keyDisplay.name())
+	return m
+}
+
+func (m Model) openNewKeyInput() (Model, tea.Cmd) {
+	if m.denyReadOnly("new key") {
 		return m, nil
 	}
-	return m, tea.Batch(cmds...)
+	m.newKey = true
+	m.newKeyInput.SetValue("")
+	m.status = "New key. (Enter edit value · Esc cancel)"
+	return m, m.newKeyInput.Focus()
 }
 
-func (m Model) toggleGroupCounts() (Model, tea.Cmd) {
-	m.showGroupCounts = !m.showGroupCounts
-	if !m.showGroupCounts {
+func (m Model) startNewKey() (Model, tea.Cmd) {
+	text := m.newKeyInput.Value()
+	if strings.TrimSpace(text) == "" {
+		m.newKey = false
+		m.newKeyInput.Blur()
+		m.status = "New key canceled."
 		return m, nil
 	}
-	if m.groupCountsLoading {
+	key, err := parseKeyInput(text)
+	if err != nil {
+		m.status = errStyle.Render(fmt.Sprintf("Error: invalid key: %v", err))
 		return m, nil
 	}
-	m.groupCountsLoading = true
-	m.groupCountsErr = ""
-	return m, loadGroupCountsCmd(m.store)
+	// I ask the store rather than the list, which only holds the loaded
+	// page: a new key's save would overwrite a stored value with its TTL
+	// and meta.
+	_, err = m.store.GetEntry(key)
+	if err != nil && !keyNotFound(err) {
+		m.status = errStyle.Render(fmt.Sprintf("Error: cannot check key '%s': %v", m.showKey(key), err))
+		return m, nil
+	}
+	m.newKey = false
+	m.newKeyInput.Blur()
+	m.selected = key
+	m.focusRight = true
+	if err == nil {
+		m.editKey = key
+		m.status = "Key exists; loading it for edit..."
+		return m, loadValueCmd(m.store, key)
+	}
+	m.viewport.SetContent("")
+	m.editExpiresAt = 0
+	m.editOrigMeta = 0
+	m.startEditWithContent(key, nil)
+	m.updateEditorLayout(computeLayout(m.width, m.height))
+	return m, m.editor.Focus()
+}
+
+// I place a saved key into the loaded range so new keys show up without a reload.
+func (m *Model) insertKey(key string) {
+	if m.hasMoreKeys && m.keyBefore(m.lastKey, key) || m.hasLessKeys && m.keyBefore(key, m.firstKey) {
+		return
+	}
+	// I cannot tell whether the key matches the meta filter without loading it.
+	if m.keyQuery.HasMeta || !strings.HasPrefix(key, m.keyQuery.Prefix) || !m.filterAccepts(key) {
+		return
+	}
+	items := m.list.Items()
+	idx := sort.Search(len(items), func(i int) bool {
+		ki, _ := items[i].(kvItem)
+		return !m.keyBefore(ki.key, key)
+	})
+	if idx < len(items) {
+		if ki, _ := items[idx].(kvItem); ki.key == key {
+			return
+		}
+	}
+	m.list.InsertItem(idx, kvItem{key: key})
+}
+
+// removeKey drops a key that no longer exists from the list.
+func (m *Model) removeKey(key string) {
+	for i, it := range m.list.Items() {
+		if ki, _ := it.(kvItem); ki.key == key {
+			m.list.RemoveItem(i)
+			return
+		}
+	}
+}
+
+// pageEdge is the key the next page in that direction continues from.
+func (m Model) pageEdge(before bool) string {
+	if before {
+		return m.firstKey
+	}
+	return m.lastKey
+}
+
+// prependKeys puts a backward page above the loaded keys and keeps the
+// cursor on the same key.
+func (m Model) prependKeys(msg loadKeysMsg) (Model, tea.Cmd) {
+	m.hasLessKeys = msg.hasMore
+	if len(msg.keys) == 0 {
+		return m, nil
+	}
+	old := m.list.Items()
+	items := make([]list.Item, 0, len(msg.keys)+len(old))
+	for i := len(msg.keys) - 1; i >= 0; i-- {
+		items = append(items, kvItem{key: msg.keys[i]})
+	}
+	items = append(items, old...)
+	idx := m.list.Index()
+	cmd := m.list.SetItems(items)
+	m.list.Select(idx + len(msg.keys))
+	m.firstKey = msg.lastKey
+	return m, cmd
 }
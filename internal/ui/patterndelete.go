@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+// deletePreviewSample is how many matching keys the preview lists.
+const deletePreviewSample = 10
+
+// A pattern delete is previewed first: the keys it matches are counted and
+// the first few listed before y/n is taken.
+type deletePreview struct {
+	job     int
+	loading bool
+	matcher store.Matcher
+	plan    store.DeletePlan
+	err     string
+}
+
+type deletePlanMsg struct {
+	job  int
+	plan store.DeletePlan
+	err  error
+}
+
+func planDeleteCmd(s Store, j job, mt store.Matcher) tea.Cmd {
+	return func() tea.Msg {
+		plan, err := s.PlanDelete(j.ctx, mt, deletePreviewSample, j.progress)
+		return deletePlanMsg{job: j.id, plan: plan, err: err}
+	}
+}
+
+func deletePatternCmd(s Store, j job, mt store.Matcher) tea.Cmd {
+	return func() tea.Msg {
+		sum := s.DeleteMatching(j.ctx, mt, j.progress)
+		return deletePatternResultMsg{job: j.id, matcher: mt, summary: sum}
+	}
+}
+
+func (m Model) startDeletePreview(mt store.Matcher) (Model, tea.Cmd) {
+	m.confirmPatternDelete = true
+	m, j, tick := m.startJob(jobPreview, "Preview delete "+m.describeMatcher(mt))
+	m.preview = deletePreview{job: j.id, loading: true, matcher: mt}
+	m.status = fmt.Sprintf("Finding keys matching %s… (Esc cancel)", m.describeMatcher(mt))
+	return m, tea.Batch(tick, planDeleteCmd(m.store, j, mt))
+}
+
+func (m Model) handleDeletePlan(msg deletePlanMsg) (Model, tea.Cmd) {
+	m = m.endJob(msg.job, msg.err)
+	if !m.confirmPatternDelete || msg.job != m.preview.job || !m.preview.loading {
+		return m, nil
+	}
+	m.preview.loading = false
+	if msg.err != nil {
+		m.preview.err = msg.err.Error()
+		m.status = errStyle.Render(fmt.Sprintf("Error: preview failed: %v", msg.err))
+		return m, nil
+	}
+	m.preview.plan = msg.plan
+	if msg.plan.Total == 0 {
+		m.status = errStyle.Render(fmt.Sprintf("Warning: no keys match %s (Esc close)", m.describeMatcher(m.preview.matcher)))
+		return m, nil
+	}
+	m.status = fmt.Sprintf("Delete %s keys matching %s? (y/n)", humanize.Comma(int64(msg.plan.Total)), m.describeMatcher(m.preview.matcher))
+	return m, nil
+}
+
+func (m Model) updateDeletePreview(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "enter":
+		if m.preview.loading || m.preview.err != "" || m.preview.plan.Total == 0 {
+			return m, nil
+		}
+		mt, total := m.preview.matcher, m.preview.plan.Total
+		m.confirmPatternDelete = false
+		m.preview = deletePreview{}
+		if _, busy := m.runningJob(jobDelete); busy {
+			m.status = errStyle.Render("Error: a pattern delete is already running (J shows jobs).")
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Deleting keys matching %s…", m.describeMatcher(mt))
+		m, j, tick := m.startJob(jobDelete, "Delete "+m.describeMatcher(mt))
+		m = m.setJobTotal(j.id, total)
+		return m, tea.Batch(tick, deletePatternCmd(m.store, j, mt))
+	case "n", "N", "esc":
+		if m.preview.loading {
+			m = m.cancelJob(m.preview.job, "canceled")
+		}
+		m.confirmPatternDelete = false
+		m.preview = deletePreview{}
+		m.status = "Pattern delete canceled."
+	}
+	return m, nil
+}
+
+func (m Model) handleDeletePatternResult(msg deletePatternResultMsg) (Model, tea.Cmd) {
+	sum := msg.summary
+	m = m.endJob(msg.job, sum.Err)
+	remove := make(map[string]struct{}, len(sum.Keys))
+	for _, k := range sum.Keys {
+		remove[k] = struct{}{}
+	}
+	gone := func(k string) bool {
+		if sum.Dropped {
+			return strings.HasPrefix(k, sum.Prefix)
+		}
+		_, ok := remove[k]
+		return ok
+	}
+	var cmd tea.Cmd
+	if sum.Deleted > 0 {
+		items := m.list.Items()
+		kept := make([]list.Item, 0, len(items))
+		for _, it := range items {
+			if ki, ok := it.(kvItem); ok && gone(ki.key) {
+				continue
+			}
+			kept = append(kept, it)
+		}
+		cmd = m.list.SetItems(kept)
+		if m.selected != "" && gone(m.selected) {
+			m.selected = ""
+			m.viewport.SetContent("")
+		}
+	}
+
+	what := m.describeMatcher(msg.matcher)
+	if sum.Dropped {
+		what += fmt.Sprintf(", dropped prefix '%s'", m.showKey(sum.Prefix))
+	}
+	switch {
+	case errors.Is(sum.Err, context.Canceled):
+		m.status = fmt.Sprintf("Pattern delete canceled after %s records (%s).", humanize.Comma(int64(sum.Deleted)), what)
+	case notLogged(sum.Err) && sum.Failed == 0:
+		m.status = errStyle.Render(fmt.Sprintf("Warning: deleted %s records (%s), but %v", humanize.Comma(int64(sum.Deleted)), what, sum.Err))
+	case sum.Err != nil && sum.Deleted == 0:
+		m.status = errStyle.Render(fmt.Sprintf("Error: pattern delete failed: %v", sum.Err))
+	case sum.Err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: deleted %s records but %s failed (%s): %v",
+			humanize.Comma(int64(sum.Deleted)), humanize.Comma(int64(sum.Failed)), what, sum.Err))
+	case sum.Deleted == 0:
+		m.status = errStyle.Render(fmt.Sprintf("Warning: no keys match %s", what))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("Deleted %s records (%s).", humanize.Comma(int64(sum.Deleted)), what))
+	}
+	return m, cmd
+}
+
+func (m Model) deletePreviewView(width int) string {
+	p := m.preview
+	lines := []string{"Delete keys matching " + m.describeMatcher(p.matcher)}
+	switch {
+	case p.loading:
+		scanned := int64(0)
+		if j, ok := m.jobByID(p.job); ok {
+			scanned = j.progress.Scanned()
+		}
+		lines = append(lines, fmt.Sprintf("Scanning… %s keys read (Esc cancel)", humanize.Comma(scanned)))
+	case p.err != "":
+		lines = append(lines, errStyle.Render("Error: "+p.err))
+	case p.plan.Total == 0:
+		lines = append(lines, "No keys match.")
+	default:
+		how := fmt.Sprintf("in batches of up to %d keys", store.DeleteChunk)
+		if p.plan.Drop {
+			// DropPrefix removes every version, which a tombstone would not.
+			how = fmt.Sprintf("by dropping prefix '%s' (all versions)", m.showKey(p.plan.Prefix))
+		}
+		lines = append(lines, fmt.Sprintf("%s keys match; they will be deleted %s.", humanize.Comma(int64(p.plan.Total)), how))
+		for _, k := range p.plan.Sample {
+			lines = append(lines, "  "+truncateString(m.showKey(k), width-6))
+		}
+		if more := p.plan.Total - len(p.plan.Sample); more > 0 {
+			lines = append(lines, inspectorStyle.Render(fmt.Sprintf("  … and %s more", humanize.Comma(int64(more)))))
+		}
+		lines = append(lines, "y delete · n/Esc cancel")
+	}
+	return paneStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
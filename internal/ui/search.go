@@ -0,0 +1,309 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// searchScanChunk is how many keys one search step reads before results are shown.
+	searchScanChunk = 2000
+	// searchMaxResults stops the scan so a broad term cannot fill memory.
+	searchMaxResults = 1000
+)
+
+// I keep the value search next to the key list, like the tree.
+type searchState struct {
+	active      bool
+	prompt      bool
+	focusPrefix bool
+	regex       bool
+	gen         int
+	job         int
+	term        string
+	prefix      string
+	results     []store.ValueMatch
+	cursor      int
+	scanning    bool
+	lastKey     string
+	capped      bool
+	err         string
+}
+
+type searchChunkMsg struct {
+	gen     int
+	matches []store.ValueMatch
+	lastKey string
+	done    bool
+	err     error
+}
+
+func searchChunkCmd(s Store, j job, gen int, vs store.ValueSearch, startAfter string) tea.Cmd {
+	return func() tea.Msg {
+		matches, lastKey, done, err := s.SearchValues(j.ctx, vs, startAfter, searchScanChunk, j.progress)
+		return searchChunkMsg{gen: gen, matches: matches, lastKey: lastKey, done: done, err: err}
+	}
+}
+
+func (m Model) openSearchPrompt() (Model, tea.Cmd) {
+	m.search.prompt = true
+	m.search.focusPrefix = false
+	m.searchInput.SetValue(m.search.term)
+	m.searchInput.CursorEnd()
+	prefix := m.search.prefix
+	if !m.search.active {
+		prefix = displayKey(m.keyQuery.Prefix, keyEscaped)
+	}
+	m.searchPrefixInput.SetValue(prefix)
+	m.searchPrefixInput.Blur()
+	m.status = "Search values. (Enter search · Tab prefix/term · Ctrl+R substring/regex · Esc cancel)"
+	return m, m.searchInput.Focus()
+}
+
+func (m Model) updateSearchPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.search.prompt = false
+		m.searchInput.Blur()
+		m.searchPrefixInput.Blur()
+		m.status = "Search canceled."
+		return m, nil
+	case "tab", "shift+tab":
+		m.search.focusPrefix = !m.search.focusPrefix
+		if m.search.focusPrefix {
+			m.searchInput.Blur()
+			return m, m.searchPrefixInput.Focus()
+		}
+		m.searchPrefixInput.Blur()
+		return m, m.searchInput.Focus()
+	case "ctrl+r":
+		m.search.regex = !m.search.regex
+		return m, nil
+	case "enter":
+		return m.startSearch()
+	}
+	var cmd tea.Cmd
+	if m.search.focusPrefix {
+		m.searchPrefixInput, cmd = m.searchPrefixInput.Update(msg)
+	} else {
+		m.searchInput, cmd = m.searchInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m Model) startSearch() (Model, tea.Cmd) {
+	term := m.searchInput.Value()
+	if term == "" {
+		m.status = errStyle.Render("Error: enter something to search for.")
+		return m, nil
+	}
+	prefix, err := parseKeyInput(m.searchPrefixInput.Value())
+	if err != nil {
+		m.status = errStyle.Render(fmt.Sprintf("Error: invalid prefix: %v", err))
+		return m, nil
+	}
+	vs := store.ValueSearch{Prefix: prefix, Substring: []byte(term)}
+	if m.search.regex {
+		re, err := regexp.Compile(term)
+		if err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Error: invalid regex: %v", err))
+			return m, nil
+		}
+		vs.Regexp = re
+	}
+	mode := "substring"
+	if m.search.regex {
+		mode = "regex"
+	}
+	m, j, tick := m.startJob(jobSearch, fmt.Sprintf("Search values %s '%s'", mode, term))
+	m.searchInput.Blur()
+	m.searchPrefixInput.Blur()
+	m = m.closeTree()
+	m.focusRight = false
+	m.search = searchState{
+		active:   true,
+		regex:    m.search.regex,
+		gen:      m.search.gen + 1,
+		job:      j.id,
+		term:     term,
+		prefix:   m.searchPrefixInput.Value(),
+		scanning: true,
+	}
+	m.searchQuery = vs
+	m.status = "Searching values… ↑/↓ select · Enter view · f new search · Esc stop/close"
+	return m, tea.Batch(tick, searchChunkCmd(m.store, j, m.search.gen, vs, ""))
+}
+
+func (m Model) handleSearchChunk(msg searchChunkMsg) (Model, tea.Cmd) {
+	if !m.search.active || msg.gen != m.search.gen || !m.search.scanning {
+		return m, nil
+	}
+	j, ok := m.jobByID(m.search.job)
+	if !ok || !j.running() {
+		m.search.scanning = false
+		return m, nil
+	}
+	if msg.err != nil {
+		m = m.endJob(j.id, msg.err)
+		m.search.scanning = false
+		m.search.err = msg.err.Error()
+		return m, nil
+	}
+	m.search.results = append(m.search.results, msg.matches...)
+	m.search.lastKey = msg.lastKey
+	if len(m.search.results) >= searchMaxResults {
+		m.search.results = m.search.results[:searchMaxResults]
+		m.search.capped = true
+		m.search.scanning = false
+		m = m.endJob(j.id, nil)
+		m.status = fmt.Sprintf("Stopped at %d matches; narrow the prefix or term.", searchMaxResults)
+		return m, nil
+	}
+	if msg.done {
+		m = m.endJob(j.id, nil)
+		m.search.scanning = false
+		m.status = fmt.Sprintf("Search done: %d matching keys.", len(m.search.results))
+		return m, nil
+	}
+	return m, searchChunkCmd(m.store, j, m.search.gen, m.searchQuery, msg.lastKey)
+}
+
+func (m Model) updateSearchKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	n := len(m.search.results)
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc":
+		if m.search.scanning {
+			m = m.cancelJob(m.search.job, "canceled")
+			m.search.scanning = false
+			m.status = fmt.Sprintf("Search stopped: %d matching keys so far.", n)
+			return m, nil
+		}
+		m.search = searchState{regex: m.search.regex, gen: m.search.gen, term: m.search.term, prefix: m.search.prefix}
+		m.status = "List focused."
+		return m, nil
+	case "up":
+		m.search.cursor = max(0, m.search.cursor-1)
+	case "down":
+		m.search.cursor = clamp(m.search.cursor+1, 0, max(0, n-1))
+	case "pgup":
+		m.search.cursor = max(0, m.search.cursor-10)
+	case "pgdown":
+		m.search.cursor = clamp(m.search.cursor+10, 0, max(0, n-1))
+	case "enter":
+		if m.search.cursor < n {
+			key := m.search.results[m.search.cursor].Key
+			m.selected = key
+			m.editKey = ""
+			m.focusRight = true
+			return m, loadValueCmd(m.store, key)
+		}
+	case "f":
+		return m.openSearchPrompt()
+	case "t", "h", "b", "j":
+		m.valFormat = formatForKey(msg.String())
+		if m.selected == "" {
+			return m, nil
+		}
+		m.editKey = ""
+		return m, loadValueCmd(m.store, m.selected)
+	case "x":
+		return m.cycleKeyDisplay(), nil
+	}
+	return m, nil
+}
+
+func (m Model) searchHeaderText() string {
+	mode := "substring"
+	if m.search.regex {
+		mode = "regex"
+	}
+	text := fmt.Sprintf("Values %s '%s': %d", mode, m.search.term, len(m.search.results))
+	switch {
+	case m.search.scanning:
+		text += " (scanning…)"
+	case m.search.capped:
+		text += "+ (capped)"
+	}
+	return text
+}
+
+// Each result takes two lines: the key, then the snippet.
+func (m Model) searchView(lay layout) string {
+	var lines []string
+	switch {
+	case m.search.err != "":
+		lines = append(lines, errStyle.Render("Error: "+m.search.err))
+	case len(m.search.results) == 0 && m.search.scanning:
+		lines = append(lines, "  Searching…")
+	case len(m.search.results) == 0:
+		lines = append(lines, "  No values matched.")
+	}
+	height := max(1, lay.listHeight-len(lines))
+	perPage := max(1, height/2)
+	start := 0
+	if m.search.cursor >= perPage {
+		start = m.search.cursor - perPage + 1
+	}
+	end := min(len(m.search.results), start+perPage)
+	width := lay.listWidth - 4
+	for i := start; i < end; i++ {
+		r := m.search.results[i]
+		key := truncateString(m.showKey(r.Key), lay.listWidth-2)
+		if i == m.search.cursor {
+			lines = append(lines, "│ "+selectedRowStyle.Render(key))
+		} else {
+			lines = append(lines, "  "+key)
+		}
+		lines = append(lines, "    "+renderSnippet(r, width))
+	}
+	if m.search.scanning && len(m.search.results) > 0 && len(lines) < lay.listHeight {
+		lines = append(lines, inspectorStyle.Render("  scanning at "+truncateString(m.showKey(m.search.lastKey), lay.listWidth-16)))
+	}
+	for len(lines) < lay.listHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines[:max(1, lay.listHeight)], "\n")
+}
+
+// renderSnippet keeps some text before the match and highlights it.
+func renderSnippet(r store.ValueMatch, width int) string {
+	before := snippetText(r.Snippet[:r.MatchStart])
+	match := truncateString(snippetText(r.Snippet[r.MatchStart:r.MatchEnd]), width)
+	after := snippetText(r.Snippet[r.MatchEnd:])
+	// I give at most half of the leftover room to the text before the match.
+	keep := max(0, (width-lipgloss.Width(match))/2)
+	for lipgloss.Width(before) > keep {
+		_, size := utf8.DecodeRuneInString(before)
+		before = before[size:]
+	}
+	room := width - lipgloss.Width(match) - lipgloss.Width(before)
+	return inspectorStyle.Render(before) + snippetMatchStyle.Render(match) + inspectorStyle.Render(truncateString(after, room))
+}
+
+// snippetText flattens whitespace and hides bytes that would break the layout.
+func snippetText(b []byte) string {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			sb.WriteByte(' ')
+		case r == utf8.RuneError && size <= 1, !unicode.IsPrint(r):
+			sb.WriteByte('.')
+		default:
+			sb.WriteRune(r)
+		}
+		b = b[size:]
+	}
+	return sb.String()
+}
@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"badge-reader/internal/store"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// seekContext is how many keys I show before the one sought.
+const seekContext = 50
+
+type seekKeysMsg struct {
+	query   store.KeyQuery
+	from    string
+	before  []string // nearest first
+	keys    []string
+	lastKey string
+	hasLess bool
+	hasMore bool
+	restore bool // I am bringing the list back after a filter, not seeking.
+	err     error
+}
+
+func seekKeysCmd(s Store, q store.KeyQuery, from string, limit int, restore bool) tea.Cmd {
+	return func() tea.Msg {
+		back := q
+		back.Reverse = !q.Reverse
+		before, _, hasLess, err := s.ListKeys(back, from, seekContext)
+		if err != nil {
+			return seekKeysMsg{query: q, from: from, restore: restore, err: err}
+		}
+		keys, lastKey, hasMore, err := s.ListKeysFrom(q, from, limit)
+		return seekKeysMsg{query: q, from: from, before: before, keys: keys, lastKey: lastKey, hasLess: hasLess, hasMore: hasMore, restore: restore, err: err}
+	}
+}
+
+func (m Model) openSeekPrompt() (Model, tea.Cmd) {
+	m.seekPrompt = true
+	m.seekInput.SetValue("")
+	m.status = "Go to the first key at or after this key or prefix. (Enter go · Esc cancel)"
+	if m.keyQuery.Reverse {
+		m.status = "Go to the last key at or before this key. (Enter go · Esc cancel)"
+	}
+	return m, m.seekInput.Focus()
+}
+
+func (m Model) updateSeekPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.seekPrompt = false
+		m.seekInput.Blur()
+		m.status = "Go to canceled."
+		return m, nil
+	case "enter":
+		text := m.seekInput.Value()
+		if strings.TrimSpace(text) == "" {
+			m.seekPrompt = false
+			m.seekInput.Blur()
+			m.status = "Go to canceled."
+			return m, nil
+		}
+		from, err := parseKeyInput(text)
+		if err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Error: invalid key: %v", err))
+			return m, nil
+		}
+		m.seekPrompt = false
+		m.seekInput.Blur()
+		m.status = fmt.Sprintf("Seeking to '%s'...", m.showKey(from))
+		m, cmd := m.resetKeys()
+		m.hasMoreKeys = false
+		return m, tea.Batch(cmd, seekKeysCmd(m.store, m.keyQuery, from, m.pageSize, false))
+	}
+	var cmd tea.Cmd
+	m.seekInput, cmd = m.seekInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) handleSeekKeys(msg seekKeysMsg) (Model, tea.Cmd) {
+	if msg.query != m.keyQuery || m.filter.active {
+		return m, nil
+	}
+	m.loadingKeys = false
+	if msg.err != nil {
+		m.status = errStyle.Render(fmt.Sprintf("Error: seek failed: %v", msg.err))
+		return m, nil
+	}
+	items := make([]list.Item, 0, len(msg.before)+len(msg.keys))
+	for i := len(msg.before) - 1; i >= 0; i-- {
+		items = append(items, kvItem{key: msg.before[i]})
+	}
+	for _, k := range msg.keys {
+		items = append(items, kvItem{key: k})
+	}
+	cmd := m.list.SetItems(items)
+	m.lastKey = msg.lastKey
+	m.hasMoreKeys = msg.hasMore
+	m.hasLessKeys = msg.hasLess
+	if len(msg.before) > 0 {
+		m.firstKey = msg.before[len(msg.before)-1]
+	} else if len(msg.keys) > 0 {
+		m.firstKey = msg.keys[0]
+	}
+	if len(items) == 0 {
+		m.status = "No keys in this range."
+		return m, cmd
+	}
+	idx := min(len(msg.before), len(items)-1)
+	m.list.Select(idx)
+	ki := items[idx].(kvItem)
+	switch {
+	case msg.restore:
+		m.status = "Filter cleared."
+	case ki.key == msg.from:
+		m.status = okStyle.Render(fmt.Sprintf("Found '%s'.", m.showKey(ki.key)))
+	case len(msg.keys) == 0:
+		m.status = fmt.Sprintf("No key past '%s'; showing the nearest ones.", m.showKey(msg.from))
+	default:
+		m.status = fmt.Sprintf("No key '%s'; showing the next one.", m.showKey(msg.from))
+	}
+	m.selected = ki.key
+	m.editKey = ""
+	return m, tea.Batch(cmd, loadValueCmd(m.store, ki.key))
+}
+
+// I flip the list order and start again from the matching end.
+func (m Model) toggleReverse() (Model, tea.Cmd) {
+	q := m.keyQuery
+	q.Reverse = !q.Reverse
+	if q.Reverse {
+		m.status = "Keys in descending order, from the end of the keyspace."
+	} else {
+		m.status = "Keys in ascending order."
+	}
+	return m.setKeyQuery(q)
+}
+
+// keyBefore reports whether a comes before b in the list's order.
+func (m Model) keyBefore(a, b string) bool {
+	if m.keyQuery.Reverse {
+		return a > b
+	}
+	return a < b
+}
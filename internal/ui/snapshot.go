@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// togglePin pins a snapshot so pages, values and scans all read the DB as
+// of one point in time, or lets go of the one that is pinned.
+func (m Model) togglePin() (Model, tea.Cmd) {
+	if m.pinnedTs != 0 {
+		m.store.Unpin()
+		m.pinnedTs = 0
+		return m.reloadSnapshot("Snapshot released; reads see the latest state.")
+	}
+	m.pinnedTs = m.store.Pin()
+	return m.reloadSnapshot(fmt.Sprintf("Pinned a snapshot at read ts %d. F5 re-pins to the latest state · P releases it.", m.pinnedTs))
+}
+
+// repin moves the pinned snapshot to the latest state.
+func (m Model) repin() (Model, tea.Cmd) {
+	if m.pinnedTs == 0 {
+		m.status = "No snapshot is pinned; P pins one."
+		return m, nil
+	}
+	was := m.pinnedTs
+	m.pinnedTs = m.store.Pin()
+	if m.pinnedTs == was {
+		m.status = fmt.Sprintf("Nothing has changed since read ts %d.", was)
+		return m, nil
+	}
+	return m.reloadSnapshot(fmt.Sprintf("Re-pinned the snapshot at read ts %d (was %d).", m.pinnedTs, was))
+}
+
+// reloadSnapshot reloads what is on screen from the new point in time: the
+// list from its start, the shown value, the tree and the group counts.
+func (m Model) reloadSnapshot(status string) (Model, tea.Cmd) {
+	m, cmd := m.setKeyQuery(m.keyQuery)
+	cmds := []tea.Cmd{cmd}
+	if m.selected != "" {
+		cmds = append(cmds, loadValueCmd(m.store, m.selected))
+	}
+	if m.tree.children != nil {
+		var tcmd tea.Cmd
+		m, tcmd = m.reloadTree()
+		cmds = append(cmds, tcmd)
+	}
+	if m.showGroupCounts {
+		// I restart the counts rather than mix two points in time.
+		m = m.cancelJobs(jobGroups)
+		m.groupCountsLoading = false
+		m.showGroupCounts = false
+		var gcmd tea.Cmd
+		m, gcmd = m.toggleGroupCounts()
+		cmds = append(cmds, gcmd)
+	}
+	m.status = status
+	return m, tea.Batch(cmds...)
+}
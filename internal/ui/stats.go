@@ -0,0 +1,202 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
+)
+
+// The statistics dashboard replaces the two panes with Badger's own view of
+// the DB. It is a picture taken when it opens and on r, not a live one.
+type statsState struct {
+	active  bool
+	loading bool
+	loaded  bool
+	stats   store.Stats
+	scroll  int // first table row shown
+}
+
+type statsMsg struct {
+	stats store.Stats
+}
+
+// Badger walks every table for these, so I gather them off the UI goroutine.
+func loadStatsCmd(s Store) tea.Cmd {
+	return func() tea.Msg {
+		return statsMsg{stats: s.Stats()}
+	}
+}
+
+func (m Model) toggleStats() (Model, tea.Cmd) {
+	if m.stats.active {
+		m.stats.active = false
+		m.status = "List focused."
+		return m, nil
+	}
+	m.stats.active = true
+	return m.refreshStats()
+}
+
+func (m Model) refreshStats() (Model, tea.Cmd) {
+	m.stats.loading = true
+	m.status = "Statistics. r refresh · ↑/↓ scroll tables · S/Esc close"
+	return m, loadStatsCmd(m.store)
+}
+
+func (m Model) handleStats(msg statsMsg) (Model, tea.Cmd) {
+	m.stats.loading = false
+	m.stats.loaded = true
+	m.stats.stats = msg.stats
+	m.stats.scroll = clamp(m.stats.scroll, 0, max(0, len(msg.stats.Tables)-1))
+	if m.stats.active {
+		m.status = fmt.Sprintf("Statistics as of %s. r refresh · ↑/↓ scroll tables · S/Esc close", msg.stats.At.Format(time.TimeOnly))
+	}
+	return m, nil
+}
+
+func (m Model) updateStatsKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	page := max(1, m.statsTableRows(computeLayout(m.width, m.height))-1)
+	last := max(0, len(m.stats.stats.Tables)-page)
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "S":
+		return m.toggleStats()
+	case "r", "f5":
+		return m.refreshStats()
+	case "up", "k":
+		m.stats.scroll = max(0, m.stats.scroll-1)
+	case "down", "j":
+		m.stats.scroll = clamp(m.stats.scroll+1, 0, last)
+	case "pgup":
+		m.stats.scroll = max(0, m.stats.scroll-page)
+	case "pgdown":
+		m.stats.scroll = clamp(m.stats.scroll+page, 0, last)
+	case "home", "g":
+		m.stats.scroll = 0
+	case "end", "G":
+		m.stats.scroll = last
+	}
+	return m, nil
+}
+
+// statsView fills the body between the header and the footer.
+func (m Model) statsView(lay layout) string {
+	top := m.statsTop(lay.innerWidth)
+	if !m.stats.loaded {
+		return top
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, top, m.statsTables(lay.innerWidth, m.statsTableRows(lay)))
+}
+
+func (m Model) statsTop(width int) string {
+	if !m.stats.loaded {
+		return m.statsOverview(width)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, m.statsOverview(width), m.statsLevels(width))
+}
+
+// statsTableRows is how many table rows fit under the overview and levels.
+func (m Model) statsTableRows(lay layout) int {
+	used := lipgloss.Height(m.statsTop(lay.innerWidth))
+	return max(1, lay.bodyHeight-used-paneStyle.GetVerticalFrameSize()-panelHeaderLines-1)
+}
+
+func statsPane(title string, width int, lines []string) string {
+	inner := width - paneStyle.GetHorizontalFrameSize()
+	for i, l := range lines {
+		lines[i] = truncateString(l, inner)
+	}
+	header := panelHeaderStyle.Render(padToWidth(truncateString(title, inner), inner))
+	return paneStyle.Width(width - paneStyle.GetHorizontalBorderSize()).Render(header + "\n" + strings.Join(lines, "\n"))
+}
+
+func (m Model) statsOverview(width int) string {
+	s := m.stats.stats
+	if !m.stats.loaded {
+		return statsPane("Overview", width, []string{"Loading…"})
+	}
+	title := "Overview as of " + s.At.Format(time.TimeOnly)
+	if m.stats.loading {
+		title += " · refreshing…"
+	}
+	compaction := fmt.Sprintf("%d compactors · ", s.Compactors)
+	if s.Compactors == 0 {
+		compaction = "not running (read-only) · "
+	}
+	switch s.Pending {
+	case 0:
+		compaction += "nothing pending"
+	case 1:
+		compaction += "1 level pending"
+	default:
+		compaction += fmt.Sprintf("%d levels pending", s.Pending)
+	}
+	lines := []string{
+		fmt.Sprintf("Size        LSM %s · value log %s (Badger measures them once a minute)",
+			humanize.IBytes(uint64(s.LSMSize)), humanize.IBytes(uint64(s.VlogSize))),
+		fmt.Sprintf("Versions    max version %d", s.MaxVersion),
+		"Compaction  " + compaction,
+		"Block cache " + cacheLine(s.BlockCache, "off"),
+		"Index cache " + cacheLine(s.IndexCache, "off (indexes stay in memory)"),
+	}
+	return statsPane(title, width, lines)
+}
+
+func cacheLine(c store.CacheStats, off string) string {
+	if !c.Enabled {
+		return off
+	}
+	if c.Hits+c.Misses == 0 {
+		return fmt.Sprintf("%s · no lookups yet", humanize.IBytes(uint64(c.MaxCost)))
+	}
+	return fmt.Sprintf("%s · %.1f%% hits (%s hits · %s misses)", humanize.IBytes(uint64(c.MaxCost)),
+		c.Ratio*100, humanize.Comma(int64(c.Hits)), humanize.Comma(int64(c.Misses)))
+}
+
+func (m Model) statsLevels(width int) string {
+	lines := []string{fmt.Sprintf("%-7s %7s %12s %10s %10s %10s %7s", "Level", "Tables", "Keys", "Size", "Target", "Stale", "Fill")}
+	for _, l := range m.stats.stats.Levels {
+		name := fmt.Sprintf("L%d", l.Level)
+		if l.Base {
+			name += " base"
+		}
+		target := humanize.IBytes(uint64(l.TargetSize))
+		if l.Level == 0 {
+			target = "-"
+		}
+		line := fmt.Sprintf("%-7s %7d %12s %10s %10s %10s %6.0f%%", name, l.Tables, humanize.Comma(int64(l.Keys)),
+			humanize.IBytes(uint64(l.Size)), target, humanize.IBytes(uint64(l.StaleSize)), l.Fill*100)
+		if l.Adjusted >= 1 {
+			line = errStyle.Render(fmt.Sprintf("%s  compaction pending (priority %.2f)", line, l.Adjusted))
+		}
+		lines = append(lines, line)
+	}
+	return statsPane("LSM levels", width, lines)
+}
+
+func (m Model) statsTables(width, rows int) string {
+	tables := m.stats.stats.Tables
+	title := fmt.Sprintf("Tables %d", len(tables))
+	lines := []string{fmt.Sprintf("%-8s %-5s %10s %10s %12s %10s %12s  %s", "ID", "Level", "Keys", "Size", "Uncompressed", "Stale", "Max version", "Key range")}
+	if len(tables) == 0 {
+		lines = append(lines, "No tables yet; every key is still in the memtable.")
+	}
+	start := clamp(m.stats.scroll, 0, max(0, len(tables)-1))
+	end := min(len(tables), start+rows)
+	for _, t := range tables[start:end] {
+		lines = append(lines, fmt.Sprintf("%-8d L%-4d %10s %10s %12s %10s %12d  %s … %s", t.ID, t.Level,
+			humanize.Comma(int64(t.Keys)), humanize.IBytes(uint64(t.Size)), humanize.IBytes(uint64(t.UncompressedSize)),
+			humanize.IBytes(uint64(t.StaleSize)), t.MaxVersion, m.showKey(t.Smallest), m.showKey(t.Biggest)))
+	}
+	if len(tables) > rows {
+		title += fmt.Sprintf(" · %d–%d", start+1, end)
+	}
+	return statsPane(title, width, lines)
+}
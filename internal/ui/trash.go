@@ -0,0 +1,215 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+// The trash lists what deletes and saves replaced, newest first, from the
+// undo journal next to the DB.
+type trashState struct {
+	active  bool
+	loading bool
+	entries []store.TrashEntry
+	cursor  int
+	err     string
+}
+
+type trashMsg struct {
+	entries []store.TrashEntry
+	err     error
+}
+
+type restoreMsg struct {
+	entries []store.TrashEntry
+	undo    bool
+	err     error
+}
+
+func loadTrashCmd(s Store) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := s.Trash()
+		// I show the latest change first.
+		for i, k := 0, len(entries)-1; i < k; i, k = i+1, k-1 {
+			entries[i], entries[k] = entries[k], entries[i]
+		}
+		return trashMsg{entries: entries, err: err}
+	}
+}
+
+func restoreCmd(s Store, ids []int) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := s.Restore(ids)
+		return restoreMsg{entries: entries, err: err}
+	}
+}
+
+func undoCmd(s Store) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := s.Undo()
+		return restoreMsg{entries: entries, undo: true, err: err}
+	}
+}
+
+func (m Model) toggleTrash() (Model, tea.Cmd) {
+	if m.trash.active {
+		m.trash = trashState{}
+		m.status = "List focused."
+		return m, nil
+	}
+	m.trash = trashState{active: true, loading: true}
+	m.status = "Trash. ↑/↓ select · Enter/r restore · u undo last change · T/Esc close"
+	return m, loadTrashCmd(m.store)
+}
+
+func (m Model) undo() (Model, tea.Cmd) {
+	if m.denyReadOnly("undo") {
+		return m, nil
+	}
+	m.status = "Undoing the last change..."
+	return m, undoCmd(m.store)
+}
+
+func (m Model) updateTrashKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	n := len(m.trash.entries)
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "T":
+		return m.toggleTrash()
+	case "up":
+		m.trash.cursor = max(0, m.trash.cursor-1)
+	case "down":
+		m.trash.cursor = clamp(m.trash.cursor+1, 0, max(0, n-1))
+	case "pgup":
+		m.trash.cursor = max(0, m.trash.cursor-10)
+	case "pgdown":
+		m.trash.cursor = clamp(m.trash.cursor+10, 0, max(0, n-1))
+	case "enter", "r":
+		if m.trash.cursor >= n || m.denyReadOnly("restore") {
+			return m, nil
+		}
+		e := m.trash.entries[m.trash.cursor]
+		if e.Restored {
+			m.status = fmt.Sprintf("'%s' was already restored from this entry.", m.showKey(e.Key))
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Restoring '%s'...", m.showKey(e.Key))
+		return m, restoreCmd(m.store, []int{e.ID})
+	case "u":
+		return m.undo()
+	}
+	return m, nil
+}
+
+func (m Model) handleTrash(msg trashMsg) (Model, tea.Cmd) {
+	if !m.trash.active {
+		return m, nil
+	}
+	m.trash.loading = false
+	m.trash.err = ""
+	if msg.err != nil {
+		m.trash.err = msg.err.Error()
+		return m, nil
+	}
+	m.trash.entries = msg.entries
+	m.trash.cursor = clamp(m.trash.cursor, 0, max(0, len(msg.entries)-1))
+	return m, nil
+}
+
+// handleRestore brings the list in line with what was put back, even after
+// a partial failure.
+func (m Model) handleRestore(msg restoreMsg) (Model, tea.Cmd) {
+	reload := false
+	for _, e := range msg.entries {
+		if e.Existed {
+			m.insertKey(e.Key)
+		} else {
+			m.removeKey(e.Key)
+		}
+		reload = reload || e.Key == m.selected
+	}
+	var cmds []tea.Cmd
+	if reload {
+		cmds = append(cmds, loadValueCmd(m.store, m.selected))
+	}
+	if m.trash.active {
+		cmds = append(cmds, loadTrashCmd(m.store))
+	}
+	switch {
+	case errors.Is(msg.err, store.ErrNothingToUndo):
+		m.status = "Nothing to undo."
+	case notLogged(msg.err):
+		m.status = errStyle.Render(fmt.Sprintf("Warning: restored %s keys, but %v", humanize.Comma(int64(len(msg.entries))), msg.err))
+	case msg.err != nil && len(msg.entries) > 0:
+		m.status = errStyle.Render(fmt.Sprintf("Error: restored %d keys, then: %v", len(msg.entries), msg.err))
+	case msg.err != nil:
+		m.status = errStyle.Render(fmt.Sprintf("Error: restore failed: %v", msg.err))
+	case len(msg.entries) == 1:
+		m.status = okStyle.Render(fmt.Sprintf("Restored '%s' (%s).", m.showKey(msg.entries[0].Key), restoreVerb(msg.entries[0])))
+	case msg.undo:
+		m.status = okStyle.Render(fmt.Sprintf("Undid the %s of %s keys.", msg.entries[0].Kind, humanize.Comma(int64(len(msg.entries)))))
+	default:
+		m.status = okStyle.Render(fmt.Sprintf("Restored %s keys.", humanize.Comma(int64(len(msg.entries)))))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// restoreVerb says what putting an entry back did to its key.
+func restoreVerb(e store.TrashEntry) string {
+	if e.Existed {
+		return "value before " + e.Kind
+	}
+	return "removed, it did not exist before " + e.Kind
+}
+
+func (m Model) trashView(width int) string {
+	lines := []string{"Trash (↑/↓ select · Enter/r restore · u undo last change · T/Esc close)"}
+	switch {
+	case m.trash.loading:
+		lines = append(lines, "Loading…")
+	case m.trash.err != "":
+		lines = append(lines, errStyle.Render("Error: "+m.trash.err))
+	case len(m.trash.entries) == 0:
+		lines = append(lines, "Nothing deleted or changed yet.")
+	}
+	perPage := max(5, m.height/2-2)
+	start := 0
+	if m.trash.cursor >= perPage {
+		start = m.trash.cursor - perPage + 1
+	}
+	end := min(len(m.trash.entries), start+perPage)
+	for i := start; i < end; i++ {
+		e := m.trash.entries[i]
+		what := humanize.IBytes(uint64(e.Size))
+		if !e.Existed {
+			what = "(absent)"
+		}
+		state := ""
+		if e.Restored {
+			state = "restored"
+		}
+		line := fmt.Sprintf("%s  %-14s  %-40s %10s  %s",
+			e.At.Local().Format(time.DateTime), e.Kind,
+			truncateString(m.showKey(e.Key), 40), what, state)
+		line = truncateString(line, width-4)
+		switch {
+		case i == m.trash.cursor:
+			line = selectedRowStyle.Render(line)
+		case e.Restored:
+			line = inspectorStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if end < len(m.trash.entries) {
+		lines = append(lines, inspectorStyle.Render(fmt.Sprintf("… %d more", len(m.trash.entries)-end)))
+	}
+	return paneStyle.Width(width).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,360 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"badge-reader/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+// DefaultDelimiters splits keys like "tenant/42/order:7" into tree levels.
+const DefaultDelimiters = "/:"
+
+// treePageSize is how many children one expand (or "more") loads.
+const treePageSize = 200
+
+// treeChildren is the loaded part of one prefix's child list.
+type treeChildren struct {
+	nodes   []store.TreeNode
+	hasMore bool
+	loading bool
+	job     int // the job loading the next page, while loading
+	err     string
+}
+
+// I keep the tree next to the flat list so switching back loses nothing.
+type treeState struct {
+	active   bool
+	delims   string
+	children map[string]*treeChildren
+	expanded map[string]bool
+	cursor   int
+}
+
+// treeRow is one visible line; a more row pages the parent's children.
+type treeRow struct {
+	parent string
+	node   store.TreeNode
+	depth  int
+	more   bool
+}
+
+type treeChildrenMsg struct {
+	job        int
+	parent     string
+	startAfter string
+	nodes      []store.TreeNode
+	hasMore    bool
+	err        error
+}
+
+func loadTreeChildrenCmd(s Store, j job, parent, delims, startAfter string) tea.Cmd {
+	return func() tea.Msg {
+		nodes, hasMore, err := s.ListChildren(j.ctx, parent, delims, startAfter, treePageSize, j.progress)
+		return treeChildrenMsg{job: j.id, parent: parent, startAfter: startAfter, nodes: nodes, hasMore: hasMore, err: err}
+	}
+}
+
+// loadTreeChildren starts the job that loads a page of parent's children.
+// Counting them walks every key below, so it runs as a job that can be
+// canceled and shows its progress.
+func (m Model) loadTreeChildren(c *treeChildren, parent, startAfter string) (Model, tea.Cmd) {
+	label := "Tree: children of '" + m.showKey(parent) + "'"
+	if parent == "" {
+		label = "Tree: top level"
+	}
+	m, j, tick := m.startJob(jobTree, label)
+	c.loading, c.job = true, j.id
+	return m, tea.Batch(tick, loadTreeChildrenCmd(m.store, j, parent, m.tree.delims, startAfter))
+}
+
+// stopTreeLoads cancels the loads under prefix ("" for all). A node whose
+// first page was not in yet is collapsed, so expanding it loads it again;
+// one that was loading more keeps its more row.
+func (m Model) stopTreeLoads(prefix string) Model {
+	for parent, c := range m.tree.children {
+		if !c.loading || !strings.HasPrefix(parent, prefix) {
+			continue
+		}
+		m = m.cancelJob(c.job, "canceled")
+		m = m.settleTreeLoad(parent, c)
+	}
+	return m
+}
+
+func (m Model) settleTreeLoad(parent string, c *treeChildren) Model {
+	c.loading, c.job = false, 0
+	switch {
+	case len(c.nodes) > 0:
+	case parent == "" && m.tree.active:
+		c.err = "canceled; r reloads"
+	case parent == "":
+		// Opening the tree again loads it from the start.
+		m.tree.children = nil
+	default:
+		delete(m.tree.children, parent)
+		delete(m.tree.expanded, parent)
+	}
+	return m
+}
+
+func (m Model) openTree() (Model, tea.Cmd) {
+	m.tree.active = true
+	m.focusRight = false
+	m.status = "Tree: ↑/↓ move · →/Space expand · ← collapse · Enter scope list to prefix · r reload · Tab/Esc list"
+	if m.tree.children == nil {
+		return m.reloadTree()
+	}
+	return m, nil
+}
+
+func (m Model) reloadTree() (Model, tea.Cmd) {
+	m = m.stopTreeLoads("")
+	top := &treeChildren{}
+	m.tree.children = map[string]*treeChildren{"": top}
+	m.tree.expanded = map[string]bool{}
+	m.tree.cursor = 0
+	return m.loadTreeChildren(top, "", "")
+}
+
+func (m Model) closeTree() Model {
+	m.tree.active = false
+	m = m.stopTreeLoads("")
+	m.status = "List focused."
+	return m
+}
+
+// treeRows flattens the expanded part of the tree in key order.
+func (m Model) treeRows() []treeRow {
+	var rows []treeRow
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		c := m.tree.children[parent]
+		if c == nil {
+			return
+		}
+		for _, n := range c.nodes {
+			rows = append(rows, treeRow{parent: parent, node: n, depth: depth})
+			if !n.Leaf && m.tree.expanded[n.Prefix] {
+				walk(n.Prefix, depth+1)
+			}
+		}
+		if c.hasMore || c.loading || c.err != "" {
+			rows = append(rows, treeRow{parent: parent, depth: depth, more: true})
+		}
+	}
+	walk("", 0)
+	return rows
+}
+
+func (m Model) selectedTreeRow() (treeRow, bool) {
+	rows := m.treeRows()
+	if m.tree.cursor < 0 || m.tree.cursor >= len(rows) {
+		return treeRow{}, false
+	}
+	return rows[m.tree.cursor], true
+}
+
+func (m Model) expandTreeNode(n store.TreeNode) (Model, tea.Cmd) {
+	if n.Leaf {
+		return m, nil
+	}
+	m.tree.expanded[n.Prefix] = true
+	if _, ok := m.tree.children[n.Prefix]; ok {
+		return m, nil
+	}
+	c := &treeChildren{}
+	m.tree.children[n.Prefix] = c
+	return m.loadTreeChildren(c, n.Prefix, "")
+}
+
+func (m Model) loadMoreTreeChildren(parent string) (Model, tea.Cmd) {
+	c := m.tree.children[parent]
+	if c == nil || c.loading || !c.hasMore || len(c.nodes) == 0 {
+		return m, nil
+	}
+	return m.loadTreeChildren(c, parent, c.nodes[len(c.nodes)-1].Prefix)
+}
+
+func (m Model) updateTreeKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	rows := m.treeRows()
+	row, ok := m.selectedTreeRow()
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "tab", "esc":
+		return m.closeTree(), nil
+	case "up":
+		m.tree.cursor = max(0, m.tree.cursor-1)
+	case "down":
+		m.tree.cursor = clamp(m.tree.cursor+1, 0, max(0, len(rows)-1))
+		if next, ok := m.selectedTreeRow(); ok && next.more {
+			return m.loadMoreTreeChildren(next.parent)
+		}
+	case "pgup":
+		m.tree.cursor = max(0, m.tree.cursor-10)
+	case "pgdown":
+		m.tree.cursor = clamp(m.tree.cursor+10, 0, max(0, len(rows)-1))
+	case "right", " ":
+		if !ok || row.more {
+			return m, nil
+		}
+		if msg.String() == " " && m.tree.expanded[row.node.Prefix] {
+			return m.collapseTreeNode(row.node.Prefix), nil
+		}
+		return m.expandTreeNode(row.node)
+	case "left":
+		if !ok {
+			return m, nil
+		}
+		if !row.more && m.tree.expanded[row.node.Prefix] {
+			return m.collapseTreeNode(row.node.Prefix), nil
+		}
+		// I jump to the parent row so repeated ← walks up the tree.
+		for i := m.tree.cursor - 1; i >= 0; i-- {
+			if rows[i].node.Prefix == row.parent && !rows[i].more {
+				m.tree.cursor = i
+				break
+			}
+		}
+	case "enter":
+		switch {
+		case !ok:
+			return m, nil
+		case row.more:
+			return m.loadMoreTreeChildren(row.parent)
+		case row.node.Leaf:
+			m.selected = row.node.Prefix
+			m.editKey = ""
+			m.focusRight = true
+			return m, loadValueCmd(m.store, row.node.Prefix)
+		}
+		m = m.closeTree()
+		m.status = fmt.Sprintf("Showing keys under '%s' (Backspace widens).", m.showKey(row.node.Prefix))
+		q := m.keyQuery
+		q.Prefix = row.node.Prefix
+		return m.setKeyQuery(q)
+	case "r":
+		m.status = "Tree reloaded."
+		return m.reloadTree()
+	case "t", "h", "b", "j":
+		m.valFormat = formatForKey(msg.String())
+		if m.selected == "" {
+			return m, nil
+		}
+		m.editKey = ""
+		return m, loadValueCmd(m.store, m.selected)
+	case "x":
+		return m.cycleKeyDisplay(), nil
+	}
+	return m, nil
+}
+
+// collapseTreeNode hides prefix's children and stops loading what is
+// below it, as nobody is looking.
+func (m Model) collapseTreeNode(prefix string) Model {
+	delete(m.tree.expanded, prefix)
+	return m.stopTreeLoads(prefix)
+}
+
+// widenPrefix moves the list scope one tree level up.
+func (m Model) widenPrefix() (Model, tea.Cmd) {
+	if m.keyQuery.Prefix == "" {
+		return m, nil
+	}
+	q := m.keyQuery
+	q.Prefix = store.ParentPrefix(q.Prefix, m.tree.delims)
+	if q.Prefix == "" {
+		m.status = "Prefix scope cleared."
+	} else {
+		m.status = fmt.Sprintf("Showing keys under '%s'.", m.showKey(q.Prefix))
+	}
+	return m.setKeyQuery(q)
+}
+
+func (m Model) handleTreeChildren(msg treeChildrenMsg) Model {
+	m = m.endJob(msg.job, msg.err)
+	c := m.tree.children[msg.parent]
+	if c == nil || !c.loading || c.job != msg.job {
+		// I drop pages of a load that was canceled, or of a tree that was
+		// reloaded meanwhile.
+		return m
+	}
+	c.loading, c.job = false, 0
+	if msg.err != nil {
+		c.err = msg.err.Error()
+		return m
+	}
+	c.err = ""
+	if msg.startAfter == "" {
+		c.nodes = nil
+	}
+	c.nodes = append(c.nodes, msg.nodes...)
+	c.hasMore = msg.hasMore
+	return m
+}
+
+func (m Model) treeHeaderText() string {
+	top := m.tree.children[""]
+	if top == nil {
+		return "Tree"
+	}
+	suffix := ""
+	if top.hasMore {
+		suffix = "+"
+	}
+	return fmt.Sprintf("Tree (%s) %d%s", m.tree.delims, len(top.nodes), suffix)
+}
+
+func (m Model) treeView(lay layout) string {
+	rows := m.treeRows()
+	height := max(1, lay.listHeight)
+	start := 0
+	if m.tree.cursor >= height {
+		start = m.tree.cursor - height + 1
+	}
+	end := min(len(rows), start+height)
+	var lines []string
+	for i := start; i < end; i++ {
+		line := truncateString(m.treeLine(rows[i]), lay.listWidth-2)
+		if i == m.tree.cursor {
+			lines = append(lines, "│ "+selectedRowStyle.Render(line))
+		} else {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if len(rows) == 0 {
+		lines = append(lines, "  No keys found.")
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) treeLine(r treeRow) string {
+	indent := strings.Repeat("  ", r.depth)
+	if r.more {
+		c := m.tree.children[r.parent]
+		switch {
+		case c.loading:
+			return indent + "  Loading…"
+		case c.err != "":
+			return indent + "  " + errStyle.Render("Error: "+c.err)
+		}
+		return indent + "  … more (Enter)"
+	}
+	// I only show the segment below the parent, like a file browser.
+	label := m.showKey(r.node.Prefix[len(r.parent):])
+	if r.node.Leaf {
+		return indent + "  " + label
+	}
+	marker := "▸ "
+	if m.tree.expanded[r.node.Prefix] {
+		marker = "▾ "
+	}
+	return fmt.Sprintf("%s%s%s (%s)", indent, marker, label, humanize.Comma(int64(r.node.Count)))
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type ttlMode int
+
+const (
+	ttlKeep ttlMode = iota
+	ttlSet
+	ttlClear
+)
+
+// ttlChange is what a save does to the key's expiry.
+type ttlChange struct {
+	mode      ttlMode
+	expiresAt uint64
+}
+
+type ttlTickMsg struct{}
+
+func ttlTickCmd() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return ttlTickMsg{} })
+}
+
+// parseTTLInput reads the TTL prompt:
+//
+//	"" or keep             leave the TTL as it is
+//	0, none or clear       remove the TTL
+//	90m, 2h                expire that long from now
+//	+1h                    extend the current expiry (or now, if none)
+//	2026-01-02 15:04:05    expire at a local time (RFC 3339 also works)
+func parseTTLInput(in string, current uint64, now time.Time) (ttlChange, error) {
+	in = strings.TrimSpace(in)
+	switch strings.ToLower(in) {
+	case "", "keep":
+		return ttlChange{mode: ttlKeep}, nil
+	case "0", "none", "clear", "off":
+		return ttlChange{mode: ttlClear}, nil
+	}
+
+	var at time.Time
+	if strings.HasPrefix(in, "+") {
+		d, err := time.ParseDuration(in[1:])
+		if err != nil {
+			return ttlChange{}, fmt.Errorf("invalid extension %q: %w", in, err)
+		}
+		base := now
+		if current != 0 && int64(current) > now.Unix() {
+			base = time.Unix(int64(current), 0)
+		}
+		at = base.Add(d)
+	} else if d, err := time.ParseDuration(in); err == nil {
+		at = now.Add(d)
+	} else if t, err := time.Parse(time.RFC3339, in); err == nil {
+		at = t
+	} else if t, err := time.ParseInLocation(time.DateTime, in, time.Local); err == nil {
+		at = t
+	} else {
+		return ttlChange{}, fmt.Errorf("invalid TTL %q (try 2h, +30m, clear or 2006-01-02 15:04:05)", in)
+	}
+	if !at.After(now) {
+		return ttlChange{}, fmt.Errorf("expiry %s is in the past", at.Format(time.DateTime))
+	}
+	return ttlChange{mode: ttlSet, expiresAt: uint64(at.Unix())}, nil
+}
+
+// describeExpiry shows an absolute expiry with a countdown.
+func describeExpiry(expiresAt uint64, now time.Time) string {
+	if expiresAt == 0 {
+		return "no TTL"
+	}
+	at := time.Unix(int64(expiresAt), 0)
+	if !at.After(now) {
+		return "expired " + at.Format(time.DateTime)
+	}
+	return fmt.Sprintf("expires %s (in %s)", at.Format(time.DateTime), at.Sub(now).Round(time.Second))
+}
+
+func (m Model) editTTLLabel(now time.Time) string {
+	switch m.editTTL.mode {
+	case ttlSet:
+		return "TTL → " + describeExpiry(m.editTTL.expiresAt, now)
+	case ttlClear:
+		return "TTL → none"
+	default:
+		if m.editExpiresAt == 0 {
+			return "TTL: none"
+		}
+		return "TTL kept: " + describeExpiry(m.editExpiresAt, now)
+	}
+}
+
+func (m Model) openTTLPrompt() (Model, tea.Cmd) {
+	m.ttlPrompt = true
+	value := ""
+	if m.editTTL.mode == ttlSet {
+		value = time.Unix(int64(m.editTTL.expiresAt), 0).Format(time.DateTime)
+	} else if m.editTTL.mode == ttlClear {
+		value = "clear"
+	}
+	m.ttlInput.SetValue(value)
+	m.ttlInput.CursorEnd()
+	m.status = "TTL: 2h · +30m · clear · keep · 2006-01-02 15:04:05 (Enter apply · Esc cancel)"
+	return m, m.ttlInput.Focus()
+}
+
+func (m Model) updateTTLPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.ttlPrompt = false
+		m.ttlInput.Blur()
+		m.status = "TTL unchanged."
+		return m, m.editor.Focus()
+	case "enter":
+		change, err := parseTTLInput(m.ttlInput.Value(), m.editExpiresAt, time.Now())
+		if err != nil {
+			m.status = errStyle.Render(fmt.Sprintf("Error: %v", err))
+			return m, nil
+		}
+		m.editTTL = change
+		m.ttlPrompt = false
+		m.ttlInput.Blur()
+		m.status = m.editTTLLabel(time.Now()) + " (applied on Ctrl+S)"
+		focus := m.editor.Focus()
+		m, tick := m.maybeStartTTLTick()
+		return m, tea.Batch(focus, tick)
+	}
+	var cmd tea.Cmd
+	m.ttlInput, cmd = m.ttlInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) showsCountdown() bool {
+	if m.editing {
+		return m.editExpiresAt != 0 || m.editTTL.mode == ttlSet
+	}
+	return m.selectedExpiresAt != 0
+}
+
+// I only keep the one-second tick alive while a TTL countdown is on screen.
+func (m Model) maybeStartTTLTick() (Model, tea.Cmd) {
+	if m.ttlTicking || !m.showsCountdown() {
+		return m, nil
+	}
+	m.ttlTicking = true
+	return m, ttlTickCmd()
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTTLInput(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	at := func(t time.Time) uint64 { return uint64(t.Unix()) }
+	current := at(now.Add(time.Hour))
+	tests := []struct {
+		in      string
+		current uint64
+		want    ttlChange
+	}{
+		{"", current, ttlChange{mode: ttlKeep}},
+		{"  keep ", current, ttlChange{mode: ttlKeep}},
+		{"0", current, ttlChange{mode: ttlClear}},
+		{"none", current, ttlChange{mode: ttlClear}},
+		{"Clear", current, ttlChange{mode: ttlClear}},
+		{"off", current, ttlChange{mode: ttlClear}},
+		{"90m", current, ttlChange{ttlSet, at(now.Add(90 * time.Minute))}},
+		{"2h", 0, ttlChange{ttlSet, at(now.Add(2 * time.Hour))}},
+		{"+30m", current, ttlChange{ttlSet, at(now.Add(90 * time.Minute))}},
+		{"+30m", 0, ttlChange{ttlSet, at(now.Add(30 * time.Minute))}},
+		// An expiry already gone extends from now.
+		{"+30m", at(now.Add(-time.Hour)), ttlChange{ttlSet, at(now.Add(30 * time.Minute))}},
+		{"2026-03-11 08:30:00", 0, ttlChange{ttlSet, at(time.Date(2026, 3, 11, 8, 30, 0, 0, time.Local))}},
+		{"2026-03-11T08:30:00Z", 0, ttlChange{ttlSet, at(time.Date(2026, 3, 11, 8, 30, 0, 0, time.UTC))}},
+	}
+	for _, tt := range tests {
+		got, err := parseTTLInput(tt.in, tt.current, now)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTTLInputRejects(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, in := range []string{
+		"soon",
+		"+",
+		"+soon",
+		"2026-13-01 00:00:00",
+		"-5m",
+		"+-2h",
+		"2026-03-10 11:59:59",
+		"2026-03-10T12:00:00" + now.Format("Z07:00"),
+	} {
+		if got, err := parseTTLInput(in, 0, now); err == nil {
+			t.Errorf("%q: got %+v, want an error", in, got)
+		}
+	}
+}
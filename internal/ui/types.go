@@ -1,8 +1,12 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"time"
+
+	"badge-reader/internal/store"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -12,13 +16,49 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Store is what the UI needs from the DB. Methods that may scan a large
+// part of the keyspace take a context so they can be canceled.
 type Store interface {
 	ListKeysPage(startAfter string, limit int) ([]string, string, bool, error)
-	CountKeysMatching(term string) (int, error)
-	GroupKeyCounts() (map[string]int, error)
+	ListKeys(q store.KeyQuery, startAfter string, limit int) ([]string, string, bool, error)
+	CountKeysMatching(ctx context.Context, q store.KeyQuery, m store.Matcher, p *store.Progress) (int, error)
+	GroupKeyCounts(ctx context.Context, p *store.Progress) (map[string]int, error)
 	Get(key string) ([]byte, error)
 	Set(key string, value []byte) error
 	Delete(key string) error
+	ReadOnly() bool
+	GetEntry(key string) (store.Entry, error)
+	SetWith(key string, value []byte, o store.SetOptions) error
+	Inspect(key string) (store.KeyInfo, error)
+	History(key string) ([]store.KeyVersion, error)
+	GetVersion(key string, version uint64) (store.Entry, error)
+	ListChildren(ctx context.Context, prefix, delims, startAfter string, limit int, p *store.Progress) ([]store.TreeNode, bool, error)
+	ListKeysFrom(q store.KeyQuery, from string, limit int) ([]string, string, bool, error)
+	SearchValues(ctx context.Context, vs store.ValueSearch, startAfter string, scanLimit int, p *store.Progress) ([]store.ValueMatch, string, bool, error)
+	FilterKeys(ctx context.Context, q store.KeyQuery, m store.Matcher, cursor string, scanLimit, limit int) ([]string, string, bool, error)
+	PlanDelete(ctx context.Context, m store.Matcher, sample int, p *store.Progress) (store.DeletePlan, error)
+	DeleteMatching(ctx context.Context, m store.Matcher, p *store.Progress) store.DeleteSummary
+	Trash() ([]store.TrashEntry, error)
+	Restore(ids []int) ([]store.TrashEntry, error)
+	Undo() ([]store.TrashEntry, error)
+	EachEntry(ctx context.Context, q store.KeyQuery, m *store.Matcher, p *store.Progress, fn func(key []byte, e store.Entry) error) error
+	Import(ctx context.Context, next func() (store.ImportEntry, error), c store.Conflict, dryRun bool, p *store.Progress) store.ImportSummary
+	Backup(ctx context.Context, w io.Writer, since uint64, prefix string, p *store.Progress) (uint64, int, error)
+	OpenRestoreTarget(dir string) (*store.BadgerStore, error)
+	EncryptionKey() []byte
+	Pin() uint64
+	Unpin()
+	Subscribe(ctx context.Context, prefixes []string, fn func([]store.Change)) error
+	ResolveDeletes(changes []store.Change) error
+	Stats() store.Stats
+}
+
+// Options carries the UI settings that come from flags or the config file.
+type Options struct {
+	Delimiters string
+	MatchMode  store.MatchMode
+	// BackupCatalog lists the backups of the DB; empty for an in-memory one.
+	BackupCatalog string
 }
 
 type kvItem struct{ key string }
@@ -28,7 +68,16 @@ func (i kvItem) Description() string { return "" }
 func (i kvItem) FilterValue() string { return i.key }
 
 // I use a thin cursor and no bold in the delegate.
-type thinCursorDelegate struct{}
+type thinCursorDelegate struct {
+	display keyDisplay
+}
+
+var (
+	keyRowStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("250"))
+	keyRowMatchStyle = keyRowStyle.Foreground(lipgloss.Color("220")).Underline(true)
+	keySelStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
+	keySelMatchStyle = keySelStyle.Foreground(lipgloss.Color("220")).Underline(true)
+)
 
 func (d thinCursorDelegate) Height() int                               { return 1 }
 func (d thinCursorDelegate) Spacing() int                              { return 0 }
@@ -39,13 +88,20 @@ func (d thinCursorDelegate) Render(w io.Writer, m list.Model, index int, listIte
 
 	// I show a thin line for the selected row; otherwise I use spaces.
 	cursor := "  "
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("250")) // I keep the normal style (no bold).
+	plain, match := keyRowStyle, keyRowMatchStyle // I keep the normal style (no bold).
 	if index == m.Index() {
 		cursor = "│ "
-		titleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true) // I use the selected color.
+		plain, match = keySelStyle, keySelMatchStyle // I use the selected color.
 	}
 
-	fmt.Fprintf(w, "%s%s", cursor, titleStyle.Render(it.Title()))
+	// I highlight the bytes the filter matched.
+	paint := func(s string, on bool) string {
+		if on {
+			return match.Render(s)
+		}
+		return plain.Render(s)
+	}
+	fmt.Fprintf(w, "%s%s", cursor, renderKey(it.key, d.display, m.MatchesForItem(index), paint))
 }
 
 type valueFormat int
@@ -63,29 +119,35 @@ type Model struct {
 	viewport   viewport.Model
 	status     string
 	valFormat  valueFormat
+	keyDisplay keyDisplay
 	ready      bool
 	selected   string
 	dbPath     string
+	readOnly   bool
 	focusRight bool
 	width      int
 	height     int
 
-	editorHeight        int
-	pageSize            int
-	lastKey             string
-	hasMoreKeys         bool
-	loadingKeys         bool
-	filterTerm          string
-	filterCount         int
-	filterCountValid    bool
-	filterCountLoading  bool
-	filterCountErr      string
-	loadingAllForFilter bool
-	groupCounts         []groupCount
-	groupCountsLoading  bool
-	groupCountsErr      string
-	showGroupCounts     bool
-	showAbout           bool
+	editorHeight       int
+	pageSize           int
+	lastKey            string
+	hasMoreKeys        bool
+	firstKey           string // I page backwards from here after a seek.
+	hasLessKeys        bool
+	loadingKeys        bool
+	matchMode          store.MatchMode
+	filter             filterState
+	filterCount        int
+	filterCountValid   bool
+	filterCountLoading bool
+	filterCountErr     string
+	groupCounts        []groupCount
+	groupCountsLoading bool
+	groupCountsErr     string
+	groupCountsTook    time.Duration
+	groupCountsJob     int
+	showGroupCounts    bool
+	showAbout          bool
 
 	// I track delete confirmation state.
 	confirmDelete bool
@@ -95,7 +157,16 @@ type Model struct {
 	patternDelete        bool
 	patternInput         textinput.Model
 	confirmPatternDelete bool
-	pendingPattern       string
+	patternMode          store.MatchMode
+	preview              deletePreview
+
+	// I track the go-to-key prompt.
+	seekPrompt bool
+	seekInput  textinput.Model
+
+	// I track new key input state.
+	newKey      bool
+	newKeyInput textinput.Model
 
 	// I track edit mode state.
 	editing       bool
@@ -103,12 +174,60 @@ type Model struct {
 	editKey       string // I track the key being edited.
 	editorHelp    string
 	lastLoadValue []byte
+
+	// I track the TTL of the shown value and what a save will do to it.
+	selectedExpiresAt uint64
+	editExpiresAt     uint64
+	editTTL           ttlChange
+	ttlPrompt         bool
+	ttlInput          textinput.Model
+	ttlTicking        bool
+
+	// I track UserMeta: the list filter, the shown value and the edit choice.
+	keyQuery       store.KeyQuery
+	selectedMeta   byte
+	editOrigMeta   byte
+	editMeta       byte
+	editMetaSet    bool
+	metaPrompt     bool
+	metaPromptEdit bool
+	metaInput      textinput.Model
+
+	history historyState
+	tree    treeState
+	jobs    jobsState
+	trash   trashState
+	export  exportState
+	imp     importState
+	backups backupsState
+	feed    feedState
+	stats   statsState
+
+	backupCatalog string
+
+	// I track the pinned snapshot by its read timestamp; 0 reads live.
+	pinnedTs uint64
+
+	// I track the value search and its form.
+	search            searchState
+	searchQuery       store.ValueSearch
+	searchInput       textinput.Model
+	searchPrefixInput textinput.Model
+
+	// I track the metadata inspector panel.
+	showInspector bool
+	inspectKey    string
+	inspectInfo   store.KeyInfo
+	inspectErr    string
 }
 
 type loadValueMsg struct {
-	key   string
-	value []byte
-	err   error
+	key       string
+	value     []byte
+	expiresAt uint64
+	userMeta  byte
+	err       error
+	refresh   bool // a write changed the shown value; keep the scroll
 }
 
 type deleteResultMsg struct {
@@ -117,26 +236,30 @@ type deleteResultMsg struct {
 }
 
 type saveResultMsg struct {
-	key string
-	err error
+	key     string
+	version uint64 // I set this when a history version was restored.
+	err     error
 }
 
 type deletePatternResultMsg struct {
-	pattern string
-	keys    []string
-	err     error
+	job     int
+	matcher store.Matcher
+	summary store.DeleteSummary
 }
 
 type loadKeysMsg struct {
+	query      store.KeyQuery
 	keys       []string
 	lastKey    string
 	hasMore    bool
 	startAfter string
+	before     bool // I prepend the page: it runs backwards from firstKey.
 	err        error
 }
 
 type filterCountMsg struct {
-	term  string
+	job   int
+	gen   int
 	count int
 	err   error
 }
@@ -147,7 +270,9 @@ type groupCount struct {
 }
 
 type groupCountsMsg struct {
+	job    int
 	counts []groupCount
+	took   time.Duration
 	err    error
 }
 
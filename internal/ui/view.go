@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
+	"github.com/dustin/go-humanize"
 )
 
 func (m Model) View() string {
@@ -15,19 +17,34 @@ func (m Model) View() string {
 
 	header := headerBarStyle.Render(padToWidth(joinLeftRight(m.appHeaderLeft(), m.appHeaderRight(), lay.innerWidth), lay.innerWidth))
 
-	leftHeader := panelHeaderStyle.Render(padToWidth(m.listHeaderText(), lay.listWidth))
+	leftTitle, leftBody := m.listHeaderText(), m.list.View()
+	if m.tree.active {
+		leftTitle, leftBody = m.treeHeaderText(), m.treeView(lay)
+	}
+	if m.search.active {
+		leftTitle, leftBody = m.searchHeaderText(), m.searchView(lay)
+	}
+	leftHeader := panelHeaderStyle.Render(padToWidth(truncateString(leftTitle, lay.listWidth), lay.listWidth))
 	left := paneStyle.Render(
 		lipgloss.JoinVertical(lipgloss.Left,
 			leftHeader,
-			m.list.View(),
+			leftBody,
 		),
 	)
 
 	var rightTitle string
-	if m.editing {
-		rightTitle = fmt.Sprintf("Edit: %s  (Ctrl+S save · Esc cancel)", m.editKey)
+	if m.history.active {
+		rightTitle = m.historyTitle()
+	} else if m.editing {
+		rightTitle = fmt.Sprintf("Edit: %s  %s  %s  %s", m.showKey(m.editKey), m.editMetaLabel(), m.editTTLLabel(time.Now()), m.editorHelp)
 	} else {
-		rightTitle = fmt.Sprintf("Value: %s", m.selected)
+		rightTitle = fmt.Sprintf("Value: %s", m.showKey(m.selected))
+		if m.selected != "" {
+			rightTitle += "  meta " + formatMeta(m.selectedMeta)
+		}
+		if m.selected != "" && m.selectedExpiresAt != 0 {
+			rightTitle += "  " + describeExpiry(m.selectedExpiresAt, time.Now())
+		}
 		if m.focusRight {
 			rightTitle += "  [scroll]"
 		}
@@ -36,12 +53,16 @@ func (m Model) View() string {
 	rightHeader := panelHeaderStyle.Render(padToWidth(rightTitle, lay.rightContentWidth))
 
 	var rightBody string
-	if m.editing {
+	if m.history.active {
+		rightBody = m.historyView(lay)
+	} else if m.editing {
 		if m.valFormat == fmtJSON {
 			rightBody = m.renderJSONEditor(lay)
 		} else {
 			rightBody = m.editor.View()
 		}
+	} else if m.showInspector {
+		rightBody = m.inspectorView(lay.rightContentWidth) + "\n" + m.viewport.View()
 	} else {
 		rightBody = m.viewport.View()
 	}
@@ -54,9 +75,47 @@ func (m Model) View() string {
 
 	spacer := strings.Repeat(" ", panelGap)
 	body := lipgloss.JoinHorizontal(lipgloss.Top, left, spacer, right)
+	if m.stats.active {
+		body = m.statsView(lay)
+	}
 	footerText := m.status
+	if j, ok := m.runningJob(jobDelete); ok && j.total > 0 {
+		footerText = fmt.Sprintf("%s  %s  %s/%s keys", j.label, progressBar(j.progress.Scanned(), j.total, 30),
+			humanize.Comma(j.progress.Scanned()), humanize.Comma(j.total))
+	}
 	if m.patternDelete {
-		footerText = "Delete pattern (glob): " + m.patternInput.View() + "  (Enter confirm · Esc cancel)"
+		footerText = fmt.Sprintf("Delete keys matching (%s): %s  (Enter confirm · Ctrl+R mode · Esc cancel)", m.patternMode, m.patternInput.View())
+	}
+	if m.metaPrompt {
+		footerText = "UserMeta (0-255 or 0x..): " + m.metaInput.View() + "  (Enter apply · Esc cancel)"
+	}
+	if m.ttlPrompt {
+		footerText = "Set TTL (2h · +30m · clear · keep · 2006-01-02 15:04:05): " + m.ttlInput.View() + "  (Enter apply · Esc cancel)"
+	}
+	if m.seekPrompt {
+		footerText = "Go to key (text, \\xNN, \\x{..} or 0x hex): " + m.seekInput.View() + "  (Enter go · Esc cancel)"
+	}
+	if m.search.prompt {
+		mode := "substring"
+		if m.search.regex {
+			mode = "regex"
+		}
+		footerText = fmt.Sprintf("Search values (%s): %s  %s  (Enter search · Tab field · Ctrl+R mode · Esc cancel)", mode, m.searchInput.View(), m.searchPrefixInput.View())
+	}
+	if m.export.prompt {
+		footerText = m.exportPromptText()
+	}
+	if m.imp.prompt {
+		footerText = m.importPromptText()
+	}
+	if m.backups.prompt != backupPromptNone {
+		footerText = m.backupPromptText()
+	}
+	if m.feed.prompt {
+		footerText = m.feedPromptText()
+	}
+	if m.newKey {
+		footerText = "New key (text, \\xNN, \\x{..} or 0x hex): " + m.newKeyInput.View() + "  (Enter edit · Esc cancel)"
 	}
 	footer := footerBarStyle.Render(padToWidth(truncateString(footerText, lay.innerWidth), lay.innerWidth))
 
@@ -66,22 +125,39 @@ func (m Model) View() string {
 	if m.showAbout {
 		return m.aboutView(lay)
 	}
+	if m.confirmPatternDelete {
+		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.deletePreviewView(lay.innerWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
+	}
+	if m.backups.active {
+		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.backupsView(lay.innerWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
+	}
+	if m.trash.active {
+		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.trashView(lay.innerWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
+	}
+	if m.jobs.show {
+		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.jobsView(lay.innerWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
+	}
 	if m.showGroupCounts {
 		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.groupCountsView(lay.innerWidth))
 		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
 	}
+	if m.feed.active {
+		panel := lipgloss.NewStyle().Padding(appPadY, appPadX).Render(m.feedView(lay.innerWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, panel, app)
+	}
 	return app
 }
 
 func (m Model) listHeaderText() string {
 	total := len(m.list.Items())
 	visible := len(m.list.VisibleItems())
-	suffix := ""
-	if m.hasMoreKeys {
-		suffix = "+"
-	}
-	if m.loadingKeys {
-		suffix += "…"
+	suffix := m.pagingSuffix()
+	if m.hasLessKeys {
+		suffix += " (more above)"
 	}
 	if m.list.IsFiltered() || m.list.SettingFilter() {
 		return fmt.Sprintf("Keys %d/%d%s", visible, total, suffix)
@@ -89,6 +165,23 @@ func (m Model) listHeaderText() string {
 	return fmt.Sprintf("Keys %d%s", total, suffix)
 }
 
+// pagingSuffix marks a count that is not final: "+" when more keys are
+// left to load and "…" while a page is on its way.
+func (m Model) pagingSuffix() string {
+	more, loading := m.hasMoreKeys, m.loadingKeys
+	if m.filter.active {
+		more, loading = !m.filter.done, m.filter.loading
+	}
+	suffix := ""
+	if more {
+		suffix = "+"
+	}
+	if loading {
+		suffix += "…"
+	}
+	return suffix
+}
+
 func (m Model) appHeaderLeft() string {
 	left := appTitleStyle.Render("badger-gui")
 	meta := appMetaStyle.Render(fmt.Sprintf("DB: %s", m.dbPath))
@@ -98,18 +191,15 @@ func (m Model) appHeaderLeft() string {
 func (m Model) appHeaderRight() string {
 	total := len(m.list.Items())
 	visible := len(m.list.VisibleItems())
-	suffix := ""
-	if m.hasMoreKeys {
-		suffix = "+"
-	}
-	if m.loadingKeys {
-		suffix += "…"
-	}
+	suffix := m.pagingSuffix()
 	count := fmt.Sprintf("Keys: %d%s", total, suffix)
 	if m.list.IsFiltered() || m.list.SettingFilter() {
 		count = fmt.Sprintf("Keys: %d/%d%s", visible, total, suffix)
 	}
 	format := fmt.Sprintf("Format: %s", m.formatName())
+	if m.keyDisplay != keyEscaped {
+		format += fmt.Sprintf("  Keys as: %s", m.keyDisplay.name())
+	}
 	filter := ""
 	if m.list.FilterState() != list.Unfiltered {
 		fv := m.list.FilterValue()
@@ -118,7 +208,19 @@ func (m Model) appHeaderRight() string {
 		}
 		filter = fmt.Sprintf("Filter: %s", truncateString(fv, 20))
 	}
-	parts := []string{count, format}
+	parts := []string{count, format, "Match: " + m.matchMode.String()}
+	if m.pinnedTs != 0 {
+		parts = append(parts, fmt.Sprintf("Snapshot: ts %d", m.pinnedTs))
+	}
+	if m.keyQuery.Reverse {
+		parts = append(parts, "Order: desc")
+	}
+	if m.keyQuery.Prefix != "" {
+		parts = append(parts, "Prefix: "+truncateString(m.showKey(m.keyQuery.Prefix), 24))
+	}
+	if m.keyQuery.HasMeta {
+		parts = append(parts, "Meta: "+formatMeta(m.keyQuery.UserMeta))
+	}
 	if filter != "" {
 		parts = append(parts, filter)
 	}
@@ -131,11 +233,22 @@ func (m Model) appHeaderRight() string {
 			parts = append(parts, fmt.Sprintf("Matches: %d", m.filterCount))
 		}
 	}
-	return appMetaStyle.Render(strings.Join(parts, "  "))
+	if n := m.runningJobs(); n > 0 {
+		parts = append(parts, fmt.Sprintf("Jobs: %d", n))
+	}
+	right := appMetaStyle.Render(strings.Join(parts, "  "))
+	if m.readOnly {
+		right = readOnlyBadgeStyle.Render("READ-ONLY") + " " + right
+	}
+	return right
 }
 
 func (m Model) groupCountsView(width int) string {
 	title := "Group counts (prefix before ':')"
+	if !m.groupCountsLoading && m.groupCountsErr == "" && m.groupCountsTook > 0 {
+		// I show the scan time so the worker count can be tuned.
+		title += fmt.Sprintf(" in %s", m.groupCountsTook.Round(time.Millisecond))
+	}
 	lines := []string{title}
 	if m.groupCountsLoading {
 		lines = append(lines, "Loading…")
@@ -153,7 +266,7 @@ func (m Model) groupCountsView(width int) string {
 		}
 		for i := 0; i < len(m.groupCounts) && i < maxLines-1; i++ {
 			g := m.groupCounts[i]
-			name := g.group
+			name := m.showKey(g.group)
 			if lipgloss.Width(name) > 20 {
 				name = truncateString(name, 20)
 			}
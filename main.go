@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"badge-reader/internal/app"
 
@@ -19,11 +20,97 @@ func main() {
 				Name:    "dbpath",
 				Aliases: []string{"d"},
 				Usage:   "Badger DB directory",
-				Value:   "./data/badger",
+				Value:   app.DefaultDBPath,
+			},
+			&cli.BoolFlag{
+				Name:    "write",
+				Aliases: []string{"w"},
+				Usage:   "Open the DB read-write and enable edit/delete (default is read-only)",
+			},
+			&cli.BoolFlag{
+				Name:  "snapshot",
+				Usage: "Open a private read-only copy of the DB, e.g. while another process holds its lock; the copy is removed on exit",
+			},
+			&cli.DurationFlag{
+				Name:  "wait-for-lock",
+				Usage: "Keep retrying this long, e.g. 30s, while another process holds the DB lock",
+			},
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "JSON config file; CLI flags override its keys",
+				Sources: cli.EnvVars("BADGER_GUI_CONFIG"),
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Base tuning: inspect (low memory) or performance",
+				Value: "inspect",
+			},
+			&cli.StringFlag{
+				Name:  "block-cache",
+				Usage: "Block cache size, e.g. 16MiB",
+			},
+			&cli.StringFlag{
+				Name:  "index-cache",
+				Usage: "Index cache size, e.g. 64MiB (0 keeps indexes in memory)",
+			},
+			&cli.IntFlag{
+				Name:  "compactors",
+				Usage: "Number of compaction goroutines (0 or at least 2)",
+			},
+			&cli.StringFlag{
+				Name:  "compression",
+				Usage: "Table compression: none, snappy or zstd",
+			},
+			&cli.BoolFlag{
+				Name:  "sync-writes",
+				Usage: "Fsync every write",
+			},
+			&cli.StringFlag{
+				Name:  "value-threshold",
+				Usage: "Values larger than this go to the value log, e.g. 1MiB",
+			},
+			&cli.BoolFlag{
+				Name:  "in-memory",
+				Usage: "Open an empty in-memory DB (needs --write)",
+			},
+			&cli.StringFlag{
+				Name:  "log-level",
+				Usage: "Badger logger verbosity: debug, info, warning, error or off",
+			},
+			&cli.IntFlag{
+				Name:  "scan-workers",
+				Usage: "Goroutines for full scans such as match counts and group counts (0 = one per CPU)",
+			},
+			&cli.StringFlag{
+				Name:  "delimiters",
+				Usage: "Characters the tree browser splits keys on (default \"/:\")",
+			},
+			&cli.StringFlag{
+				Name:  "match-mode",
+				Usage: "Starting filter mode: fuzzy, substring, prefix, regex or glob",
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "JSONL file every change is logged to (default <dbpath>.audit.jsonl)",
+			},
+			&cli.StringFlag{
+				Name:    "encryption-key-file",
+				Usage:   "AES key (16/24/32 bytes, raw or hex) for a DB encrypted at rest; " + app.EncryptionKeyEnv + " may hold the key instead",
+				Sources: cli.EnvVars("BADGER_GUI_ENCRYPTION_KEY_FILE"),
+			},
+			&cli.DurationFlag{
+				Name:  "encryption-key-rotation",
+				Usage: "How often Badger rotates data keys when writing (default 240h)",
 			},
 		},
+		Commands: []*cli.Command{auditCommand(), exportCommand(), importCommand(), backupCommand(), restoreCommand(), verifyCommand()},
 		Action: func(ctx context.Context, c *cli.Command) error {
-			return app.Run(c.String("dbpath"))
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.Run(cfg)
 		},
 	}
 
@@ -31,3 +118,251 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// I layer the config file under the flags the user actually passed.
+func resolveConfig(c *cli.Command) (app.Config, error) {
+	var layers []app.Settings
+	if path := c.String("config"); path != "" {
+		file, err := app.LoadSettingsFile(path)
+		if err != nil {
+			return app.Config{}, err
+		}
+		layers = append(layers, file)
+	}
+	layers = append(layers, app.EnvSettings())
+	return app.ResolveConfig(append(layers, flagSettings(c))...)
+}
+
+func flagSettings(c *cli.Command) app.Settings {
+	var s app.Settings
+	s.DBPath = stringFlag(c, "dbpath")
+	s.Write = boolFlag(c, "write")
+	s.Profile = stringFlag(c, "profile")
+	s.BlockCacheSize = stringFlag(c, "block-cache")
+	s.IndexCacheSize = stringFlag(c, "index-cache")
+	if c.IsSet("compactors") {
+		v := int(c.Int("compactors"))
+		s.NumCompactors = &v
+	}
+	s.Compression = stringFlag(c, "compression")
+	s.SyncWrites = boolFlag(c, "sync-writes")
+	s.ValueThreshold = stringFlag(c, "value-threshold")
+	s.InMemory = boolFlag(c, "in-memory")
+	s.LogLevel = stringFlag(c, "log-level")
+	if c.IsSet("scan-workers") {
+		v := int(c.Int("scan-workers"))
+		s.ScanWorkers = &v
+	}
+	s.Delimiters = stringFlag(c, "delimiters")
+	s.MatchMode = stringFlag(c, "match-mode")
+	s.AuditLog = stringFlag(c, "audit-log")
+	s.EncryptionKeyFile = stringFlag(c, "encryption-key-file")
+	if c.IsSet("encryption-key-rotation") {
+		v := c.Duration("encryption-key-rotation").String()
+		s.EncryptionKeyRotation = &v
+	}
+	s.Snapshot = boolFlag(c, "snapshot")
+	if c.IsSet("wait-for-lock") {
+		v := c.Duration("wait-for-lock").String()
+		s.WaitForLock = &v
+	}
+	return s
+}
+
+func stringFlag(c *cli.Command, name string) *string {
+	if !c.IsSet(name) {
+		return nil
+	}
+	v := c.String(name)
+	return &v
+}
+
+func boolFlag(c *cli.Command, name string) *bool {
+	if !c.IsSet(name) {
+		return nil
+	}
+	v := c.Bool(name)
+	return &v
+}
+
+func auditCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "audit",
+		Usage:     "Show the changes made with badger-gui, from the audit log",
+		UsageText: "badger-gui [--dbpath DIR] audit [--key KEY | --prefix P] [--since T] [--until T] [--op OP] [--user U] [--json]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "key", Usage: "Only this key"},
+			&cli.StringFlag{Name: "prefix", Usage: "Only keys with this prefix"},
+			&cli.StringFlag{Name: "since", Usage: "From this time: RFC 3339, 2006-01-02[ 15:04], or a duration ago such as 24h"},
+			&cli.StringFlag{Name: "until", Usage: "Before this time, in the same formats"},
+			&cli.StringFlag{Name: "op", Usage: "Only set, delete, pattern delete, drop prefix, restore, restore backup or import"},
+			&cli.StringFlag{Name: "user", Usage: "Only changes by this OS user"},
+			&cli.BoolFlag{Name: "json", Usage: "Print matching entries as JSONL"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			q := app.AuditQuery{
+				Key:    c.String("key"),
+				Prefix: c.String("prefix"),
+				Op:     c.String("op"),
+				User:   c.String("user"),
+				JSON:   c.Bool("json"),
+			}
+			now := time.Now()
+			if v := c.String("since"); v != "" {
+				if q.Since, err = app.ParseAuditTime(v, now); err != nil {
+					return err
+				}
+			}
+			if v := c.String("until"); v != "" {
+				if q.Until, err = app.ParseAuditTime(v, now); err != nil {
+					return err
+				}
+			}
+			return app.RunAudit(cfg, q, os.Stdout)
+		},
+	}
+}
+
+func exportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Write keys and values to a JSONL or CSV file",
+		UsageText: "badger-gui [--dbpath DIR] [--match-mode MODE] export [--prefix P] [--match TERM] [--format jsonl|csv] [--encoding E] [--flatten] [--force] -o FILE",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "File to write, or - for stdout", Required: true},
+			&cli.StringFlag{Name: "prefix", Usage: "Only keys with this prefix"},
+			&cli.StringFlag{Name: "match", Usage: "Only keys matching this glob, or this term in --match-mode when given"},
+			&cli.StringFlag{Name: "format", Usage: "jsonl or csv (default from the file extension, else jsonl)"},
+			&cli.StringFlag{Name: "encoding", Usage: "Value encoding: text, base64, hex or json", Value: "text"},
+			&cli.BoolFlag{Name: "flatten", Usage: "CSV only: give each field of a JSON object value its own column"},
+			&cli.BoolFlag{Name: "force", Usage: "Overwrite the output file if it exists"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.RunExport(ctx, cfg, app.ExportRequest{
+				Path:      c.String("output"),
+				Prefix:    c.String("prefix"),
+				Match:     c.String("match"),
+				MatchMode: c.String("match-mode"),
+				Format:    c.String("format"),
+				Encoding:  c.String("encoding"),
+				Flatten:   c.Bool("flatten"),
+				Force:     c.Bool("force"),
+			}, os.Stderr)
+		},
+	}
+}
+
+func importCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Load keys and values from a JSONL or CSV file (needs --write unless --dry-run)",
+		UsageText: "badger-gui [--dbpath DIR] --write import [--key-column C | --key-template T] [--encoding E] [--on-conflict P] [--dry-run] FILE",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "format", Usage: "jsonl or csv (default from the file extension, else jsonl)"},
+			&cli.StringFlag{Name: "key-column", Usage: "Column or field holding the key (default key)"},
+			&cli.StringFlag{Name: "key-template", Usage: "Build keys from columns instead, e.g. user:{id} or user:{value.id}"},
+			&cli.StringFlag{Name: "value-column", Usage: "Column or field holding the value (default value)"},
+			&cli.StringFlag{Name: "encoding", Usage: "Value encoding for records without an encoding column: text, base64, hex or json", Value: "text"},
+			&cli.StringFlag{Name: "ttl-column", Usage: "Column with the expiry: unix seconds, a duration or RFC 3339 (default expires_at)"},
+			&cli.StringFlag{Name: "meta-column", Usage: "Column with the UserMeta byte (default user_meta)"},
+			&cli.StringFlag{Name: "on-conflict", Usage: "For keys that exist: overwrite, skip or fail", Value: "overwrite"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Only count the keys that would be created, overwritten or skipped"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("import takes one file", 2)
+			}
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.RunImport(ctx, cfg, app.ImportRequest{
+				Path:        c.Args().First(),
+				Format:      c.String("format"),
+				KeyColumn:   c.String("key-column"),
+				KeyTemplate: c.String("key-template"),
+				ValueColumn: c.String("value-column"),
+				Encoding:    c.String("encoding"),
+				TTLColumn:   c.String("ttl-column"),
+				MetaColumn:  c.String("meta-column"),
+				Conflict:    c.String("on-conflict"),
+				DryRun:      c.Bool("dry-run"),
+			}, os.Stderr)
+		},
+	}
+}
+
+func backupCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "backup",
+		Usage:     "Write a full or incremental Badger backup",
+		UsageText: "badger-gui [--dbpath DIR] backup [-o FILE] [--prefix P] [--incremental | --since VERSION] [--gzip]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "File to write (default <dbpath>-<time>-full.bak.gz); .gz compresses"},
+			&cli.StringFlag{Name: "prefix", Usage: "Only keys with this prefix"},
+			&cli.BoolFlag{Name: "incremental", Usage: "Only changes since the latest backup of the same prefix in the catalog"},
+			&cli.Uint64Flag{Name: "since", Usage: "Only changes from this version on"},
+			&cli.BoolFlag{Name: "gzip", Usage: "Compress whatever the file name"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.RunBackup(ctx, cfg, app.BackupRequest{
+				Path:        c.String("output"),
+				Prefix:      c.String("prefix"),
+				Incremental: c.Bool("incremental"),
+				Since:       c.Uint64("since"),
+				Gzip:        c.Bool("gzip"),
+			}, os.Stderr)
+		},
+	}
+}
+
+func restoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "restore",
+		Usage:     "Load backups into an empty DB: a full one, then its incremental ones in order",
+		UsageText: "badger-gui --dbpath NEWDIR --write restore [--force] FULL [INCREMENTAL...]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "force", Usage: "Load into a DB that already has keys; newer versions there win"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.Args().Len() == 0 {
+				return cli.Exit("restore takes at least one backup file", 2)
+			}
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.RunRestore(ctx, cfg, c.Args().Slice(), c.Bool("force"), os.Stderr)
+		},
+	}
+}
+
+func verifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Restore a backup into memory and compare its key count with the catalog",
+		UsageText: "badger-gui [--dbpath DIR] verify FILE",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("verify takes one backup file", 2)
+			}
+			cfg, err := resolveConfig(c)
+			if err != nil {
+				return err
+			}
+			return app.RunVerify(ctx, cfg, c.Args().First(), os.Stdout)
+		},
+	}
+}